@@ -0,0 +1,78 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// channels of the index that plugins can be installed from. The manifests of
+// the stable channel are in the plugins directory of the index, the ones of
+// other channels in the channels/<channel> directory.
+const (
+	stableChannel = "stable"
+	betaChannel   = "beta"
+)
+
+var indexChannels = []string{stableChannel, betaChannel}
+
+// parseChannel validates the channel name and returns it as it is recorded in
+// receipts, where the stable channel is empty.
+func parseChannel(channel string) (string, error) {
+	switch channel {
+	case "", stableChannel:
+		return "", nil
+	case betaChannel:
+		return channel, nil
+	}
+	return "", errors.Errorf("unsupported channel %q, must be one of: %s", channel, strings.Join(indexChannels, ", "))
+}
+
+// loadChannelPlugin loads the manifest of the plugin for the channel. A plugin
+// tracking a pre-release channel gets the stable version once it is newer.
+func loadChannelPlugin(p environment.Paths, name, channel string) (index.Plugin, error) {
+	return indexscanner.LoadNewestPlugin(name, channelPluginsDirs(p, channel)...)
+}
+
+// channelPluginsDirs returns the directories of the index with the plugin
+// manifests for the channel.
+func channelPluginsDirs(p environment.Paths, channel string) []string {
+	dirs := []string{p.IndexPluginsPath()}
+	if channel != "" {
+		dirs = append(dirs, p.IndexChannelPath(channel))
+	}
+	return dirs
+}
+
+// installedChannel returns the channel the installed plugin tracks, which is
+// empty for the stable channel. The name is resolved against the index like
+// the names of plugins loaded from it.
+func installedChannel(p environment.Paths, name string) string {
+	name = indexscanner.CanonicalPluginName(p.IndexPluginsPath(), name)
+	r, err := receipt.Load(p.PluginInstallReceiptPath(name))
+	if err != nil {
+		klog.V(2).Infof("Failed to load the receipt of plugin %s: %v", name, err)
+		return ""
+	}
+	return r.Status.Source.Channel
+}
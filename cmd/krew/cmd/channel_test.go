@@ -0,0 +1,91 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func Test_parseChannel(t *testing.T) {
+	tests := []struct {
+		channel  string
+		expected string
+		wantErr  bool
+	}{
+		{channel: "", expected: ""},
+		{channel: stableChannel, expected: ""},
+		{channel: betaChannel, expected: betaChannel},
+		{channel: "nightly", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseChannel(tt.channel)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseChannel(%q) error = %v, wantErr %v", tt.channel, err, tt.wantErr)
+		}
+		if got != tt.expected {
+			t.Errorf("parseChannel(%q) = %q, want %q", tt.channel, got, tt.expected)
+		}
+	}
+}
+
+func Test_loadChannelPlugin(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+
+	write := func(dir, name, version string) {
+		b, err := yaml.Marshal(testutil.NewPlugin().WithName(name).WithVersion(version).V())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir.Write(dir+"/"+name+constants.ManifestExtension, b)
+	}
+	write("index/plugins", "foo", "v1.0.0")
+	write("index/channels/beta", "foo", "v1.1.0-beta.1")
+	write("index/plugins", "bar", "v2.0.0")
+	write("index/channels/beta", "bar", "v2.0.0-rc.1")
+	write("index/channels/beta", "new", "v0.1.0")
+
+	tests := []struct {
+		name     string
+		channel  string
+		expected string
+	}{
+		{name: "foo", channel: "", expected: "v1.0.0"},
+		{name: "foo", channel: betaChannel, expected: "v1.1.0-beta.1"},
+		{name: "bar", channel: betaChannel, expected: "v2.0.0"},
+		{name: "new", channel: betaChannel, expected: "v0.1.0"},
+	}
+	for _, tt := range tests {
+		plugin, err := loadChannelPlugin(p, tt.name, tt.channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plugin.Spec.Version != tt.expected {
+			t.Errorf("loadChannelPlugin(%q, %q) version = %s, want %s", tt.name, tt.channel, plugin.Spec.Version, tt.expected)
+		}
+	}
+
+	if _, err := loadChannelPlugin(p, "new", ""); !os.IsNotExist(err) {
+		t.Errorf("expected IsNotExist error for a plugin only in the beta channel, got: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/gitutil"
+)
+
+// configEnv is the path of the config file to use instead of the default
+// one, like the --config-file flag.
+const configEnv = "KREW_CONFIG"
+
+// config holds the defaults for global settings read from the config file.
+// Flags and environment variables take precedence over it.
+type config struct {
+	Offline         bool     `json:"offline,omitempty"`
+	DownloadHosts   []string `json:"downloadHosts,omitempty"`
+	UserAgent       string   `json:"userAgent,omitempty"`
+	DownloadHeaders []string `json:"downloadHeaders,omitempty"`
+	DefaultIndexURI string   `json:"defaultIndexURI,omitempty"`
+	Channel         string   `json:"channel,omitempty"`
+	MaxDownloadRate string   `json:"maxDownloadRate,omitempty"`
+	Hook            string   `json:"hook,omitempty"`
+	HookStrict      bool     `json:"hookStrict,omitempty"`
+	NoUpdateNotice  bool     `json:"noUpdateNotice,omitempty"`
+
+	// DownloadCacheSizeMB is the size in MiB the download cache is trimmed
+	// to, defaultDownloadCacheSizeMB if not set.
+	DownloadCacheSizeMB int `json:"downloadCacheSizeMB,omitempty"`
+}
+
+// defaultDownloadCacheSizeMB is the default maximum size of the download cache.
+const defaultDownloadCacheSizeMB = 1024
+
+var (
+	configFile *string // config file specified with --config or --config-file
+	cfg        config  // settings loaded from the config file
+)
+
+// configPath returns the path of the config file, and whether it was
+// explicitly specified with --config-file or configEnv.
+func configPath() (string, bool) {
+	if *configFile != "" {
+		return *configFile, true
+	}
+	if env := os.Getenv(configEnv); env != "" {
+		return env, true
+	}
+	return paths.ConfigPath(), false
+}
+
+// loadConfig reads and validates the config file at path. A missing file is
+// only an error if it is required. Unknown keys are ignored with a warning, so
+// that typos are noticed and config files written for newer versions of krew
+// still work.
+func loadConfig(path string, required bool) (config, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		klog.V(4).Infof("No config file at %q", path)
+		return config{}, nil
+	} else if err != nil {
+		return config{}, errors.Wrapf(err, "failed to read config file %q", path)
+	}
+
+	var c config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return config{}, errors.Wrapf(err, "invalid config file %q", path)
+	}
+	unknown, err := unknownConfigKeys(b)
+	if err != nil {
+		return config{}, errors.Wrapf(err, "invalid config file %q", path)
+	}
+	for _, key := range unknown {
+		klog.Warningf("Ignoring unknown key %q in config file %q", key, path)
+	}
+	if err := c.validate(); err != nil {
+		return config{}, errors.Wrapf(err, "invalid config file %q", path)
+	}
+	klog.V(1).Infof("Loaded config file %q", path)
+	return c, nil
+}
+
+// unknownConfigKeys returns the top-level keys of the config file content that
+// are not settings of config, sorted.
+func unknownConfigKeys(b []byte) ([]string, error) {
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	t := reflect.TypeOf(config{})
+	for i := 0; i < t.NumField(); i++ {
+		known[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	var unknown []string
+	for key := range m {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func (c config) validate() error {
+	if _, err := parseDownloadHosts(c.DownloadHosts); err != nil {
+		return errors.Wrap(err, "invalid downloadHosts")
+	}
+	if _, err := parseDownloadHeaders(c.DownloadHeaders); err != nil {
+		return errors.Wrap(err, "invalid downloadHeaders")
+	}
+	if c.DefaultIndexURI != "" {
+		if err := gitutil.ValidateURL(c.DefaultIndexURI); err != nil {
+			return errors.Wrap(err, "invalid defaultIndexURI")
+		}
+	}
+	if _, err := parseChannel(c.Channel); err != nil {
+		return errors.Wrap(err, "invalid channel")
+	}
+	if c.MaxDownloadRate != "" {
+		if _, err := download.ParseRate(c.MaxDownloadRate); err != nil {
+			return errors.Wrap(err, "invalid maxDownloadRate")
+		}
+	}
+	if c.DownloadCacheSizeMB < 0 {
+		return errors.Errorf("invalid downloadCacheSizeMB %d, must not be negative", c.DownloadCacheSizeMB)
+	}
+	return nil
+}
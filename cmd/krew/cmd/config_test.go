@@ -0,0 +1,158 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func Test_loadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		required bool
+		want     config
+		wantErr  bool
+	}{
+		{
+			name: "all settings",
+			content: `offline: true
+downloadHosts: ["github.com=mirror.internal"]
+userAgent: my-krew
+downloadHeaders: ["X-Api-Key: foo"]
+defaultIndexURI: https://git.internal/krew-index.git
+channel: beta
+maxDownloadRate: 5MB/s
+downloadCacheSizeMB: 100
+`,
+			want: config{
+				Offline:         true,
+				DownloadHosts:   []string{"github.com=mirror.internal"},
+				UserAgent:       "my-krew",
+				DownloadHeaders: []string{"X-Api-Key: foo"},
+				DefaultIndexURI: "https://git.internal/krew-index.git",
+				Channel:         "beta",
+				MaxDownloadRate: "5MB/s",
+
+				DownloadCacheSizeMB: 100,
+			},
+		},
+		{name: "empty file", content: "", want: config{}},
+		{name: "unknown key", content: "ofline: true\nchannel: beta\n", want: config{Channel: "beta"}},
+		{name: "not a map", content: "- offline\n", wantErr: true},
+		{name: "invalid download host", content: "downloadHosts: [github.com]\n", wantErr: true},
+		{name: "invalid download header", content: "downloadHeaders: [foo]\n", wantErr: true},
+		{name: "invalid channel", content: "channel: nightly\n", wantErr: true},
+		{name: "negative download rate", content: "maxDownloadRate: -5MB/s\n", wantErr: true},
+		{name: "invalid download rate", content: "maxDownloadRate: fast\n", wantErr: true},
+		{name: "negative download cache size", content: "downloadCacheSizeMB: -1\n", wantErr: true},
+		{name: "invalid index URI", content: "defaultIndexURI: ftp://example.com/index\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("config", []byte(tt.content))
+
+			got, err := loadConfig(tmpDir.Path("config"), tt.required)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("loadConfig() mismatch:\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_unknownConfigKeys(t *testing.T) {
+	got, err := unknownConfigKeys([]byte("ofline: true\nchannel: beta\nproxy: http://proxy.internal\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"ofline", "proxy"}, got); diff != "" {
+		t.Errorf("unknownConfigKeys() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_loadConfig_missing(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	if _, err := loadConfig(tmpDir.Path("config"), false); err != nil {
+		t.Errorf("expected a missing default config file to be ignored, got: %v", err)
+	}
+	if _, err := loadConfig(tmpDir.Path("config"), true); err == nil {
+		t.Error("expected an error for a missing config file that was specified explicitly")
+	}
+}
+
+func Test_configPath(t *testing.T) {
+	defer func(orig string) { *configFile = orig }(*configFile)
+	defer os.Unsetenv(configEnv)
+
+	*configFile = ""
+	if got, explicit := configPath(); got != paths.ConfigPath() || explicit {
+		t.Errorf("configPath() = (%q, %v), want the default path", got, explicit)
+	}
+	os.Setenv(configEnv, "/env/config")
+	if got, explicit := configPath(); got != "/env/config" || !explicit {
+		t.Errorf("configPath() = (%q, %v), want the path from %s", got, explicit, configEnv)
+	}
+	*configFile = "/flag/config"
+	if got, explicit := configPath(); got != "/flag/config" || !explicit {
+		t.Errorf("configPath() = (%q, %v), want the path from the flag", got, explicit)
+	}
+}
+
+func Test_globalSettings_fromConfig(t *testing.T) {
+	defer func(orig config) { cfg = orig }(cfg)
+	defer os.Unsetenv(offlineEnv)
+	defer os.Unsetenv(userAgentEnv)
+
+	cfg = config{Offline: true, UserAgent: "config-ua", DownloadHosts: []string{"github.com=config.internal"}}
+	if !isOffline() {
+		t.Error("expected the offline mode from the config file")
+	}
+	os.Setenv(offlineEnv, "0")
+	if isOffline() {
+		t.Errorf("expected %s to override the config file", offlineEnv)
+	}
+
+	f, err := httpFetcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.UserAgent != "config-ua" {
+		t.Errorf("UserAgent = %q, want the one from the config file", f.UserAgent)
+	}
+	os.Setenv(userAgentEnv, "env-ua")
+	if f, _ = httpFetcher(); f.UserAgent != "env-ua" {
+		t.Errorf("UserAgent = %q, want %s to override the config file", f.UserAgent, userAgentEnv)
+	}
+
+	hosts, err := downloadHostRemap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts["github.com"] != "config.internal" {
+		t.Errorf("downloadHostRemap() = %v, want the remap from the config file", hosts)
+	}
+}
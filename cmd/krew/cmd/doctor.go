@@ -0,0 +1,212 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/homedir"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/installation"
+)
+
+const (
+	pathBlockBegin = "# >>> krew PATH >>>"
+	pathBlockEnd   = "# <<< krew PATH <<<"
+)
+
+func init() {
+	var fixPath *bool
+
+	// doctorCmd represents the doctor command
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the krew setup for problems",
+		Long: `Check the krew setup for problems, such as the krew bin directory missing
+from PATH, which makes installed plugins unavailable to kubectl.
+
+To add the bin directory to PATH in the profile file of your shell (bash, zsh
+or fish), run:
+  kubectl krew doctor --fix-path
+
+The line is added in a marked block, so running it again doesn't add it twice.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			binDir := paths.BinPath()
+			if isInPath(binDir, os.Getenv("PATH")) {
+				fmt.Fprintf(os.Stderr, "The krew bin directory %q is in PATH.\n", binDir)
+				return nil
+			}
+			if !*fixPath {
+				fmt.Fprintf(os.Stderr, "The krew bin directory %q is not in PATH, installed plugins can't be found by kubectl.\n"+
+					"Run \"kubectl krew doctor --fix-path\" to add it to your shell profile.\n", binDir)
+				return nil
+			}
+			if installation.IsWindows() {
+				return errors.Errorf("--fix-path is not supported on Windows, add %q to the PATH environment variable manually", binDir)
+			}
+
+			profile, err := shellProfile(os.Getenv("SHELL"), homedir.HomeDir(), runtime.GOOS)
+			if err != nil {
+				return err
+			}
+			block, err := profile.pathBlock(binDir)
+			if err != nil {
+				return err
+			}
+			changed, err := writePathBlock(profile.file, block)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				fmt.Fprintf(os.Stderr, "%s already adds the krew bin directory to PATH, restart your shell to use it.\n", profile.file)
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Added these lines to %s:\n\n%s\n"+
+				"Restart your shell, or run \"source %s\", to use it.\n"+
+				"To undo the change, remove the lines from %q to %q in %s.\n",
+				profile.file, block, profile.file, pathBlockBegin, pathBlockEnd, profile.file)
+			return nil
+		},
+	}
+
+	fixPath = doctorCmd.Flags().Bool("fix-path", false, "add the krew bin directory to PATH in the profile file of your shell")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// isInPath reports whether dir is one of the directories in the PATH value.
+func isInPath(dir, pathEnv string) bool {
+	dir = filepath.Clean(dir)
+	for _, d := range filepath.SplitList(pathEnv) {
+		if d != "" && filepath.Clean(d) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// profile is a shell profile file that krew knows how to add PATH entries to.
+type profile struct {
+	shell string
+	file  string
+}
+
+// shellProfile returns the profile file of the user's shell, detected from
+// the value of $SHELL. Only shells with a well known profile file are
+// supported.
+func shellProfile(shell, home, goos string) (profile, error) {
+	name := filepath.Base(shell)
+	switch name {
+	case "bash":
+		// login shells of the macOS terminal don't read .bashrc
+		if goos == "darwin" {
+			return profile{name, filepath.Join(home, ".bash_profile")}, nil
+		}
+		return profile{name, filepath.Join(home, ".bashrc")}, nil
+	case "zsh":
+		dir := os.Getenv("ZDOTDIR")
+		if dir == "" {
+			dir = home
+		}
+		return profile{name, filepath.Join(dir, ".zshrc")}, nil
+	case "fish":
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			dir = filepath.Join(home, ".config")
+		}
+		return profile{name, filepath.Join(dir, "fish", "config.fish")}, nil
+	case "", ".":
+		return profile{}, errors.New("cannot detect your shell, $SHELL is not set")
+	default:
+		return profile{}, errors.Errorf("not changing the profile of the unsupported shell %q, add the krew bin directory to PATH manually", name)
+	}
+}
+
+// pathBlock returns the marked block of lines that adds binDir to PATH.
+func (p profile) pathBlock(binDir string) (string, error) {
+	if strings.ContainsAny(binDir, "\"$`\\\n") {
+		return "", errors.Errorf("not writing the bin directory %q with special characters to a shell profile, add it to PATH manually", binDir)
+	}
+	var line string
+	if p.shell == "fish" {
+		line = fmt.Sprintf("set -gx PATH $PATH %q", binDir)
+	} else {
+		line = fmt.Sprintf("export PATH=\"%s:$PATH\"", binDir)
+	}
+	return pathBlockBegin + "\n" + line + "\n" + pathBlockEnd + "\n", nil
+}
+
+// writePathBlock adds the block to the end of the file, or replaces the block
+// that is already in the file. It returns false if the file already had the
+// same block.
+func writePathBlock(file, block string) (bool, error) {
+	fi, err := os.Stat(file)
+	if err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "failed to read %s", file)
+	}
+	if err == nil && !fi.Mode().IsRegular() {
+		return false, errors.Errorf("not changing %s, it is not a regular file", file)
+	}
+	var content string
+	if err == nil {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to read %s", file)
+		}
+		content = string(b)
+	}
+
+	var updated string
+	begin := strings.Index(content, pathBlockBegin)
+	end := strings.Index(content, pathBlockEnd)
+	switch {
+	case begin < 0 && end < 0:
+		updated = content
+		if updated != "" && !strings.HasSuffix(updated, "\n") {
+			updated += "\n"
+		}
+		updated += block
+	case begin >= 0 && end > begin && strings.LastIndex(content, pathBlockBegin) == begin:
+		rest := content[end+len(pathBlockEnd):]
+		rest = strings.TrimPrefix(rest, "\n")
+		updated = content[:begin] + block + rest
+	default:
+		return false, errors.Errorf("not changing %s, its krew PATH block is incomplete or duplicated, fix it manually", file)
+	}
+	if updated == content {
+		return false, nil
+	}
+
+	mode := os.FileMode(0644)
+	if fi != nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return false, errors.Wrapf(err, "failed to create the directory of %s", file)
+	}
+	klog.V(1).Infof("Writing krew PATH block to %s", file)
+	if err := ioutil.WriteFile(file, []byte(updated), mode); err != nil {
+		return false, errors.Wrapf(err, "failed to write %s", file)
+	}
+	return true, nil
+}
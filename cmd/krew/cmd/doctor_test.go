@@ -0,0 +1,134 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func Test_isInPath(t *testing.T) {
+	pathEnv := strings.Join([]string{"/usr/bin", "/home/user/.krew/bin/", ""}, string(os.PathListSeparator))
+	if !isInPath("/home/user/.krew/bin", pathEnv) {
+		t.Error("expected the bin directory to be found in PATH")
+	}
+	if isInPath("/home/user/.krew", pathEnv) {
+		t.Error("expected the parent directory not to be found in PATH")
+	}
+}
+
+func Test_shellProfile(t *testing.T) {
+	defer os.Unsetenv("ZDOTDIR")
+	os.Unsetenv("ZDOTDIR")
+
+	tests := []struct {
+		shell, goos string
+		want        string
+		shouldErr   bool
+	}{
+		{shell: "/bin/bash", goos: "linux", want: "/home/user/.bashrc"},
+		{shell: "/bin/bash", goos: "darwin", want: "/home/user/.bash_profile"},
+		{shell: "/usr/bin/zsh", goos: "linux", want: "/home/user/.zshrc"},
+		{shell: "/bin/tcsh", goos: "linux", shouldErr: true},
+		{shell: "", goos: "linux", shouldErr: true},
+	}
+	for _, tt := range tests {
+		got, err := shellProfile(tt.shell, "/home/user", tt.goos)
+		if (err != nil) != tt.shouldErr {
+			t.Errorf("shellProfile(%q) error = %v, shouldErr %v", tt.shell, err, tt.shouldErr)
+			continue
+		}
+		if got.file != tt.want {
+			t.Errorf("shellProfile(%q) = %q, expected %q", tt.shell, got.file, tt.want)
+		}
+	}
+}
+
+func Test_writePathBlock(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	file := tmpDir.Path(".bashrc")
+	tmpDir.Write(".bashrc", []byte("alias k=kubectl"))
+
+	block, err := profile{shell: "bash"}.pathBlock("/home/user/.krew/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wantChanged := range []bool{true, false} {
+		changed, err := writePathBlock(file, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("run %d: changed = %v, expected %v", i, changed, wantChanged)
+		}
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "alias k=kubectl\n" + pathBlockBegin + "\nexport PATH=\"/home/user/.krew/bin:$PATH\"\n" + pathBlockEnd + "\n"
+	if string(b) != expected {
+		t.Errorf("got profile:\n%s\nexpected:\n%s", b, expected)
+	}
+
+	// an existing block is replaced in place
+	newBlock, err := profile{shell: "bash"}.pathBlock("/opt/krew/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write(".bashrc", []byte(string(b)+"alias kk=kubectl\n"))
+	if _, err := writePathBlock(file, newBlock); err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "alias k=kubectl\n" + newBlock + "alias kk=kubectl\n"; string(b) != expected {
+		t.Errorf("got profile:\n%s\nexpected:\n%s", b, expected)
+	}
+}
+
+func Test_writePathBlock_refuses(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	block, err := profile{shell: "bash"}.pathBlock("/home/user/.krew/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// incomplete block
+	tmpDir.Write(".bashrc", []byte(pathBlockBegin+"\nexport PATH=/somewhere:$PATH\n"))
+	if _, err := writePathBlock(tmpDir.Path(".bashrc"), block); err == nil {
+		t.Error("expected an error for an incomplete block")
+	}
+
+	// not a regular file
+	if err := os.MkdirAll(tmpDir.Path(".zshrc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writePathBlock(tmpDir.Path(".zshrc"), block); err == nil {
+		t.Error("expected an error for a directory")
+	}
+
+	if _, err := (profile{shell: "bash"}).pathBlock("/home/$USER/bin"); err == nil {
+		t.Error("expected an error for a bin directory with special characters")
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func init() {
+	var to *string
+	var allPlatforms *bool
+
+	// downloadCmd represents the download command
+	downloadCmd := &cobra.Command{
+		Use:   "download",
+		Short: "Download the archives of plugins without installing them",
+		Long: `Download and verify the archives of plugins from the index without
+installing them, e.g. to install them later with --archive on a machine
+without network access.
+
+The archives are written to DIR/PLUGIN/OS-ARCH/, named like in their uri.
+
+Examples:
+  To download the archive of a plugin for this platform, run:
+    kubectl krew download NAME --to=DIR
+
+  To download the archives of a plugin for every platform it supports, e.g.
+  to build an offline mirror, run:
+    kubectl krew download NAME --all-platforms --to=DIR
+
+Remarks:
+  With --all-platforms, the platforms of the manifest are enumerated for the
+  common os/arch pairs:
+    ` + knownPlatformsList() + `
+  Platforms of the manifest that match none of them are skipped with a
+  warning. An archive shared by several platforms is downloaded once.
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *to == "" {
+				return errors.New("--to must be specified")
+			}
+			envs := []installation.OSArchPair{installation.OSArch()}
+			if *allPlatforms {
+				envs = installation.KnownOSArchPairs()
+			}
+			hosts, err := downloadHostRemap()
+			if err != nil {
+				return err
+			}
+			fetcher, err := httpFetcher()
+			if err != nil {
+				return err
+			}
+
+			var failed []string
+			var returnErr error
+			fail := func(name string, err error) {
+				if returnErr == nil {
+					returnErr = withPlugin(name, err)
+				}
+				failed = append(failed, name)
+			}
+			for _, name := range args {
+				plugin, err := indexscanner.LoadPluginByName(paths.IndexPluginsPath(), name)
+				if os.IsNotExist(err) {
+					fail(name, withExitCode(exitNotFound, errors.Errorf("plugin %q does not exist in the plugin index%s", name, didYouMean(paths.IndexPluginsPath(), name))))
+					continue
+				} else if err != nil {
+					fail(name, errors.Wrapf(err, "failed to load the plugin manifest for plugin %s", name))
+					continue
+				}
+
+				artifacts, unmatched, err := platformArtifacts(plugin, envs, hosts)
+				if err != nil {
+					fail(plugin.Name, err)
+					continue
+				}
+				if *allPlatforms {
+					for _, i := range unmatched {
+						klog.Warningf("Skipping platform %d of plugin %s, it matches none of the known platforms", i+1, plugin.Name)
+					}
+				}
+				if len(artifacts) == 0 {
+					fail(plugin.Name, withExitCode(exitNotFound, errors.Errorf("plugin %q does not offer installation for %s", plugin.Name, envs[0])))
+					continue
+				}
+
+				fmt.Fprintf(os.Stderr, "Downloading plugin: %s\n", plugin.Name)
+				files, err := downloadArtifacts(artifacts, filepath.Join(*to, plugin.Name), fetcher, downloadCache())
+				for _, f := range files {
+					fmt.Fprintln(os.Stdout, f)
+				}
+				if err != nil {
+					fail(plugin.Name, err)
+				}
+			}
+			if len(failed) > 0 {
+				return errors.Wrapf(returnErr, "failed to download some plugins: %+v", failed)
+			}
+			return nil
+		},
+		PreRunE: checkIndex,
+	}
+
+	to = downloadCmd.Flags().String("to", "", "directory to write the archives to")
+	allPlatforms = downloadCmd.Flags().Bool("all-platforms", false, "download the archives for every platform of the plugins, not only for this one")
+	rootCmd.AddCommand(downloadCmd)
+}
+
+// knownPlatformsList returns the known os/arch pairs for help texts.
+func knownPlatformsList() string {
+	var s []string
+	for _, env := range installation.KnownOSArchPairs() {
+		s = append(s, env.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+// platformArtifact is the download of a plugin for an os/arch pair.
+type platformArtifact struct {
+	env    installation.OSArchPair
+	uri    string
+	sha256 string
+}
+
+// platformArtifacts returns the downloads of the plugin for each of the os/arch
+// pairs that one of its platforms is selected for, with the download host
+// remaps applied. It also returns the indexes of the platforms that are
+// selected for none of the pairs.
+func platformArtifacts(plugin index.Plugin, envs []installation.OSArchPair, hosts map[string]string) ([]platformArtifact, []int, error) {
+	matches, err := installation.PlatformsByOSArch(plugin.Spec.Platforms, envs)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to match the platforms of the plugin")
+	}
+	used := make(map[int]bool, len(plugin.Spec.Platforms))
+	var artifacts []platformArtifact
+	for _, env := range envs {
+		i, ok := matches[env]
+		if !ok {
+			continue
+		}
+		used[i] = true
+		platform := plugin.Spec.Platforms[i]
+		uri, err := installation.ExpandURIFor(platform.URI, env, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if uri, err = installation.RewriteDownloadHost(uri, hosts); err != nil {
+			return nil, nil, err
+		}
+		artifacts = append(artifacts, platformArtifact{env: env, uri: uri, sha256: platform.Sha256})
+	}
+	var unmatched []int
+	for i := range plugin.Spec.Platforms {
+		if !used[i] {
+			unmatched = append(unmatched, i)
+		}
+	}
+	return artifacts, unmatched, nil
+}
+
+// downloadArtifacts downloads and verifies the artifacts into dir/OS-ARCH/ and
+// returns the paths of the written files. An artifact that is the same for
+// several os/arch pairs is copied instead of downloaded again (or retried if it
+// failed). Artifacts that fail to download are skipped, and the first error is
+// returned.
+func downloadArtifacts(artifacts []platformArtifact, dir string, fetcher download.Fetcher, cache *download.Cache) ([]string, error) {
+	downloaded := make(map[string]string, len(artifacts)) // uri+sha256 -> file
+	failed := make(map[string]error)
+	var files []string
+	var firstErr error
+	for _, a := range artifacts {
+		dst := filepath.Join(dir, a.env.OS+"-"+a.env.Arch, artifactFileName(a.uri))
+		err := func() error {
+			if src, ok := downloaded[a.uri+a.sha256]; ok {
+				return copyFile(src, dst)
+			}
+			if err, ok := failed[a.uri+a.sha256]; ok {
+				return err
+			}
+			cached := cache != nil && cache.Has(a.sha256)
+			if isOffline() && !cached {
+				return errors.Errorf("cannot download %q in offline mode", a.uri)
+			}
+			f := fetcher
+			if cache != nil {
+				f = cache.Fetcher(fetcher, a.sha256)
+			}
+			klog.V(1).Infof("Downloading %s for %s to %s", a.uri, a.env, dst)
+			err := download.NewDownloader(download.NewSha256Verifier(a.sha256), f).GetFile(a.uri, dst)
+			if err != nil {
+				failed[a.uri+a.sha256] = err
+			}
+			return err
+		}()
+		if err != nil {
+			klog.Warningf("Failed to download the archive for %s: %v", a.env, err)
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to download the archive for %s", a.env)
+			}
+			continue
+		}
+		downloaded[a.uri+a.sha256] = dst
+		files = append(files, dst)
+	}
+	return files, firstErr
+}
+
+// artifactFileName returns the name of the file a download is written to,
+// the last element of the path of its uri.
+func artifactFileName(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "archive"
+	}
+	name := path.Base(u.Path)
+	if download.IsOCIArtifact(uri) {
+		// oci://registry/repository:tag or @digest
+		name = strings.SplitN(strings.SplitN(name, "@", 2)[0], ":", 2)[0]
+	}
+	if name == "." || name == "/" || name == "" {
+		return "archive"
+	}
+	return name
+}
+
+// copyFile copies the file at src to dst.
+func copyFile(src, dst string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", src)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %q", dst)
+	}
+	return errors.Wrapf(ioutil.WriteFile(dst, b, 0644), "failed to write %q", dst)
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func Test_platformArtifacts(t *testing.T) {
+	plugin := testutil.NewPlugin().WithPlatforms(
+		testutil.NewPlatform().WithOSArch("darwin", "arm64").WithURI("https://github.com/foo/foo-darwin.tar.gz").WithSHA256("a").V(),
+		testutil.NewPlatform().WithOS("linux").WithURI("https://github.com/foo/foo-${KREW_ARCH}.tar.gz").WithSHA256("b").V(),
+		testutil.NewPlatform().WithOSArch("plan9", "amd64").WithURI("https://github.com/foo/foo-plan9.tar.gz").WithSHA256("c").V(),
+	).V()
+	envs := []installation.OSArchPair{{OS: "darwin", Arch: "arm64"}, {OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}, {OS: "windows", Arch: "amd64"}}
+
+	got, unmatched, err := platformArtifacts(plugin, envs, map[string]string{"github.com": "mirror.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []platformArtifact{
+		{env: envs[0], uri: "https://mirror.internal/foo/foo-darwin.tar.gz", sha256: "a"},
+		{env: envs[1], uri: "https://mirror.internal/foo/foo-amd64.tar.gz", sha256: "b"},
+		{env: envs[2], uri: "https://mirror.internal/foo/foo-arm64.tar.gz", sha256: "b"},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(platformArtifact{})); diff != "" {
+		t.Errorf("platformArtifacts() mismatch:\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{2}, unmatched); diff != "" {
+		t.Errorf("platformArtifacts() unmatched platforms mismatch:\n%s", diff)
+	}
+}
+
+func Test_downloadArtifacts(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func() { offline.Changed = false; offline.Value.Set("false") }()
+	offline.Changed = true
+	offline.Value.Set("false")
+
+	archive := filepath.Join("..", "..", "..", "integration_test", "testdata", "foo.tar.gz")
+	sum := "354bad230cdd0966fc8c919476c4e6c7f2078b04a6ff7dead6a811cdc101d31e"
+	artifacts := []platformArtifact{
+		{env: installation.OSArchPair{OS: "linux", Arch: "amd64"}, uri: "https://example.com/foo.tar.gz", sha256: sum},
+		{env: installation.OSArchPair{OS: "linux", Arch: "arm64"}, uri: "https://example.com/foo.tar.gz", sha256: sum},
+		{env: installation.OSArchPair{OS: "darwin", Arch: "amd64"}, uri: "https://example.com/foo-darwin.tar.gz", sha256: strings.Repeat("0", 64)},
+	}
+
+	files, err := downloadArtifacts(artifacts, tmpDir.Root(), download.NewFileFetcher(archive), nil)
+	if err == nil {
+		t.Error("expected an error for the archive with a checksum mismatch")
+	}
+	want := []string{tmpDir.Path("linux-amd64/foo.tar.gz"), tmpDir.Path("linux-arm64/foo.tar.gz")}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Errorf("downloadArtifacts() files mismatch:\n%s", diff)
+	}
+	for _, f := range want {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("expected archive %s to be downloaded: %v", f, err)
+		}
+	}
+	if _, err := os.Stat(tmpDir.Path("darwin-amd64")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written for the unverified archive, got err=%v", err)
+	}
+}
+
+func Test_artifactFileName(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/foo/releases/download/v1.0.0/foo_linux_amd64.tar.gz?x=1": "foo_linux_amd64.tar.gz",
+		"oci://registry.example.com/plugins/foo:v1.0.0":                              "foo",
+		"oci://registry.example.com/plugins/foo@sha256:abc":                          "foo",
+		"https://example.com/": "archive",
+	}
+	for uri, want := range tests {
+		if got := artifactFileName(uri); got != want {
+			t.Errorf("artifactFileName(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
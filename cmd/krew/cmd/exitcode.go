@@ -0,0 +1,153 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+
+	"sigs.k8s.io/krew/internal/index/validation"
+	"sigs.k8s.io/krew/internal/installation"
+)
+
+// Exit codes of krew commands, see the help text of the root command.
+const (
+	exitGeneric     = 1 // any failure not covered below
+	exitUpToDate    = 2 // the plugin is already installed or upgraded
+	exitNotFound    = 3 // the plugin is not in the index or not installed
+	exitNetwork     = 4 // a download or the index update failed
+	exitInvalidSpec = 5 // a plugin manifest or receipt is invalid
+
+	exitUpgradesAvailable = 6 // "upgrade --check" found plugins to upgrade
+)
+
+// categories of errors in the output of --json-errors, by exit code
+var exitCategories = map[int]string{
+	exitGeneric:           "generic",
+	exitUpToDate:          "up-to-date",
+	exitNotFound:          "not-found",
+	exitNetwork:           "network",
+	exitInvalidSpec:       "invalid-manifest",
+	exitUpgradesAvailable: "upgrades-available",
+}
+
+// exitCodeHelp documents the exit codes in the help text.
+const exitCodeHelp = `
+Exit codes:
+  0  success
+  1  generic failure
+  2  the plugin is already installed or on the newest version
+  3  the plugin was not found in the index or is not installed
+  4  network failure (download or index update)
+  5  invalid plugin manifest
+  6  upgrades are available ("upgrade --check")`
+
+// codedError is an error with the exit code krew exits with for it.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e codedError) Error() string { return e.err.Error() }
+func (e codedError) Cause() error  { return e.err }
+
+// withExitCode sets the exit code for err.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return codedError{code: code, err: err}
+}
+
+// pluginError is an error that happened for a specific plugin.
+type pluginError struct {
+	plugin string
+	err    error
+}
+
+func (e pluginError) Error() string { return e.err.Error() }
+func (e pluginError) Cause() error  { return e.err }
+
+// withPlugin records that err happened for the plugin with the given name.
+func withPlugin(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return pluginError{plugin: name, err: err}
+}
+
+// errorPlugin returns the name of the plugin err happened for, or "" if it is
+// not specific to a plugin.
+func errorPlugin(err error) string {
+	for err != nil {
+		if e, ok := err.(pluginError); ok {
+			return e.plugin
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = causer.Cause()
+	}
+	return ""
+}
+
+// jsonError is the structured form of an error written with --json-errors.
+type jsonError struct {
+	Code     int    `json:"code"`
+	Category string `json:"category"`
+	Plugin   string `json:"plugin,omitempty"`
+	Message  string `json:"message"`
+}
+
+// writeJSONError writes err as a single-line JSON object to w.
+func writeJSONError(w io.Writer, err error) error {
+	code := exitCode(err)
+	return json.NewEncoder(w).Encode(jsonError{
+		Code:     code,
+		Category: exitCategories[code],
+		Plugin:   errorPlugin(err),
+		Message:  err.Error(),
+	})
+}
+
+// exitCode returns the exit code for err by looking for the outermost error
+// with a known category in its chain of causes.
+func exitCode(err error) int {
+	for err != nil {
+		switch e := err.(type) {
+		case codedError:
+			return e.code
+		case validation.InvalidError, validation.APIVersionError:
+			return exitInvalidSpec
+		case net.Error:
+			return exitNetwork
+		}
+		switch err {
+		case installation.ErrIsAlreadyInstalled, installation.ErrIsAlreadyUpgraded:
+			return exitUpToDate
+		case installation.ErrIsNotInstalled:
+			return exitNotFound
+		}
+
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = causer.Cause()
+	}
+	return exitGeneric
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/index/validation"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func Test_exitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"generic", errors.New("something failed"), exitGeneric},
+		{"already upgraded", errors.Wrap(installation.ErrIsAlreadyUpgraded, "failed to upgrade plugin"), exitUpToDate},
+		{"not installed", errors.Wrap(errors.Wrap(installation.ErrIsNotInstalled, "failed"), "some failed"), exitNotFound},
+		{"explicit code", errors.Wrap(withExitCode(exitNotFound, errors.New("not in index")), "failed"), exitNotFound},
+		{"outermost code wins", withExitCode(exitNetwork, errors.Wrap(installation.ErrIsNotInstalled, "failed")), exitNetwork},
+		{"network", errors.Wrap(&url.Error{Op: "Get", URL: "https://example.com", Err: timeoutError{}}, "failed to download"), exitNetwork},
+		{"invalid manifest", errors.Wrap(validation.ValidatePlugin("foo", testutil.NewPlugin().WithName("foo").WithShortDescription("").V()), "validation"), exitInvalidSpec},
+		{"plugin error", withPlugin("foo", errors.Wrap(installation.ErrIsNotInstalled, "failed")), exitNotFound},
+		{"unsupported apiVersion", errors.Wrap(validation.APIVersionError{APIVersion: "example.com/v1"}, "load"), exitInvalidSpec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.expected {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_writeJSONError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "generic",
+			err:      errors.New("something failed"),
+			expected: `{"code":1,"category":"generic","message":"something failed"}`,
+		},
+		{
+			name:     "plugin not found",
+			err:      errors.Wrap(withPlugin("foo", withExitCode(exitNotFound, errors.New("not in index"))), "failed to install some plugins"),
+			expected: `{"code":3,"category":"not-found","plugin":"foo","message":"failed to install some plugins: not in index"}`,
+		},
+		{
+			name:     "upgrades available",
+			err:      withExitCode(exitUpgradesAvailable, errors.New("1 plugin(s) have upgrades available")),
+			expected: `{"code":6,"category":"upgrades-available","message":"1 plugin(s) have upgrades available"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeJSONError(&buf, tt.err); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.expected+"\n" {
+				t.Errorf("writeJSONError() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/installation"
+)
+
+// hookEnv is a shell command that is run after a plugin is installed,
+// upgraded or uninstalled successfully. It overrides the hook config setting.
+const hookEnv = "KREW_HOOK"
+
+// hookStrictEnv makes a failing hook fail the krew command if set to a true
+// value, and overrides the hookStrict config setting. Otherwise the failure
+// is only logged.
+const hookStrictEnv = "KREW_HOOK_STRICT"
+
+// events the hook is run for, passed in KREW_HOOK_EVENT
+const (
+	hookInstall   = "install"
+	hookUpgrade   = "upgrade"
+	hookUninstall = "uninstall"
+)
+
+// runHook runs the hook command, if one is set, for the event on the plugin
+// at the given version. The hook gets the event, plugin name and version in
+// the KREW_HOOK_EVENT, KREW_HOOK_PLUGIN and KREW_HOOK_VERSION environment
+// variables. Its output is written to stderr.
+func runHook(event, plugin, version string) error {
+	command := os.Getenv(hookEnv)
+	if command == "" {
+		command = cfg.Hook
+	}
+	if command == "" {
+		return nil
+	}
+
+	var c *exec.Cmd
+	if installation.IsWindows() {
+		c = exec.Command("cmd", "/C", command)
+	} else {
+		c = exec.Command("sh", "-c", command)
+	}
+	c.Env = append(os.Environ(),
+		"KREW_HOOK_EVENT="+event,
+		"KREW_HOOK_PLUGIN="+plugin,
+		"KREW_HOOK_VERSION="+version)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+
+	klog.V(1).Infof("Running %s hook for plugin %s", event, plugin)
+	if err := c.Run(); err != nil {
+		err = errors.Wrapf(err, "%s hook for plugin %s failed", event, plugin)
+		if hookStrict() {
+			return err
+		}
+		klog.Warningf("%v", err)
+	}
+	return nil
+}
+
+// hookStrict reports whether a failing hook fails the command, from
+// hookStrictEnv or else the config file.
+func hookStrict() bool {
+	if strict, err := strconv.ParseBool(os.Getenv(hookStrictEnv)); err == nil {
+		return strict
+	}
+	return cfg.HookStrict
+}
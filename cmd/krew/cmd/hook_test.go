@@ -0,0 +1,101 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func Test_runHook(t *testing.T) {
+	if installation.IsWindows() {
+		t.Skip("hook test uses a POSIX shell")
+	}
+	defer os.Unsetenv(hookEnv)
+	defer os.Unsetenv(hookStrictEnv)
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	os.Unsetenv(hookEnv)
+	if err := runHook(hookInstall, "foo", "v1.0.0"); err != nil {
+		t.Fatalf("expected no error without a hook: %v", err)
+	}
+
+	out := tmpDir.Path("out")
+	os.Setenv(hookEnv, `echo "$KREW_HOOK_EVENT $KREW_HOOK_PLUGIN $KREW_HOOK_VERSION" > `+out)
+	if err := runHook(hookUpgrade, "foo", "v1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "upgrade foo v1.2.0\n"; got != want {
+		t.Errorf("hook got %q, want %q", got, want)
+	}
+
+	os.Setenv(hookEnv, "exit 3")
+	if err := runHook(hookUninstall, "foo", "v1.2.0"); err != nil {
+		t.Errorf("expected a failing hook to only be logged: %v", err)
+	}
+	os.Setenv(hookStrictEnv, "1")
+	if err := runHook(hookUninstall, "foo", "v1.2.0"); err == nil {
+		t.Error("expected a failing hook to return an error with " + hookStrictEnv)
+	}
+}
+
+func Test_runHook_config(t *testing.T) {
+	if installation.IsWindows() {
+		t.Skip("hook test uses a POSIX shell")
+	}
+	defer func(c config) { cfg = c }(cfg)
+	defer os.Unsetenv(hookEnv)
+	defer os.Unsetenv(hookStrictEnv)
+	os.Unsetenv(hookEnv)
+	os.Unsetenv(hookStrictEnv)
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	out := tmpDir.Path("out")
+	cfg = config{Hook: `echo "$KREW_HOOK_EVENT $KREW_HOOK_PLUGIN" > ` + out}
+	if err := runHook(hookInstall, "foo", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "install foo\n"; got != want {
+		t.Errorf("hook from config got %q, want %q", got, want)
+	}
+
+	cfg = config{Hook: "exit 3", HookStrict: true}
+	if err := runHook(hookUninstall, "foo", "v1.0.0"); err == nil {
+		t.Error("expected a failing hook to return an error with hookStrict in the config")
+	}
+	os.Setenv(hookStrictEnv, "0")
+	if err := runHook(hookUninstall, "foo", "v1.0.0"); err != nil {
+		t.Errorf("expected %s=0 to override the config: %v", hookStrictEnv, err)
+	}
+	os.Setenv(hookEnv, "true")
+	os.Unsetenv(hookStrictEnv)
+	if err := runHook(hookUninstall, "foo", "v1.0.0"); err != nil {
+		t.Errorf("expected %s to override the hook in the config: %v", hookEnv, err)
+	}
+}
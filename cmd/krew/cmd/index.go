@@ -0,0 +1,381 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/gitutil"
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// indexHealthWorkers is the number of concurrent requests made by
+// "index health --check-urls".
+const indexHealthWorkers = 8
+
+// indexProblem is a problem found in a plugin manifest of the index.
+type indexProblem struct {
+	plugin  string
+	problem string
+}
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Perform operations on the plugin index",
+	Long: `Perform operations on the local copy of the plugin index.
+
+Examples:
+  To show the plugin index with its number of plugins, run:
+    kubectl krew index list
+
+  To check the plugin manifests of the index for problems, run:
+    kubectl krew index health
+
+  To show the plugins that changed in the last update of the index, run:
+    kubectl krew index diff`,
+	Args: cobra.NoArgs,
+}
+
+// indexSummary is a row of "index list".
+type indexSummary struct {
+	Name      string `json:"name"`
+	Plugins   int    `json:"plugins"`
+	Installed int    `json:"installed"`
+}
+
+func init() {
+	var checkURLs *bool
+	var output, diffIndex *string
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the plugin indexes with their number of plugins",
+		Long: `List the plugin indexes with the number of valid plugin manifests in the
+local copy of each index, and the number of installed plugins that were
+installed from it.
+
+Use -o json to print the list as JSON.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *output != "" && *output != "json" {
+				return errors.Errorf("unsupported output format %q, must be: json", *output)
+			}
+			plugins, _, err := scanIndexManifests(paths.IndexPluginsPath())
+			if err != nil {
+				return err
+			}
+			receipts, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
+			if err != nil {
+				return errors.Wrap(err, "failed to find all installed versions")
+			}
+			summaries := []indexSummary{summarizeIndex(constants.DefaultIndexName, plugins, receipts)}
+
+			if *output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return errors.Wrap(enc.Encode(summaries), "failed to write the list of indexes")
+			}
+			var rows [][]string
+			for _, s := range summaries {
+				rows = append(rows, []string{s.Name, strconv.Itoa(s.Plugins), strconv.Itoa(s.Installed)})
+			}
+			return printTable(os.Stdout, []string{"INDEX", "PLUGINS", "INSTALLED"}, rows)
+		},
+		PreRunE: checkIndex,
+	}
+
+	output = listCmd.Flags().StringP("output", "o", "", "output format, one of: json")
+
+	healthCmd := &cobra.Command{
+		Use:   "health [NAME]",
+		Short: "Check the plugin manifests of the index for problems",
+		Long: `Check all plugin manifests in the local copy of the plugin index and report
+the ones that fail validation.
+
+With --check-urls, the download URLs of all platforms are also requested to
+find the ones that are no longer available. This accesses the network.
+
+The command fails if any problems are found.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkIndexName(args); err != nil {
+				return err
+			}
+			if *checkURLs && isOffline() {
+				return errors.New("--check-urls needs network access, which is blocked by offline mode")
+			}
+
+			plugins, problems, err := scanIndexManifests(paths.IndexPluginsPath())
+			if err != nil {
+				return err
+			}
+			if *checkURLs {
+				fetcher, err := httpFetcher()
+				if err != nil {
+					return err
+				}
+				problems = append(problems, checkPluginURIs(plugins, fetcher, indexHealthWorkers)...)
+			}
+
+			if len(problems) == 0 {
+				fmt.Fprintf(os.Stderr, "No problems found in %d plugin manifests.\n", len(plugins))
+				return nil
+			}
+			sort.SliceStable(problems, func(i, j int) bool { return problems[i].plugin < problems[j].plugin })
+			var rows [][]string
+			for _, p := range problems {
+				rows = append(rows, []string{p.plugin, p.problem})
+			}
+			if err := printTable(os.Stdout, []string{"PLUGIN", "PROBLEM"}, rows); err != nil {
+				return err
+			}
+			return errors.Errorf("found %d problems in the plugin index", len(problems))
+		},
+		PreRunE: checkIndex,
+	}
+
+	checkURLs = healthCmd.Flags().Bool("check-urls", false, "also check that the download URLs of the plugins are available")
+
+	diffCmd := &cobra.Command{
+		Use:   "diff [PLUGIN]...",
+		Short: "Show the plugins that changed in the last update of the index",
+		Long: `Show the plugins that were added, removed or changed in the last update of
+the local copy of the plugin index, with their old and new versions.
+
+The commit of the index before each update is recorded by "krew update" (and
+the commands that update the index), so this command works after the index
+has been updated at least once. It does not access the network.
+
+To only show some plugins, specify their names. Use --index to choose the
+index.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *diffIndex != "" {
+				if err := checkIndexName([]string{*diffIndex}); err != nil {
+					return err
+				}
+			}
+			if err := checkIndexHistory("index diff"); err != nil {
+				return err
+			}
+			from, err := gitutil.ResolveRef(paths.IndexPath(), previousIndexRef)
+			if err != nil {
+				klog.V(2).Infof("%v", err)
+				return errors.New(`no previous update of the plugin index is recorded, run "kubectl krew update" first`)
+			}
+			to, err := gitutil.Head(paths.IndexPath())
+			if err != nil {
+				return err
+			}
+			changes, err := indexscanner.DiffPlugins(paths.IndexPath(), from, to)
+			if err != nil {
+				return err
+			}
+			changes = filterPluginChanges(changes, args)
+			if len(changes) == 0 && len(args) > 0 {
+				fmt.Fprintln(os.Stderr, "None of the specified plugins changed in the last update of the plugin index.")
+				return nil
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(os.Stderr, "No plugins changed in the last update of the plugin index.")
+				return nil
+			}
+			return printTable(os.Stdout, []string{"PLUGIN", "CHANGE", "VERSION"}, pluginChangeRows(changes))
+		},
+		PreRunE: checkIndex,
+	}
+
+	diffIndex = diffCmd.Flags().String("index", "", "the index to show the changes of, "+constants.DefaultIndexName+" by default")
+
+	indexCmd.AddCommand(listCmd, healthCmd, diffCmd)
+	rootCmd.AddCommand(indexCmd)
+}
+
+// checkIndexName fails if the index name in args is not the default index,
+// the only one supported.
+func checkIndexName(args []string) error {
+	if len(args) == 1 && args[0] != constants.DefaultIndexName {
+		return errors.Errorf("index %q does not exist, only the %q index is supported", args[0], constants.DefaultIndexName)
+	}
+	return nil
+}
+
+// summarizeIndex counts the plugins of the named index and the receipts of
+// plugins installed from it.
+func summarizeIndex(name string, plugins []index.Plugin, receipts []index.Receipt) indexSummary {
+	return indexSummary{
+		Name:      name,
+		Plugins:   len(plugins),
+		Installed: len(filterBySourceIndex(receipts, name)),
+	}
+}
+
+// pluginChangeRows formats the plugin changes as table rows with the version
+// delta of each plugin.
+func pluginChangeRows(changes []indexscanner.PluginChange) [][]string {
+	rows := make([][]string, 0, len(changes))
+	for _, c := range changes {
+		version := c.NewVersion
+		switch {
+		case c.Change == indexscanner.PluginRemoved:
+			version = c.OldVersion
+		case c.Change == indexscanner.PluginChanged && c.OldVersion != c.NewVersion:
+			version = fmt.Sprintf("%s -> %s", orUnknown(c.OldVersion), orUnknown(c.NewVersion))
+		}
+		rows = append(rows, []string{c.Name, c.Change, orUnknown(version)})
+	}
+	return rows
+}
+
+// filterPluginChanges returns the changes of the named plugins, or all changes
+// if no names are given.
+func filterPluginChanges(changes []indexscanner.PluginChange, names []string) []indexscanner.PluginChange {
+	if len(names) == 0 {
+		return changes
+	}
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+	var out []indexscanner.PluginChange
+	for _, c := range changes {
+		if want[c.Name] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// orUnknown returns "unknown" for an empty version.
+func orUnknown(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
+
+// scanIndexManifests loads all plugin manifests in the plugins directory of
+// the index. It returns the valid plugins and the problems of the manifests
+// that could not be loaded.
+func scanIndexManifests(pluginsDir string) ([]index.Plugin, []indexProblem, error) {
+	files, err := ioutil.ReadDir(pluginsDir)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read the plugin index")
+	}
+	var plugins []index.Plugin
+	var problems []indexProblem
+	for _, f := range files {
+		if !f.Mode().IsRegular() || filepath.Ext(f.Name()) != constants.ManifestExtension {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), constants.ManifestExtension)
+		p, err := indexscanner.LoadPluginByName(pluginsDir, name)
+		if err != nil {
+			problems = append(problems, indexProblem{plugin: name, problem: err.Error()})
+			continue
+		}
+		if p.Name != name {
+			problems = append(problems, indexProblem{plugin: name, problem: fmt.Sprintf("manifest file should be named %q for plugin %q", p.Name+constants.ManifestExtension, p.Name)})
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, problems, nil
+}
+
+// checkPluginURIs requests the download URIs of all platforms of the plugins
+// with the given number of workers and returns the ones that are not
+// available. URIs with variables are skipped, as they are resolved at install
+// time.
+func checkPluginURIs(plugins []index.Plugin, fetcher download.HTTPFetcher, workers int) []indexProblem {
+	type check struct{ plugin, uri string }
+	var checks []check
+	for _, p := range plugins {
+		for _, pl := range p.Spec.Platforms {
+			if strings.Contains(pl.URI, "${") {
+				klog.V(2).Infof("Not checking uri %q of plugin %s, it has variables", pl.URI, p.Name)
+				continue
+			}
+			if download.IsOCIArtifact(pl.URI) {
+				klog.V(2).Infof("Not checking uri %q of plugin %s, it is an OCI artifact", pl.URI, p.Name)
+				continue
+			}
+			checks = append(checks, check{plugin: p.Name, uri: pl.URI})
+		}
+	}
+
+	// results are stored by the index of the check to keep the order stable
+	results := make([]string, len(checks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = checkURI(checks[i].uri, fetcher)
+			}
+		}()
+	}
+	for i := range checks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var problems []indexProblem
+	for i, c := range checks {
+		if results[i] != "" {
+			problems = append(problems, indexProblem{plugin: c.plugin, problem: results[i]})
+		}
+	}
+	return problems
+}
+
+// checkURI describes the problem with the uri or returns an empty string if it
+// is available.
+func checkURI(uri string, fetcher download.HTTPFetcher) string {
+	klog.V(3).Infof("Checking uri %q", uri)
+	req, err := http.NewRequest(http.MethodHead, uri, nil)
+	if err != nil {
+		return fmt.Sprintf("uri %q is invalid: %v", uri, err)
+	}
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		return fmt.Sprintf("uri %q is unreachable: %v", uri, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Sprintf("uri %q returned HTTP %d", uri, resp.StatusCode)
+	}
+	return ""
+}
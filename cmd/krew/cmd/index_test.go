@@ -0,0 +1,158 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func Test_scanIndexManifests(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	b, err := yaml.Marshal(testutil.NewPlugin().WithName("valid").V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("valid"+constants.ManifestExtension, b)
+	b, err = yaml.Marshal(testutil.NewPlugin().WithName("invalid").WithShortDescription("").V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("invalid"+constants.ManifestExtension, b)
+	b, err = yaml.Marshal(testutil.NewPlugin().WithName("other").V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("misnamed"+constants.ManifestExtension, b)
+	tmpDir.Write("README.md", []byte("not a manifest"))
+
+	plugins, problems, err := scanIndexManifests(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 || plugins[0].Name != "valid" {
+		t.Errorf("expected only the valid plugin to be loaded, got %+v", plugins)
+	}
+	if len(problems) != 2 || problems[0].plugin != "invalid" || problems[1].plugin != "misnamed" {
+		t.Errorf("expected problems for the invalid and misnamed plugins, got %+v", problems)
+	}
+}
+
+func Test_checkPluginURIs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected HEAD request, got %s", r.Method)
+		}
+		if r.URL.Path != "/ok" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	plugins := []index.Plugin{
+		testutil.NewPlugin().WithName("a").WithPlatforms(
+			testutil.NewPlatform().WithURI(server.URL+"/ok").V(),
+			testutil.NewPlatform().WithURI(server.URL+"/missing").V(),
+		).V(),
+		testutil.NewPlugin().WithName("b").WithPlatforms(
+			testutil.NewPlatform().WithURI(server.URL + "/${KREW_OS}").V(),
+		).V(),
+		testutil.NewPlugin().WithName("c").WithPlatforms(
+			testutil.NewPlatform().WithURI(server.URL + "/gone").V(),
+		).V(),
+	}
+
+	problems := checkPluginURIs(plugins, download.HTTPFetcher{}, 2)
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %+v", problems)
+	}
+	if problems[0].plugin != "a" || !strings.Contains(problems[0].problem, "/missing") {
+		t.Errorf("unexpected first problem %+v", problems[0])
+	}
+	if problems[1].plugin != "c" || !strings.Contains(problems[1].problem, "HTTP 404") {
+		t.Errorf("unexpected second problem %+v", problems[1])
+	}
+}
+
+func Test_pluginChangeRows(t *testing.T) {
+	got := pluginChangeRows([]indexscanner.PluginChange{
+		{Name: "a", Change: indexscanner.PluginAdded, NewVersion: "v1.0.0"},
+		{Name: "b", Change: indexscanner.PluginRemoved, OldVersion: "v0.1.0"},
+		{Name: "c", Change: indexscanner.PluginChanged, OldVersion: "v1.0.0", NewVersion: "v2.0.0"},
+		{Name: "d", Change: indexscanner.PluginChanged, OldVersion: "v1.0.0", NewVersion: "v1.0.0"},
+		{Name: "e", Change: indexscanner.PluginChanged, OldVersion: "v1.0.0"},
+	})
+	want := [][]string{
+		{"a", "added", "v1.0.0"},
+		{"b", "removed", "v0.1.0"},
+		{"c", "changed", "v1.0.0 -> v2.0.0"},
+		{"d", "changed", "v1.0.0"},
+		{"e", "changed", "v1.0.0 -> unknown"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("pluginChangeRows() mismatch:\n%s", diff)
+	}
+}
+
+func Test_filterPluginChanges(t *testing.T) {
+	changes := []indexscanner.PluginChange{
+		{Name: "a", Change: indexscanner.PluginAdded},
+		{Name: "b", Change: indexscanner.PluginRemoved},
+		{Name: "c", Change: indexscanner.PluginChanged},
+	}
+	if diff := cmp.Diff(changes, filterPluginChanges(changes, nil)); diff != "" {
+		t.Errorf("filterPluginChanges() without names mismatch:\n%s", diff)
+	}
+	want := []indexscanner.PluginChange{changes[0], changes[2]}
+	if diff := cmp.Diff(want, filterPluginChanges(changes, []string{"c", "a", "z"})); diff != "" {
+		t.Errorf("filterPluginChanges() mismatch:\n%s", diff)
+	}
+	if got := filterPluginChanges(changes, []string{"z"}); len(got) != 0 {
+		t.Errorf("filterPluginChanges() = %v, want none", got)
+	}
+}
+
+func Test_summarizeIndex(t *testing.T) {
+	plugins := []index.Plugin{
+		testutil.NewPlugin().WithName("a").V(),
+		testutil.NewPlugin().WithName("b").V(),
+		testutil.NewPlugin().WithName("c").V(),
+	}
+	receipts := []index.Receipt{
+		receipt.New(testutil.NewPlugin().WithName("a").V(), constants.DefaultIndexName),
+		receipt.New(testutil.NewPlugin().WithName("b").V(), "other"),
+		receipt.New(testutil.NewPlugin().WithName("custom").V(), ""),
+		{Plugin: testutil.NewPlugin().WithName("c").V()}, // written before the source was recorded
+	}
+	got := summarizeIndex(constants.DefaultIndexName, plugins, receipts)
+	want := indexSummary{Name: constants.DefaultIndexName, Plugins: 3, Installed: 2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("summarizeIndex() mismatch (-want +got):\n%s", diff)
+	}
+}
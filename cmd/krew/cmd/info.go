@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -24,12 +25,29 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
 
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/index/validation"
 	"sigs.k8s.io/krew/internal/info"
 	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
 
+var (
+	dumpManifest *bool   // set to print the plugin manifest file from the index
+	downloadURL  *bool   // set to only print the download URL of the plugin
+	infoPlatform *string // OS/ARCH to resolve the download URL for
+	asManifest   *bool   // set to print a manifest skeleton of the installed plugin
+	showFiles    *bool   // set to list the files installed for the plugin
+	infoOutput   *string // output format of --files
+	jsonSchema   *bool   // set to print the JSON Schema of plugin manifests
+)
+
 // infoCmd represents the info command
 var infoCmd = &cobra.Command{
 	Use:   "info",
@@ -40,19 +58,109 @@ This command can be used to print information such as its download URL, last
 available version, platform availability and the caveats.
 
 Example:
-  kubectl krew info PLUGIN`,
+  kubectl krew info PLUGIN
+
+  To print the manifest file of the plugin exactly as it is in the index:
+    kubectl krew info PLUGIN --dump-manifest
+
+  To print only the URL the plugin is downloaded from (with --download-host
+  remaps applied), optionally for another platform:
+    kubectl krew info PLUGIN --download-url [--platform=OS/ARCH]
+
+  To generate a starter manifest from an installed plugin (e.g. one installed
+  with --link or --archive) for submitting it to an index:
+    kubectl krew info PLUGIN --as-manifest
+  Its uri and homepage are left blank to be filled in.
+
+  To list the files installed for the plugin with their absolute paths,
+  optionally as JSON:
+    kubectl krew info PLUGIN --files [-o json]
+
+  To print the JSON Schema of plugin manifests, e.g. to validate manifests in
+  an editor or CI:
+    kubectl krew info --json-schema`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if *jsonSchema {
+			if *dumpManifest || *downloadURL || *asManifest || *showFiles || *infoOutput != "" || *infoPlatform != "" {
+				return errors.New("--json-schema cannot be used with other flags")
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(validation.PluginSchema())
+		}
+		if *infoPlatform != "" && !*downloadURL {
+			return errors.New("--platform can only be used with --download-url")
+		}
+		if *infoOutput != "" && !*showFiles {
+			return errors.New("--output can only be used with --files")
+		}
+		lock, err := lockIndexShared()
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		if *showFiles {
+			if *dumpManifest || *downloadURL || *asManifest {
+				return errors.New("--files cannot be used with --dump-manifest, --download-url or --as-manifest")
+			}
+			return printPluginFiles(os.Stdout, args[0], *infoOutput)
+		}
+
+		if *asManifest {
+			if *dumpManifest || *downloadURL {
+				return errors.New("--as-manifest cannot be used with --dump-manifest or --download-url")
+			}
+			return printManifestSkeleton(os.Stdout, args[0])
+		}
+
+		if *dumpManifest {
+			b, err := indexscanner.ReadPluginFileByName(paths.IndexPluginsPath(), args[0])
+			if os.IsNotExist(err) {
+				if err := checkIndexPlugins(cmd, args); err != nil {
+					return err
+				}
+				return withExitCode(exitNotFound, errors.Errorf("plugin %q not found in the index%s", args[0], didYouMean(paths.IndexPluginsPath(), args[0])))
+			} else if err != nil {
+				return errors.Wrap(err, "failed to read plugin manifest")
+			}
+			_, err = os.Stdout.Write(b)
+			return err
+		}
+
 		plugin, err := info.LoadManifestFromReceiptOrIndex(paths, args[0])
 		if os.IsNotExist(err) {
-			return errors.Errorf("plugin %q not found", args[0])
+			if err := checkIndexPlugins(cmd, args); err != nil {
+				return err
+			}
+			return withExitCode(exitNotFound, errors.Errorf("plugin %q not found%s", args[0], didYouMean(paths.IndexPluginsPath(), args[0])))
 		} else if err != nil {
 			return errors.Wrap(err, "failed to load plugin manifest")
 		}
+		if *downloadURL {
+			return printDownloadURL(os.Stdout, plugin, *infoPlatform)
+		}
 		printPluginInfo(os.Stdout, plugin)
-		return nil
+		return printNotInIndexInfo(os.Stdout, plugin.Name)
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if *jsonSchema {
+			return nil
+		}
+		if len(args) == 1 {
+			// installed plugins are shown from their receipt without the index
+			if _, err := os.Stat(paths.PluginInstallReceiptPath(args[0])); err == nil {
+				return nil
+			}
+		}
+		return checkIndex(cmd, args)
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if *jsonSchema {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
 	},
-	PreRunE: checkIndex,
-	Args:    cobra.ExactArgs(1),
 }
 
 func printPluginInfo(out io.Writer, plugin index.Plugin) {
@@ -69,6 +177,9 @@ func printPluginInfo(out io.Writer, plugin index.Plugin) {
 	if plugin.Spec.Homepage != "" {
 		fmt.Fprintf(out, "HOMEPAGE: %s\n", plugin.Spec.Homepage)
 	}
+	if len(plugin.Spec.Tags) > 0 {
+		fmt.Fprintf(out, "TAGS: %s\n", strings.Join(plugin.Spec.Tags, ", "))
+	}
 	if plugin.Spec.Description != "" {
 		fmt.Fprintf(out, "DESCRIPTION: \n%s\n", plugin.Spec.Description)
 	}
@@ -77,6 +188,190 @@ func printPluginInfo(out io.Writer, plugin index.Plugin) {
 	}
 }
 
+// printNotInIndexInfo writes the source and the installed files of the plugin
+// to out if it is installed but not in the index, e.g. because it was removed
+// from the index or installed from a custom manifest. Nothing is written for
+// other plugins.
+func printNotInIndexInfo(out io.Writer, name string) error {
+	r, err := receipt.Load(paths.PluginInstallReceiptPath(name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to load the receipt of plugin %q", name)
+	}
+	if _, err := loadChannelPlugin(paths, name, r.Status.Source.Channel); !os.IsNotExist(err) {
+		return nil
+	}
+
+	if r.Status.Removed {
+		fmt.Fprintln(out, "STATUS: uninstalled with its receipt kept, not in any configured index")
+	} else {
+		fmt.Fprintln(out, "STATUS: installed, not in any configured index")
+	}
+	fmt.Fprintf(out, "SOURCE: %s\n", receipt.SourceIndexName(r))
+	if r.Status.Removed {
+		return nil
+	}
+	files, err := installation.PluginFiles(paths, r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the installed files of plugin %q", name)
+	}
+	fmt.Fprintln(out, "FILES:")
+	for _, f := range files {
+		fmt.Fprintf(out, "  %s\n", f)
+	}
+	return nil
+}
+
+// printDownloadURL prints the uri the plugin is downloaded from on the given
+// OS/ARCH platform, or on the current platform if it is empty.
+func printDownloadURL(out io.Writer, plugin index.Plugin, platform string) error {
+	env := installation.OSArch()
+	if platform != "" {
+		var err error
+		if env, err = installation.ParseOSArch(platform); err != nil {
+			return err
+		}
+	}
+	hosts, err := downloadHostRemap()
+	if err != nil {
+		return err
+	}
+	uri, err := pluginDownloadURL(plugin, env, isCustomManifest(plugin.Name), hosts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, uri)
+	return nil
+}
+
+// pluginDownloadURL resolves the uri of the platform of the plugin matching
+// env the way it is resolved for installing.
+func pluginDownloadURL(plugin index.Plugin, env installation.OSArchPair, customManifest bool, hosts map[string]string) (string, error) {
+	platform, ok, err := installation.MatchPlatform(plugin.Spec.Platforms, env)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find a matching platform")
+	} else if !ok {
+		return "", withExitCode(exitNotFound, errors.Errorf("plugin %q does not offer installation for %s", plugin.Name, env))
+	}
+	uri, err := installation.ExpandURIFor(platform.URI, env, customManifest)
+	if err != nil {
+		return "", err
+	}
+	return installation.RewriteDownloadHost(uri, hosts)
+}
+
+// isCustomManifest reports whether the plugin is installed from a custom
+// manifest rather than the index.
+func isCustomManifest(name string) bool {
+	r, err := receipt.Load(paths.PluginInstallReceiptPath(name))
+	return err == nil && receipt.IsCustomManifest(r)
+}
+
+// printManifestSkeleton writes a manifest skeleton of the installed plugin to
+// out and warns about what is left to fill in.
+func printManifestSkeleton(out io.Writer, name string) error {
+	r, err := receipt.Load(paths.PluginInstallReceiptPath(name))
+	if os.IsNotExist(err) || (err == nil && r.Status.Removed) {
+		return withExitCode(exitNotFound, errors.Errorf("plugin %q is not installed", name))
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to load the receipt of plugin %q", name)
+	}
+	plugin, err := manifestSkeleton(r)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(plugin)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert the manifest to yaml")
+	}
+	if _, err := out.Write(b); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Fill in the uri of each platform and the homepage of the generated manifest.")
+	if err := validation.ValidatePlugin(plugin.Name, plugin); err != nil {
+		klog.Warningf("The generated manifest is not valid yet: %v", err)
+	}
+	return nil
+}
+
+// installedFile is a file listed by "info --files".
+type installedFile struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`             // "file" or "symlink"
+	Target string `json:"target,omitempty"` // the target of a symlink
+}
+
+// printPluginFiles writes the files installed for the plugin to out, one
+// absolute path per line or as a JSON array if output is "json".
+func printPluginFiles(out io.Writer, name, output string) error {
+	if output != "" && output != "json" {
+		return errors.Errorf("unsupported output format %q, must be: json", output)
+	}
+	r, err := receipt.Load(paths.PluginInstallReceiptPath(name))
+	if os.IsNotExist(err) || (err == nil && r.Status.Removed) {
+		return withExitCode(exitNotFound, errors.Errorf("plugin %q is not installed", name))
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to load the receipt of plugin %q", name)
+	}
+	filePaths, err := installation.PluginFiles(paths, r)
+	if err != nil {
+		return err
+	}
+
+	files := make([]installedFile, 0, len(filePaths))
+	for _, path := range filePaths {
+		f := installedFile{Path: path, Type: "file"}
+		if target, err := os.Readlink(path); err == nil {
+			f.Type, f.Target = "symlink", target
+		}
+		files = append(files, f)
+	}
+	if output == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(files), "failed to write the installed files")
+	}
+	for _, f := range files {
+		if f.Target != "" {
+			fmt.Fprintf(out, "%s -> %s\n", f.Path, f.Target)
+		} else {
+			fmt.Fprintln(out, f.Path)
+		}
+	}
+	return nil
+}
+
+// manifestSkeleton returns a manifest of the plugin of the receipt that only
+// declares the platform it is installed on (or all of its platforms if none
+// matches anymore), with the uri and homepage left blank.
+func manifestSkeleton(r index.Receipt) (index.Plugin, error) {
+	platforms := r.Spec.Platforms
+	platform, ok, err := installation.GetMatchingPlatform(platforms)
+	if err != nil {
+		return index.Plugin{}, errors.Wrap(err, "failed to find the installed platform")
+	} else if ok {
+		platforms = []index.Platform{platform}
+	}
+
+	p := index.Plugin{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: constants.CurrentAPIVersion,
+			Kind:       constants.PluginKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: r.Name},
+		Spec:       r.Spec,
+	}
+	p.Spec.Homepage = ""
+	p.Spec.Platforms = make([]index.Platform, 0, len(platforms))
+	for _, pl := range platforms {
+		pl.URI = ""
+		p.Spec.Platforms = append(p.Spec.Platforms, pl)
+	}
+	return p, nil
+}
+
 // indent converts strings to an indented format ready for printing.
 // Example:
 //
@@ -95,5 +390,12 @@ func indent(s string) string {
 }
 
 func init() {
+	dumpManifest = infoCmd.Flags().Bool("dump-manifest", false, "print the plugin manifest file from the index as is")
+	asManifest = infoCmd.Flags().Bool("as-manifest", false, "print a starter manifest generated from the installed plugin")
+	downloadURL = infoCmd.Flags().Bool("download-url", false, "only print the URL the plugin is downloaded from")
+	showFiles = infoCmd.Flags().Bool("files", false, "list the files installed for the plugin")
+	infoOutput = infoCmd.Flags().StringP("output", "o", "", "output format of --files, one of: json")
+	infoPlatform = infoCmd.Flags().String("platform", "", "resolve --download-url for the specified OS/ARCH platform (e.g. linux/amd64) instead of the current one")
+	jsonSchema = infoCmd.Flags().Bool("json-schema", false, "print the JSON Schema of plugin manifests")
 	rootCmd.AddCommand(infoCmd)
 }
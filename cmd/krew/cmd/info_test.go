@@ -0,0 +1,188 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func Test_pluginDownloadURL(t *testing.T) {
+	plugin := testutil.NewPlugin().WithName("foo").WithPlatforms(
+		testutil.NewPlatform().WithOSArch("linux", "amd64").WithURI("https://github.com/foo/releases/foo-linux.tar.gz").V(),
+		testutil.NewPlatform().WithOS("darwin").WithURI("https://github.com/foo/releases/foo-${KREW_OS}-${KREW_ARCH}.tar.gz").V(),
+	).V()
+
+	tests := []struct {
+		name     string
+		env      installation.OSArchPair
+		hosts    map[string]string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "matching platform",
+			env:      installation.OSArchPair{OS: "linux", Arch: "amd64"},
+			expected: "https://github.com/foo/releases/foo-linux.tar.gz",
+		},
+		{
+			name:     "variables expand to the given platform",
+			env:      installation.OSArchPair{OS: "darwin", Arch: "arm64"},
+			expected: "https://github.com/foo/releases/foo-darwin-arm64.tar.gz",
+		},
+		{
+			name:     "download host remaps apply",
+			env:      installation.OSArchPair{OS: "linux", Arch: "amd64"},
+			hosts:    map[string]string{"github.com": "mirror.internal"},
+			expected: "https://mirror.internal/foo/releases/foo-linux.tar.gz",
+		},
+		{
+			name:    "no matching platform",
+			env:     installation.OSArchPair{OS: "windows", Arch: "amd64"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pluginDownloadURL(plugin, tt.env, false, tt.hosts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pluginDownloadURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("pluginDownloadURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_manifestSkeleton(t *testing.T) {
+	current := installation.OSArch()
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(
+		testutil.NewPlatform().WithOSArch("other", "other").WithURI("https://example.com/other.tar.gz").V(),
+		testutil.NewPlatform().WithOSArch(current.OS, current.Arch).WithURI("https://example.com/foo.tar.gz").WithSHA256("deadbeef").V(),
+	).V()
+	plugin.Spec.Homepage = "https://example.com"
+	r := receipt.New(plugin, "")
+	r.Status.BinName = "foo2"
+
+	got, err := manifestSkeleton(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.APIVersion != constants.CurrentAPIVersion || got.Kind != constants.PluginKind {
+		t.Errorf("manifest has apiVersion=%q kind=%q", got.APIVersion, got.Kind)
+	}
+	if got.Name != "foo" || got.Spec.Version != "v1.0.0" {
+		t.Errorf("manifest has name=%q version=%q, want foo v1.0.0", got.Name, got.Spec.Version)
+	}
+	if got.Spec.Homepage != "" {
+		t.Errorf("expected homepage to be blank, got %q", got.Spec.Homepage)
+	}
+	if len(got.Spec.Platforms) != 1 {
+		t.Fatalf("expected only the installed platform, got %d platforms", len(got.Spec.Platforms))
+	}
+	if pl := got.Spec.Platforms[0]; pl.URI != "" || pl.Sha256 != "deadbeef" {
+		t.Errorf("expected blank uri and the recorded sha256, got uri=%q sha256=%q", pl.URI, pl.Sha256)
+	}
+	if plugin.Spec.Platforms[1].URI == "" {
+		t.Error("manifestSkeleton() modified the receipt")
+	}
+}
+
+func Test_printNotInIndexInfo(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Root())
+
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").V()
+	r := receipt.New(plugin, "")
+	r.Status.Files = []string{"kubectl-foo"}
+	if err := os.MkdirAll(paths.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := receipt.Store(r, paths.PluginInstallReceiptPath("foo")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := printNotInIndexInfo(&buf, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"installed, not in any configured index", "SOURCE: " + constants.ManifestSourceName, filepath.Join(paths.PluginVersionInstallPath("foo", "v1.0.0"), "kubectl-foo")} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	b, err := yaml.Marshal(plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(paths.IndexPluginsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(paths.IndexPluginsPath(), "foo"+constants.ManifestExtension), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := printNotInIndexInfo(&buf, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for a plugin in the index, got:\n%s", buf.String())
+	}
+
+	// a plugin of a channel is only in the directory of the channel
+	baz := testutil.NewPlugin().WithName("baz").WithVersion("v1.0.0-beta.1").V()
+	rb := receipt.New(baz, constants.DefaultIndexName)
+	rb.Status.Source.Channel = "beta"
+	if err := receipt.Store(rb, paths.PluginInstallReceiptPath("baz")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = yaml.Marshal(baz); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(paths.IndexChannelPath("beta"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(paths.IndexChannelPath("beta"), "baz"+constants.ManifestExtension), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := printNotInIndexInfo(&buf, "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for a plugin in the channel it tracks, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := printNotInIndexInfo(&buf, "bar"); err != nil || buf.Len() != 0 {
+		t.Errorf("expected no output for a plugin that is not installed, got %v:\n%s", err, buf.String())
+	}
+}
@@ -16,24 +16,39 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/krew/cmd/krew/cmd/internal"
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/gitutil"
 	"sigs.k8s.io/krew/internal/index/indexscanner"
 	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
 
 func init() {
 	var (
-		manifest, manifestURL, archiveFileOverride *string
-		noUpdateIndex                              *bool
+		manifest, manifestURL, manifestDir, archiveFileOverride, binName, link *string
+		at, checksum, channel, version, manifestAfter                          *string
+		noUpdateIndex, verifyRun, requireRun, yes, dryRun                      *bool
+		waitForIndex                                                           *time.Duration
 	)
 
 	// installCmd represents the install command
@@ -53,20 +68,73 @@ Examples:
   --manifest-url arguments. Similarly, instead of downloading files from a URL,
   you can specify a local --archive file:
 	kubectl krew install --manifest=FILE [--archive=FILE]
+  To make sure the plugin is downloaded with the checksum you expect, even if
+  the manifest is not trusted, add --checksum=SHA256.
+
+  (For developers) To install all plugins from a directory of manifests (e.g.
+  to test an index locally), run:
+    kubectl krew install --manifest-dir=DIR
+  Plugins that are not available for the current platform are skipped.
+
+  To install a plugin under a different name (e.g. if another installed plugin
+  already uses its name), run:
+    kubectl krew install NAME --as=ALTERNATE_NAME
+  The plugin can then be uninstalled by either name.
+
+  To install the version of a plugin that was in the index at a given date
+  (YYYY-MM-DD in UTC, or an RFC 3339 timestamp), run:
+    kubectl krew install NAME --at=2020-06-01
+
+  To install a specific version of a plugin from the index history, or the
+  highest version in a range, run:
+    kubectl krew install NAME --version=v1.2.0
+    kubectl krew install NAME --version=">=v1.2.0 <v2.0.0"
+
+  To install a pre-release version of a plugin from the beta channel of the
+  index, and keep upgrading it to beta versions, run:
+    kubectl krew install NAME --channel=beta
+
+  To see what would be downloaded and installed, without changing anything
+  (or updating the index), run:
+    kubectl krew install NAME --dry-run
+
+  To record the manifests of the installed plugins, with the installed version
+  and only the platform installed on this machine, in a file (or on stdout
+  with "-"), run:
+    kubectl krew install NAME --print-manifest-after=FILE
+
+  (For developers) To link a plugin to your build output, so that rebuilding
+  it doesn't require a reinstall, run:
+    kubectl krew install --link=./dist/kubectl-foo [NAME]
+  The plugin name is derived from the binary name unless NAME is given.
+  Linked plugins are not upgraded, uninstalling them only removes the link.
 
 Remarks:
+  If a plugin is not in the index, but its name is the prefix of exactly one
+  plugin in the index, you are asked to install that plugin instead (or it is
+  installed right away with --yes).
   If a plugin is already installed, it will be skipped.
   Failure to install a plugin will not stop the installation of other plugins.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if *manifestAfter != "" && (*link != "" || *dryRun) {
+				return errors.New("--print-manifest-after cannot be used with --link or --dry-run")
+			}
+			if *link != "" && *dryRun {
+				return errors.New("--dry-run cannot be used with --link")
+			}
+			if *link != "" {
+				return installLink(*link, args, *manifest != "" || *manifestURL != "" || *manifestDir != "" || *archiveFileOverride != "" || *binName != "")
+			}
+
 			var pluginNames = make([]string, len(args))
 			copy(pluginNames, args)
 
-			if !isTerminal(os.Stdin) && (len(pluginNames) != 0 || *manifest != "") {
+			if !isTerminal(os.Stdin) && (len(pluginNames) != 0 || *manifest != "" || *manifestDir != "") {
 				fmt.Fprintln(os.Stderr, "WARNING: Detected stdin, but discarding it because of --manifest or args")
 			}
 
-			if !isTerminal(os.Stdin) && (len(pluginNames) == 0 && *manifest == "") {
+			if !isTerminal(os.Stdin) && (len(pluginNames) == 0 && *manifest == "" && *manifestDir == "") {
 				fmt.Fprintln(os.Stderr, "Reading plugin names via stdin")
 				scanner := bufio.NewScanner(os.Stdin)
 				scanner.Split(bufio.ScanLines)
@@ -81,24 +149,80 @@ Remarks:
 				return errors.New("cannot specify --manifest and --manifest-url at the same time")
 			}
 
-			if len(pluginNames) != 0 && (*manifest != "" || *manifestURL != "") {
-				return errors.New("must specify either specify either plugin names (via positional arguments or STDIN), or --manifest/--manifest-url; not both")
+			if *manifestDir != "" && (*manifest != "" || *manifestURL != "") {
+				return errors.New("cannot specify --manifest-dir with --manifest or --manifest-url")
+			}
+
+			if len(pluginNames) != 0 && (*manifest != "" || *manifestURL != "" || *manifestDir != "") {
+				return errors.New("must specify either specify either plugin names (via positional arguments or STDIN), or --manifest/--manifest-url/--manifest-dir; not both")
 			}
 
 			if *archiveFileOverride != "" && *manifest == "" && *manifestURL == "" {
 				return errors.New("--archive can be specified only with --manifest or --manifest-url")
 			}
 
-			var install []index.Plugin
-			for _, name := range pluginNames {
-				plugin, err := indexscanner.LoadPluginByName(paths.IndexPluginsPath(), name)
-				if err != nil {
-					if os.IsNotExist(err) {
-						return errors.Errorf("plugin %q does not exist in the plugin index", name)
+			if *checksum != "" && *manifest == "" && *manifestURL == "" {
+				// index installs are verified against the index already
+				klog.Warningf("Ignoring --checksum, it applies only to --manifest or --manifest-url")
+				*checksum = ""
+			}
+
+			var atTime time.Time
+			if *at != "" {
+				if *manifest != "" || *manifestURL != "" || *manifestDir != "" {
+					return errors.New("--at cannot be used with --manifest, --manifest-url or --manifest-dir")
+				}
+				if err := checkIndexHistory("--at"); err != nil {
+					return err
+				}
+				var err error
+				if atTime, err = parseIndexTime(*at); err != nil {
+					return err
+				}
+			}
+
+			var versionSpec semver.Spec
+			if *version != "" {
+				if *manifest != "" || *manifestURL != "" || *manifestDir != "" || *at != "" {
+					return errors.New("--version cannot be used with --manifest, --manifest-url, --manifest-dir or --at")
+				}
+				var err error
+				if versionSpec, err = semver.ParseSpec(*version); err != nil {
+					return errors.Wrapf(err, "invalid version %q specified with --version", *version)
+				}
+				if !versionSpec.IsLatest() {
+					if err := checkIndexHistory("--version"); err != nil {
+						return err
+					}
+				}
+			}
+
+			// the channel in the config file only applies to the current index
+			channelName := *channel
+			if *manifest != "" || *manifestURL != "" || *manifestDir != "" || *at != "" {
+				if channelName != "" {
+					return errors.New("--channel cannot be used with --manifest, --manifest-url, --manifest-dir or --at")
+				}
+			} else if channelName == "" {
+				channelName = cfg.Channel
+			}
+			indexChannel, err := parseChannel(channelName)
+			if err != nil {
+				return err
+			}
+
+			// plugins are either all loaded from the index or from a single custom manifest
+			indexName := constants.DefaultIndexName
+			install, err := loadIndexPlugins(pluginNames, *at, atTime, indexChannel, prefixConfirmer(*yes))
+			if err != nil {
+				return err
+			}
+			if *version != "" {
+				for i := range install {
+					if install[i], err = loadPluginVersion(install[i].Name, &install[i], versionSpec); err != nil {
+						return withPlugin(install[i].Name, err)
 					}
-					return errors.Wrapf(err, "failed to load plugin %q from the index", name)
 				}
-				install = append(install, plugin)
 			}
 
 			if *manifest != "" {
@@ -107,43 +231,94 @@ Remarks:
 					return errors.Wrap(err, "failed to load plugin manifest from file")
 				}
 				install = append(install, plugin)
+				indexName = ""
 			} else if *manifestURL != "" {
-				plugin, err := readPluginFromURL(*manifestURL)
+				if isOffline() {
+					return errors.New("--manifest-url needs network access, which is blocked by offline mode")
+				}
+				fetcher, err := httpFetcher()
+				if err != nil {
+					return err
+				}
+				plugin, err := readPluginFromURL(*manifestURL, fetcher)
 				if err != nil {
 					return errors.Wrap(err, "failed to read plugin manifest file from url")
 				}
 				install = append(install, plugin)
+				indexName = ""
+			} else if *manifestDir != "" {
+				plugins, err := loadInstallableManifests(*manifestDir)
+				if err != nil {
+					return err
+				}
+				if len(plugins) == 0 {
+					return errors.Errorf("no plugin manifests for this platform found in %q", *manifestDir)
+				}
+				install = append(install, plugins...)
+				indexName = ""
 			}
 
 			if len(install) == 0 {
 				return cmd.Help()
 			}
 
+			if *checksum != "" {
+				// the download is verified against the manifest, so it must agree
+				if err := verifyManifestChecksum(install[0], *checksum); err != nil {
+					return withPlugin(install[0].Name, err)
+				}
+			}
+
+			if *binName != "" && len(install) != 1 {
+				return errors.New("--bin-name (or --as) can be specified only when installing a single plugin")
+			}
+
 			for _, plugin := range install {
 				klog.V(2).Infof("Will install plugin: %s\n", plugin.Name)
 			}
 
-			var failed []string
+			opts, err := globalInstallOpts()
+			if err != nil {
+				return err
+			}
+			opts.ArchiveFileOverride = *archiveFileOverride
+			opts.BinName = *binName
+			opts.VerifyRun = *verifyRun
+			opts.RequireRun = *requireRun
+			opts.Channel = indexChannel
+
+			if *dryRun {
+				return printInstallPlans(os.Stdout, install, indexName, opts)
+			}
+
+			var installed, failed []string
 			var returnErr error
+			progress := newProgressStream()
 			for _, plugin := range install {
 				fmt.Fprintf(os.Stderr, "Installing plugin: %s\n", plugin.Name)
-				err := installation.Install(paths, plugin, installation.InstallOpts{
-					ArchiveFileOverride: *archiveFileOverride,
-				})
+				progress.emit(progressStart, plugin.Name, plugin.Spec.Version, "")
+				opts.Progress = progress.forPlugin(plugin.Name, plugin.Spec.Version)
+				err := installation.Install(paths, plugin, indexName, opts)
 				if err == installation.ErrIsAlreadyInstalled {
 					klog.Warningf("Skipping plugin %q, it is already installed", plugin.Name)
+					progress.emit(progressDone, plugin.Name, plugin.Spec.Version, "already installed")
 					continue
 				}
 				if err != nil {
+					progress.emitError(plugin.Name, plugin.Spec.Version, err)
 					klog.Warningf("failed to install plugin %q: %v", plugin.Name, err)
 					if returnErr == nil {
-						returnErr = err
+						returnErr = withPlugin(plugin.Name, err)
 					}
 					failed = append(failed, plugin.Name)
 					continue
 				}
 				fmt.Fprintf(os.Stderr, "Installed plugin: %s\n", plugin.Name)
-				output := fmt.Sprintf("Use this plugin:\n\tkubectl %s\n", plugin.Name)
+				invokeName := plugin.Name
+				if *binName != "" {
+					invokeName = *binName
+				}
+				output := fmt.Sprintf("Use this plugin:\n\tkubectl %s\n", invokeName)
 				if plugin.Spec.Homepage != "" {
 					output += fmt.Sprintf("Documentation:\n\t%s\n", plugin.Spec.Homepage)
 				}
@@ -152,6 +327,22 @@ Remarks:
 				}
 				fmt.Fprintln(os.Stderr, indent(output))
 				internal.PrintSecurityNotice(plugin.Name)
+
+				if err := runHook(hookInstall, plugin.Name, plugin.Spec.Version); err != nil {
+					progress.emitError(plugin.Name, plugin.Spec.Version, err)
+					if returnErr == nil {
+						returnErr = withPlugin(plugin.Name, err)
+					}
+					failed = append(failed, plugin.Name)
+					continue
+				}
+				progress.emit(progressDone, plugin.Name, plugin.Spec.Version, "")
+				installed = append(installed, plugin.Name)
+			}
+			if *manifestAfter != "" {
+				if err := writeInstalledManifests(*manifestAfter, installed); err != nil {
+					return err
+				}
 			}
 			if len(failed) > 0 {
 				return errors.Wrapf(returnErr, "failed to install some plugins: %+v", failed)
@@ -159,35 +350,339 @@ Remarks:
 			return nil
 		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if *manifest != "" {
-				klog.V(4).Infof("--manifest specified, not ensuring plugin index")
+			if *waitForIndex > 0 {
+				klog.V(4).Infof("--wait-for-index specified, waiting for index operations to finish")
+				if err := gitutil.WaitUntilSettled(paths.IndexPath(), *waitForIndex); err != nil {
+					return errors.Wrap(err, "local copy of plugin index did not settle")
+				}
+			}
+			if *manifest != "" || *manifestURL != "" || *manifestDir != "" || *link != "" {
+				klog.V(4).Infof("--manifest, --manifest-url, --manifest-dir or --link specified, not ensuring plugin index")
 				return nil
 			}
+			if *dryRun {
+				klog.V(4).Infof("--dry-run specified, not updating local copy of plugin index")
+				return checkIndexPlugins(cmd, args)
+			}
 			if *noUpdateIndex {
 				klog.V(4).Infof("--no-update-index specified, skipping updating local copy of plugin index")
-				return nil
+				return ensureIndex(cmd, args)
+			}
+			if err := ensureIndexUpdated(cmd, args); err != nil {
+				return err
 			}
-			return ensureIndexUpdated(cmd, args)
+			return ensureIndex(cmd, args)
 		},
 	}
 
 	manifest = installCmd.Flags().String("manifest", "", "(Development-only) specify local plugin manifest file")
 	manifestURL = installCmd.Flags().String("manifest-url", "", "(Development-only) specify plugin manifest file from url")
+	manifestDir = installCmd.Flags().String("manifest-dir", "", "(Development-only) install all plugins from the manifests in the specified directory")
 	archiveFileOverride = installCmd.Flags().String("archive", "", "(Development-only) force all downloads to use the specified file")
+	binName = installCmd.Flags().String("bin-name", "", "install the plugin to be invoked with the specified name instead of its plugin name")
+	installCmd.Flags().StringVar(binName, "as", "", "same as --bin-name")
+	link = installCmd.Flags().String("link", "", "(Development-only) link the plugin to the specified binary instead of downloading it")
 	noUpdateIndex = installCmd.Flags().Bool("no-update-index", false, "(Experimental) do not update local copy of plugin index before installing")
+	channel = installCmd.Flags().String("channel", "", "install from the specified channel of the index and track it on upgrades, one of: "+strings.Join(indexChannels, ", ")+" (default \"stable\")")
+	dryRun = installCmd.Flags().Bool("dry-run", false, "only show what would be downloaded and installed, without changing anything")
+	yes = installCmd.Flags().Bool("yes", false, "install the only plugin that starts with a name that is not in the index without asking")
+	checksum = installCmd.Flags().String("checksum", "", "(Development-only) require the sha256 checksum of the download in the custom manifest to be the specified one, ignored for index installs")
+	version = installCmd.Flags().String("version", "", "install the specified version of the plugins from the index history, \"latest\" or a range such as \">=v1.2.0 <v2.0.0\"")
+	at = installCmd.Flags().String("at", "", "install the versions of the plugins that were in the index at the specified date (YYYY-MM-DD) or RFC 3339 time")
+	verifyRun = installCmd.Flags().Bool("verify-run", false, "run the installed plugins with --help and warn if they fail to run on this platform")
+	requireRun = installCmd.Flags().Bool("require-run", false, "like --verify-run, but fail the installation if a plugin fails to run")
+	waitForIndex = installCmd.Flags().Duration("wait-for-index", 0, "wait up to the specified duration (e.g. 30s) for a concurrent update of the local copy of plugin index to finish")
+	manifestAfter = installCmd.Flags().String("print-manifest-after", "", "after installing, write the manifests of the installed plugins for the installed platform to the specified file (\"-\" for stdout)")
 
 	rootCmd.AddCommand(installCmd)
 }
 
-func readPluginFromURL(url string) (index.Plugin, error) {
+// printInstallPlans writes what installing the plugins would do to out.
+func printInstallPlans(out io.Writer, plugins []index.Plugin, indexName string, opts installation.InstallOpts) error {
+	for _, plugin := range plugins {
+		plan, err := installation.PlanInstall(paths, plugin, indexName, opts)
+		if err != nil {
+			return withPlugin(plugin.Name, errors.Wrapf(err, "failed to install plugin %q", plugin.Name))
+		}
+		if plan.AlreadyInstalled {
+			fmt.Fprintf(out, "Plugin %s is already installed, it would be skipped\n", plugin.Name)
+			continue
+		}
+		fmt.Fprintf(out, "Would install plugin: %s\n", plugin.Name)
+		fmt.Fprintf(out, "  Version: %s\n", plan.Version)
+		if opts.ArchiveFileOverride != "" {
+			fmt.Fprintf(out, "  Archive: %s\n", opts.ArchiveFileOverride)
+		} else {
+			fmt.Fprintf(out, "  URI: %s\n", plan.URI)
+		}
+		fmt.Fprintf(out, "  SHA256: %s\n", plan.Sha256)
+		fmt.Fprintf(out, "  Install directory: %s\n", plan.InstallDir)
+		fmt.Fprintln(out, "  Files:")
+		for _, f := range plan.Files {
+			fmt.Fprintf(out, "    %s -> %s\n", f.From, f.To)
+		}
+		fmt.Fprintf(out, "  Executable: %s (invoked as \"kubectl %s\")\n", plan.Bin, plan.BinName)
+	}
+	return nil
+}
+
+// writeInstalledManifests writes the manifests that the named plugins were
+// installed from, as a stream of YAML documents, to the file at dest or to
+// stdout if dest is "-". Nothing is written if no plugins were installed.
+func writeInstalledManifests(dest string, names []string) error {
+	if len(names) == 0 {
+		klog.V(1).Infof("No plugins were installed, not writing manifests to %q", dest)
+		return nil
+	}
+	var buf bytes.Buffer
+	for i, name := range names {
+		plugin, err := installedManifest(name)
+		if err != nil {
+			return withPlugin(name, err)
+		}
+		b, err := yaml.Marshal(plugin)
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert the manifest of plugin %q to yaml", name)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(b)
+	}
+	if dest == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return errors.Wrapf(ioutil.WriteFile(dest, buf.Bytes(), 0644), "failed to write the installed manifests to %q", dest)
+}
+
+// installedManifest returns the manifest in the receipt of the installed
+// plugin, with only the platform that was installed on this machine and the
+// uri it was downloaded from, if the receipt records it.
+func installedManifest(name string) (index.Plugin, error) {
+	r, err := receipt.Load(paths.PluginInstallReceiptPath(name))
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to load the receipt of plugin %q", name)
+	}
+	plugin := r.Plugin
+	platform, ok, err := installation.GetMatchingPlatform(plugin.Spec.Platforms)
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to find the installed platform of plugin %q", name)
+	}
+	if !ok {
+		return index.Plugin{}, errors.Errorf("plugin %q has no platform for %s in its receipt", name, installation.OSArch())
+	}
+	if r.Status.URI != "" {
+		platform.URI = r.Status.URI
+	}
+	plugin.Spec.Platforms = []index.Platform{platform}
+	return plugin, nil
+}
+
+// verifyManifestChecksum checks that the sha256 checksum in the manifest for
+// the current platform is the expected one.
+func verifyManifestChecksum(plugin index.Plugin, expected string) error {
+	if raw, err := hex.DecodeString(expected); err != nil || len(raw) != sha256.Size {
+		return errors.Errorf("invalid --checksum %q, expected a hex-encoded sha256 checksum", expected)
+	}
+	platform, ok, err := installation.GetMatchingPlatform(plugin.Spec.Platforms)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find a matching platform for plugin %s", plugin.Name)
+	}
+	if !ok {
+		return errors.Errorf("plugin %q does not offer installation for this platform (%s)", plugin.Name, installation.OSArch())
+	}
+	if !strings.EqualFold(platform.Sha256, expected) {
+		return errors.Errorf("the plugin manifest declares sha256 checksum %s for this platform, but %s was specified with --checksum", platform.Sha256, expected)
+	}
+	return nil
+}
+
+// loadInstallableManifests loads the valid plugin manifests in dir that offer
+// an installation for the current platform. Invalid manifests and plugins for
+// other platforms are skipped with a warning.
+func loadInstallableManifests(dir string) ([]index.Plugin, error) {
+	plugins, err := indexscanner.LoadPluginListFromFS(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load plugin manifests from %q", dir)
+	}
+	var out []index.Plugin
+	for _, p := range plugins {
+		_, ok, err := installation.GetMatchingPlatform(p.Spec.Platforms)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find a matching platform for plugin %s", p.Name)
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is not available for this platform (%s)\n", p.Name, installation.OSArch())
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// installLink links a plugin to a development build. The plugin name is
+// derived from the binary unless it is provided as the only argument.
+func installLink(binary string, args []string, otherSources bool) error {
+	if otherSources {
+		return errors.New("--link cannot be used with --manifest, --manifest-url, --archive or --bin-name (--as)")
+	}
+	if len(args) > 1 {
+		return errors.New("--link accepts at most one plugin name")
+	}
+	name := installation.PluginNameFromBinary(binary)
+	if len(args) == 1 {
+		name = args[0]
+	}
+
+	fmt.Fprintf(os.Stderr, "Linking plugin: %s\n", name)
+	err := installation.LinkDev(paths, name, binary)
+	if err == installation.ErrIsAlreadyInstalled {
+		return withPlugin(name, withExitCode(exitUpToDate, errors.Errorf("plugin %q is already installed, uninstall it first", name)))
+	} else if err != nil {
+		return withPlugin(name, errors.Wrapf(err, "failed to link plugin %q", name))
+	}
+	fmt.Fprintf(os.Stderr, "Linked plugin: %s\n", name)
+	fmt.Fprintln(os.Stderr, indent(fmt.Sprintf("Use this plugin:\n\tkubectl %s\n", name)))
+	return runHook(hookInstall, name, installation.DevLinkVersion)
+}
+
+func readPluginFromURL(url string, fetcher download.HTTPFetcher) (index.Plugin, error) {
 	klog.V(4).Infof("downloading manifest from url %s", url)
-	resp, err := http.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to create request for url (%s)", url)
+	}
+	resp, err := fetcher.Do(req)
 	if err != nil {
-		return index.Plugin{}, errors.Wrapf(err, "request to url failed (%s)", url)
+		return index.Plugin{}, withExitCode(exitNetwork, errors.Wrapf(err, "request to url failed (%s)", url))
 	}
 	klog.V(4).Infof("manifest downloaded from url, status=%v headers=%v", resp.Status, resp.Header)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return index.Plugin{}, errors.Errorf("unexpected status code (http %d) from url", resp.StatusCode)
+		return index.Plugin{}, withExitCode(exitNetwork, errors.Errorf("unexpected status code (http %d) from url", resp.StatusCode))
 	}
 	return indexscanner.ReadPlugin(resp.Body)
 }
+
+// parseIndexTime parses a date (YYYY-MM-DD, at the start of the day in UTC) or
+// an RFC 3339 time.
+func parseIndexTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q, expected a date (YYYY-MM-DD) or an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// loadIndexPlugins loads the manifests of the named plugins from the index, as
+// they were at atTime if at is set. The index is locked while the manifests
+// are read, so that a concurrent update doesn't change it. Otherwise, the
+// manifests are loaded for the channel. A name that is not in the current
+// index is resolved to the plugin it is a prefix of, if there is only one and
+// confirm (which may be nil) agrees.
+func loadIndexPlugins(names []string, at string, atTime time.Time, channel string, confirm func(question string) bool) ([]index.Plugin, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var plugins []index.Plugin
+	for _, name := range names {
+		plugin, match, err := loadIndexPlugin(name, at, atTime, channel, true)
+		if err == nil && match != "" {
+			// ask without holding the index lock, so that a slow answer does
+			// not block updates of the index
+			if err = confirmPluginPrefix(name, match, confirm); err != nil {
+				return nil, withPlugin(name, err)
+			}
+			plugin, _, err = loadIndexPlugin(match, at, atTime, channel, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// loadIndexPlugin loads the named plugin like loadIndexPlugins while holding
+// the shared index lock. If the plugin does not exist and matchPrefix is set,
+// it returns the name of the only plugin starting with the name instead.
+func loadIndexPlugin(name, at string, atTime time.Time, channel string, matchPrefix bool) (index.Plugin, string, error) {
+	lock, err := lockIndexShared()
+	if err != nil {
+		return index.Plugin{}, "", err
+	}
+	defer lock.Unlock()
+
+	if at != "" {
+		plugin, err := indexscanner.LoadPluginAtTime(paths.IndexPath(), name, atTime)
+		if err == indexscanner.ErrNotFoundAtTime {
+			return index.Plugin{}, "", withPlugin(name, withExitCode(exitNotFound, errors.Errorf("plugin %q did not exist in the plugin index at %s", name, at)))
+		} else if err != nil {
+			return index.Plugin{}, "", withPlugin(name, errors.Wrapf(err, "failed to load plugin %q from the index history", name))
+		}
+		return plugin, "", nil
+	}
+	plugin, err := loadChannelPlugin(paths, name, channel)
+	if os.IsNotExist(err) && matchPrefix {
+		match, err := pluginPrefixMatch(name, channelPluginsDirs(paths, channel)...)
+		if err != nil {
+			return index.Plugin{}, "", withPlugin(name, err)
+		}
+		return index.Plugin{}, match, nil
+	}
+	if err != nil {
+		return index.Plugin{}, "", withPlugin(name, errors.Wrapf(err, "failed to load plugin %q from the index", name))
+	}
+	return plugin, "", nil
+}
+
+// pluginPrefixMatch returns the name of the only plugin in the plugins
+// directories that starts with prefix.
+func pluginPrefixMatch(prefix string, pluginsDirs ...string) (string, error) {
+	matches, err := indexscanner.PluginNamesWithPrefix(prefix, pluginsDirs...)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find plugins starting with %q", prefix)
+	}
+	switch {
+	case len(matches) == 0:
+		return "", withExitCode(exitNotFound, errors.Errorf("plugin %q does not exist in the plugin index%s", prefix, didYouMean(pluginsDirs[0], prefix)))
+	case len(matches) > 1:
+		return "", withExitCode(exitNotFound, errors.Errorf("plugin %q does not exist in the plugin index, plugins starting with it: %s", prefix, strings.Join(matches, ", ")))
+	}
+	return matches[0], nil
+}
+
+// confirmPluginPrefix fails unless confirm agrees to install the plugin match
+// instead of prefix. confirm may be nil if no one can be asked.
+func confirmPluginPrefix(prefix, match string, confirm func(question string) bool) error {
+	if confirm == nil || !confirm(fmt.Sprintf("Plugin %q does not exist in the plugin index. Install %q instead?", prefix, match)) {
+		return withExitCode(exitNotFound, errors.Errorf("plugin %q does not exist in the plugin index, did you mean %q? (use --yes to install it)", prefix, match))
+	}
+	return nil
+}
+
+// didYouMean returns a suggestion of a plugin in pluginsDir with a name
+// similar to a name that is not in the index, to append to an error message,
+// or an empty string if there is none.
+func didYouMean(pluginsDir, name string) string {
+	if similar, ok := indexscanner.SimilarPluginName(pluginsDir, name); ok {
+		return fmt.Sprintf(", did you mean %q?", similar)
+	}
+	return ""
+}
+
+// prefixConfirmer returns how to confirm installing a plugin matched by a
+// prefix: without asking if yes is set, by asking on a terminal, or nil if
+// there is no terminal to ask on.
+func prefixConfirmer(yes bool) func(string) bool {
+	if yes {
+		return func(string) bool { return true }
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return func(question string) bool { return confirm(os.Stdin, os.Stderr, question) }
+}
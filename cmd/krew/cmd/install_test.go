@@ -15,10 +15,26 @@
 package cmd
 
 import (
+	"bytes"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
 )
 
 func Test_readPluginFromURL(t *testing.T) {
@@ -49,7 +65,7 @@ func Test_readPluginFromURL(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := readPluginFromURL(tt.url)
+			got, err := readPluginFromURL(tt.url, download.HTTPFetcher{})
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("readPluginFromURL() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -60,3 +76,209 @@ func Test_readPluginFromURL(t *testing.T) {
 		})
 	}
 }
+
+func Test_loadInstallableManifests(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	otherOS := "windows"
+	if runtime.GOOS == otherOS {
+		otherOS = "linux"
+	}
+	for name, platform := range map[string]*testutil.R{
+		"here":      testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH),
+		"elsewhere": testutil.NewPlatform().WithOSArch(otherOS, runtime.GOARCH),
+	} {
+		b, err := yaml.Marshal(testutil.NewPlugin().WithName(name).WithPlatforms(platform.V()).V())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir.Write(name+constants.ManifestExtension, b)
+	}
+	tmpDir.Write("invalid"+constants.ManifestExtension, []byte("kind: Plugin"))
+
+	plugins, err := loadInstallableManifests(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 || plugins[0].Name != "here" {
+		t.Errorf("expected only the plugin for this platform, got %+v", plugins)
+	}
+}
+
+func Test_parseIndexTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2020-06-01", want: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "2020-06-01T12:30:00+02:00", want: time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)},
+		{in: "2020-06-01 12:30", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseIndexTime(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIndexTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseIndexTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_verifyManifestChecksum(t *testing.T) {
+	sum := "2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f"
+	plugin := testutil.NewPlugin().WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithSHA256(sum).V()).V()
+
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "matching", expected: sum},
+		{name: "matching in upper case", expected: strings.ToUpper(sum)},
+		{name: "mismatch", expected: strings.Repeat("0", 64), wantErr: true},
+		{name: "not a checksum", expected: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyManifestChecksum(plugin, tt.expected); (err != nil) != tt.wantErr {
+				t.Errorf("verifyManifestChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	otherPlatform := testutil.NewPlugin().WithPlatforms(
+		testutil.NewPlatform().WithOSArch("none", runtime.GOARCH).WithSHA256(sum).V()).V()
+	if err := verifyManifestChecksum(otherPlatform, sum); err == nil {
+		t.Error("expected an error for a plugin not available on this platform")
+	}
+}
+
+func Test_pluginPrefixMatch(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	for _, name := range []string{"kubectx", "kubens", "ns"} {
+		tmpDir.Write(filepath.Join("plugins", name+constants.ManifestExtension), []byte("kind: Plugin"))
+	}
+	for _, name := range []string{"kubens", "nsx-beta"} {
+		tmpDir.Write(filepath.Join("beta", name+constants.ManifestExtension), []byte("kind: Plugin"))
+	}
+	yes := func(string) bool { return true }
+	no := func(string) bool { return false }
+
+	tests := []struct {
+		name     string
+		prefix   string
+		confirm  func(string) bool
+		expected string
+		wantErr  bool
+	}{
+		{name: "single match confirmed", prefix: "kubect", confirm: yes, expected: "kubectx"},
+		{name: "single match declined", prefix: "kubect", confirm: no, wantErr: true},
+		{name: "cannot confirm", prefix: "kubect", wantErr: true},
+		{name: "multiple matches", prefix: "kube", confirm: yes, wantErr: true},
+		{name: "no match", prefix: "foo", confirm: yes, wantErr: true},
+		{name: "match in the channel", prefix: "nsx", confirm: yes, expected: "nsx-beta"},
+		{name: "same plugin in the channel", prefix: "kuben", confirm: yes, expected: "kubens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pluginPrefixMatch(tt.prefix, tmpDir.Path("plugins"), tmpDir.Path("beta"))
+			if err == nil {
+				if err = confirmPluginPrefix(tt.prefix, got, tt.confirm); err != nil {
+					got = ""
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pluginPrefixMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && exitCode(err) != exitNotFound {
+				t.Errorf("pluginPrefixMatch() error has exit code %d, want %d", exitCode(err), exitNotFound)
+			}
+			if got != tt.expected {
+				t.Errorf("pluginPrefixMatch() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_printInstallPlans(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Root())
+
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.2.3").WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithURI("https://example.com/foo.tar.gz").V()).V()
+	var buf bytes.Buffer
+	if err := printInstallPlans(&buf, []index.Plugin{plugin}, constants.DefaultIndexName, installation.InstallOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Would install plugin: foo", "Version: v1.2.3", "URI: https://example.com/foo.tar.gz", `invoked as "kubectl foo"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+	if _, err := os.Stat(paths.InstallPath()); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be installed, got err=%v", err)
+	}
+}
+
+func Test_writeInstalledManifests(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Root())
+
+	if err := os.MkdirAll(paths.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sum := "2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f"
+	for _, name := range []string{"foo", "bar"} {
+		plugin := testutil.NewPlugin().WithName(name).WithVersion("v1.2.3").WithPlatforms(
+			testutil.NewPlatform().WithOSArch("none", runtime.GOARCH).V(),
+			testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithSHA256(sum).V()).V()
+		r := receipt.New(plugin, constants.DefaultIndexName)
+		r.Status.URI = "https://mirror.example.com/" + name + ".tar.gz"
+		if err := receipt.Store(r, paths.PluginInstallReceiptPath(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := tmpDir.Path("manifests" + constants.ManifestExtension)
+	if err := writeInstalledManifests(dest, []string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := strings.Split(string(b), "---\n")
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 manifests, got %d:\n%s", len(docs), b)
+	}
+	for i, name := range []string{"foo", "bar"} {
+		var got index.Plugin
+		if err := yaml.Unmarshal([]byte(docs[i]), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != name || got.Spec.Version != "v1.2.3" {
+			t.Errorf("manifest %d is for %s %s, want %s v1.2.3", i, got.Name, got.Spec.Version, name)
+		}
+		if len(got.Spec.Platforms) != 1 || got.Spec.Platforms[0].Sha256 != sum {
+			t.Errorf("expected only the installed platform in the manifest of %s, got %+v", name, got.Spec.Platforms)
+		} else if uri := got.Spec.Platforms[0].URI; uri != "https://mirror.example.com/"+name+".tar.gz" {
+			t.Errorf("expected the uri the plugin was downloaded from in the manifest of %s, got %q", name, uri)
+		}
+	}
+
+	if err := writeInstalledManifests(tmpDir.Path("missing"), []string{"baz"}); err == nil {
+		t.Error("expected an error for a plugin that is not installed")
+	}
+}
@@ -15,20 +15,44 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"k8s.io/klog"
 
+	"sigs.k8s.io/krew/internal/environment"
 	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
 )
 
+// sort orders supported by "list --sort"
+const (
+	listSortName        = "name"
+	listSortIndex       = "index"
+	listSortVersion     = "version"
+	listSortInstalledAt = "installed-at"
+	listSortInstalled   = "installed" // alias of listSortInstalledAt
+)
+
+var listSortOrders = []string{listSortName, listSortIndex, listSortVersion, listSortInstalledAt}
+
 func init() {
+	var indexName, sortBy, output *string
+	var reverse, orphaned, checkIntegrity *bool
+
 	// listCmd represents the list command
 	listCmd := &cobra.Command{
 		Use:   "list",
@@ -38,35 +62,144 @@ func init() {
 Remarks:
   Redirecting the output of this command to a program or file will only print
   the names of the plugins installed. This output can be piped back to the
-  "install" command.`,
+  "install" command.
+
+  Use --index to only show plugins installed from the given index. Plugins
+  installed from a custom manifest can be shown with --index="(manifest)".
+  Plugins are filtered by the index recorded in their receipts, also if that
+  index is no longer configured.
+
+  Use --sort to order the plugins by "name" (default), source "index",
+  "version", or "installed-at" to show the most recently installed or
+  upgraded plugins first. Plugins with equal keys are ordered by name.
+  Use --reverse to invert the order.
+
+  Use --orphaned to only show plugins that were installed from the index but
+  are no longer in it. They cannot be upgraded and are not maintained anymore.
+  Plugins installed from a custom manifest are not in the index by design and
+  are not shown.
+
+  Plugins uninstalled with "uninstall --keep-receipt" are shown last as "not
+  present" on a terminal, and can be restored with "kubectl krew reinstall".
+  They are left out of the plugin names printed otherwise.
+
+  Use --check-integrity to check the installed files of each plugin against
+  the checksums recorded when it was installed, and show the result in the
+  INTEGRITY column: "ok", "mismatch" if files are missing or were modified, or
+  "unknown" if no checksums were recorded. Mismatches are explained on stderr.
+
+  Use -o name to only print the plugin names, also on a terminal, or -o json
+  to print the installed plugins as a JSON array.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			plugins, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
+			if *sortBy == listSortInstalled {
+				*sortBy = listSortInstalledAt
+			}
+			if !isListSortOrder(*sortBy) {
+				return errors.Errorf("unsupported sort order %q, must be one of: %s", *sortBy, strings.Join(listSortOrders, ", "))
+			}
+			if *output != "" && *output != "name" && *output != "json" {
+				return errors.Errorf("unsupported output format %q, must be one of: name, json", *output)
+			}
+			if *checkIntegrity && *output == "name" {
+				return errors.New("--check-integrity cannot be used with -o name")
+			}
+			receipts, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
 			if err != nil {
 				return errors.Wrap(err, "failed to find all installed versions")
 			}
+			if *indexName != "" {
+				if !isConfiguredIndex(*indexName) {
+					klog.Warningf("Index %q is not configured, listing the plugins whose receipts record it as their source", *indexName)
+				}
+				receipts = filterBySourceIndex(receipts, *indexName)
+			}
+			if *orphaned {
+				var fromManifest int
+				receipts, fromManifest = orphanedReceipts(paths, receipts)
+				if fromManifest > 0 {
+					fmt.Fprintf(os.Stderr, "Skipped %d plugin(s) installed from a custom manifest, they are not expected to be in the index\n", fromManifest)
+				}
+			}
+
+			installed := make(map[string]time.Time, len(receipts))
+			for _, r := range receipts {
+				t, err := installation.InstallTime(r, paths.PluginInstallReceiptPath(r.Name))
+				if err != nil {
+					return errors.Wrapf(err, "failed to find the install time of plugin %s", r.Name)
+				}
+				installed[r.Name] = t
+			}
+			sortReceipts(receipts, *sortBy, *reverse, installed)
+
+			var integrity []installation.IntegrityResult
+			if *checkIntegrity {
+				integrity = checkReceiptsIntegrity(receipts, runtime.NumCPU())
+				for i, r := range receipts {
+					if integrity[i].Status == installation.IntegrityMismatch {
+						fmt.Fprintf(os.Stderr, "WARNING: plugin %s does not match its recorded checksums: %s\n", r.Name, strings.Join(integrity[i].Problems, ", "))
+					}
+				}
+			}
+
+			var kept []index.Receipt
+			if !*orphaned {
+				if kept, err = installation.ListKeptReceipts(paths.InstallReceiptsPath()); err != nil {
+					return errors.Wrap(err, "failed to find kept receipts")
+				}
+				if *indexName != "" {
+					kept = filterBySourceIndex(kept, *indexName)
+				}
+			}
+
+			if *output == "json" {
+				return printInstalledPluginsJSON(os.Stdout, receipts, installed, integrity, kept)
+			}
 
 			// return sorted list of plugin names when piped to other commands or file
-			if !isTerminal(os.Stdout) {
+			if (!isTerminal(os.Stdout) && !*checkIntegrity) || *output == "name" {
 				var names []string
-				for name := range plugins {
-					names = append(names, name)
+				for _, r := range receipts {
+					names = append(names, r.Name)
 				}
-				sort.Strings(names)
 				fmt.Fprintln(os.Stdout, strings.Join(names, "\n"))
 				return nil
 			}
 
 			// print table
+			columns := []string{"PLUGIN", "VERSION", "INSTALLED"}
+			if *checkIntegrity {
+				columns = append(columns, "INTEGRITY")
+			}
 			var rows [][]string
-			for p, version := range plugins {
-				rows = append(rows, []string{p, version})
+			for i, r := range receipts {
+				name := r.Name
+				if bin := installation.BinName(r); bin != r.Name {
+					name += " (as " + bin + ")"
+				}
+				row := []string{name, r.Spec.Version, installed[r.Name].Local().Format("2006-01-02 15:04")}
+				if *checkIntegrity {
+					row = append(row, integrity[i].Status)
+				}
+				rows = append(rows, row)
+			}
+			for _, r := range kept {
+				row := []string{r.Name, r.Spec.Version, "not present"}
+				if *checkIntegrity {
+					row = append(row, "")
+				}
+				rows = append(rows, row)
 			}
-			rows = sortByFirstColumn(rows)
-			return printTable(os.Stdout, []string{"PLUGIN", "VERSION"}, rows)
+			return printTable(os.Stdout, columns, rows)
 		},
 		PreRunE: checkIndex,
 	}
 
+	indexName = listCmd.Flags().String("index", "", "only show plugins installed from the specified index")
+	sortBy = listCmd.Flags().String("sort", listSortName, "sort the plugins by one of: "+strings.Join(listSortOrders, ", "))
+	reverse = listCmd.Flags().Bool("reverse", false, "reverse the sort order")
+	orphaned = listCmd.Flags().Bool("orphaned", false, "only show plugins that are no longer in the plugin index")
+	checkIntegrity = listCmd.Flags().Bool("check-integrity", false, "check the installed files of each plugin against their recorded checksums")
+	output = listCmd.Flags().StringP("output", "o", "", "output format, one of: name, json")
 	rootCmd.AddCommand(listCmd)
 }
 
@@ -87,3 +220,178 @@ func sortByFirstColumn(rows [][]string) [][]string {
 	})
 	return rows
 }
+
+func isListSortOrder(s string) bool {
+	for _, v := range listSortOrders {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// sortReceipts sorts the receipts by the given listSortOrders key, which
+// orders install times most recent first. Receipts with equal keys are sorted
+// by name, also when the order is reversed.
+func sortReceipts(receipts []index.Receipt, by string, reverse bool, installed map[string]time.Time) {
+	sort.SliceStable(receipts, func(i, j int) bool {
+		a, b := receipts[i], receipts[j]
+		c := compareReceipts(a, b, by, installed)
+		if c == 0 {
+			return a.Name < b.Name
+		}
+		if reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// compareReceipts returns -1, 0 or 1 depending on whether a sorts before, the
+// same as, or after b by the given key. Versions that are not valid semantic
+// versions sort after valid ones.
+func compareReceipts(a, b index.Receipt, by string, installed map[string]time.Time) int {
+	switch by {
+	case listSortIndex:
+		return strings.Compare(receipt.SourceIndexName(a), receipt.SourceIndexName(b))
+	case listSortVersion:
+		va, errA := semver.Parse(a.Spec.Version)
+		vb, errB := semver.Parse(b.Spec.Version)
+		switch {
+		case errA != nil && errB != nil:
+			return strings.Compare(a.Spec.Version, b.Spec.Version)
+		case errA != nil:
+			return 1
+		case errB != nil:
+			return -1
+		case semver.Less(va, vb):
+			return -1
+		case semver.Less(vb, va):
+			return 1
+		}
+		return 0
+	case listSortInstalledAt:
+		ta, tb := installed[a.Name], installed[b.Name]
+		switch {
+		case ta.After(tb):
+			return -1
+		case tb.After(ta):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.Name, b.Name)
+}
+
+// filterBySourceIndex returns the receipts that record the given index as
+// their source.
+func filterBySourceIndex(receipts []index.Receipt, indexName string) []index.Receipt {
+	var out []index.Receipt
+	for _, r := range receipts {
+		if receipt.SourceIndexName(r) == indexName {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// isConfiguredIndex reports whether plugins can be installed from the index
+// with the given name, which includes constants.ManifestSourceName.
+func isConfiguredIndex(name string) bool {
+	return name == constants.DefaultIndexName || name == constants.ManifestSourceName
+}
+
+// orphanedReceipts returns the receipts of plugins installed from an index
+// whose manifest is no longer in the index, for the channel the plugin tracks,
+// and the number of receipts skipped because the plugin was installed from a
+// custom manifest.
+func orphanedReceipts(p environment.Paths, receipts []index.Receipt) ([]index.Receipt, int) {
+	var out []index.Receipt
+	var fromManifest int
+	for _, r := range receipts {
+		if receipt.IsCustomManifest(r) {
+			fromManifest++
+			continue
+		}
+		_, err := loadChannelPlugin(p, r.Name, r.Status.Source.Channel)
+		if os.IsNotExist(err) {
+			out = append(out, r)
+		} else if err != nil {
+			klog.Warningf("failed to load the plugin manifest for plugin %s: %v", r.Name, err)
+		}
+	}
+	return out, fromManifest
+}
+
+// checkReceiptsIntegrity checks the integrity of the installed plugins of the
+// receipts with the given number of workers. The results are in the order of
+// the receipts.
+func checkReceiptsIntegrity(receipts []index.Receipt, workers int) []installation.IntegrityResult {
+	results := make([]installation.IntegrityResult, len(receipts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				klog.V(3).Infof("Checking the integrity of plugin %s", receipts[i].Name)
+				results[i] = installation.CheckIntegrity(paths, receipts[i])
+			}
+		}()
+	}
+	for i := range receipts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// installedPlugin is an installed plugin in the JSON output of list.
+type installedPlugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	BinName string `json:"binName"`
+	Index   string `json:"index"`
+	// InstalledAt is not set for plugins that are not present.
+	InstalledAt *time.Time `json:"installedAt,omitempty"`
+	Present     bool       `json:"present"`
+	// Integrity and IntegrityProblems are only set with --check-integrity.
+	Integrity         string   `json:"integrity,omitempty"`
+	IntegrityProblems []string `json:"integrityProblems,omitempty"`
+}
+
+// printInstalledPluginsJSON writes the installed plugins of the receipts,
+// followed by those of the kept receipts, as a JSON array to out. integrity
+// is nil or has the results for the receipts.
+func printInstalledPluginsJSON(out io.Writer, receipts []index.Receipt, installed map[string]time.Time,
+	integrity []installation.IntegrityResult, kept []index.Receipt) error {
+	plugins := make([]installedPlugin, 0, len(receipts)+len(kept))
+	for i, r := range receipts {
+		t := installed[r.Name]
+		p := installedPlugin{
+			Name:        r.Name,
+			Version:     r.Spec.Version,
+			BinName:     installation.BinName(r),
+			Index:       receipt.SourceIndexName(r),
+			InstalledAt: &t,
+			Present:     true,
+		}
+		if integrity != nil {
+			p.Integrity, p.IntegrityProblems = integrity[i].Status, integrity[i].Problems
+		}
+		plugins = append(plugins, p)
+	}
+	for _, r := range kept {
+		plugins = append(plugins, installedPlugin{
+			Name:    r.Name,
+			Version: r.Spec.Version,
+			BinName: installation.BinName(r),
+			Index:   receipt.SourceIndexName(r),
+		})
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(plugins), "failed to write the installed plugins")
+}
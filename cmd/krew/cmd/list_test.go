@@ -0,0 +1,147 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func Test_sortReceipts(t *testing.T) {
+	now := time.Now()
+	installed := map[string]time.Time{
+		"old":     now.Add(-time.Hour),
+		"new":     now,
+		"b-equal": now.Add(-time.Minute),
+		"a-equal": now.Add(-time.Minute),
+	}
+	newReceipts := func() []index.Receipt {
+		return []index.Receipt{
+			receipt.New(testutil.NewPlugin().WithName("old").WithVersion("v1.10.0").V(), "foo"),
+			receipt.New(testutil.NewPlugin().WithName("b-equal").WithVersion("v1.2.0").V(), constants.DefaultIndexName),
+			receipt.New(testutil.NewPlugin().WithName("new").WithVersion("not-semver").V(), ""),
+			receipt.New(testutil.NewPlugin().WithName("a-equal").WithVersion("v1.2.0").V(), constants.DefaultIndexName),
+		}
+	}
+
+	tests := []struct {
+		by       string
+		reverse  bool
+		expected []string
+	}{
+		{by: listSortName, expected: []string{"a-equal", "b-equal", "new", "old"}},
+		{by: listSortName, reverse: true, expected: []string{"old", "new", "b-equal", "a-equal"}},
+		{by: listSortIndex, expected: []string{"new", "a-equal", "b-equal", "old"}},
+		{by: listSortIndex, reverse: true, expected: []string{"old", "a-equal", "b-equal", "new"}},
+		{by: listSortVersion, expected: []string{"a-equal", "b-equal", "old", "new"}},
+		{by: listSortVersion, reverse: true, expected: []string{"new", "old", "a-equal", "b-equal"}},
+		{by: listSortInstalledAt, expected: []string{"new", "a-equal", "b-equal", "old"}},
+		{by: listSortInstalledAt, reverse: true, expected: []string{"old", "a-equal", "b-equal", "new"}},
+	}
+	for _, tt := range tests {
+		receipts := newReceipts()
+		sortReceipts(receipts, tt.by, tt.reverse, installed)
+
+		var got []string
+		for _, r := range receipts {
+			got = append(got, r.Name)
+		}
+		if diff := cmp.Diff(tt.expected, got); diff != "" {
+			t.Errorf("sortReceipts(%q, reverse=%v) mismatch (-want +got):\n%s", tt.by, tt.reverse, diff)
+		}
+	}
+}
+
+func Test_orphanedReceipts(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+
+	for dir, name := range map[string]string{p.IndexPluginsPath(): "indexed", p.IndexChannelPath(betaChannel): "beta-only"} {
+		b, err := yaml.Marshal(testutil.NewPlugin().WithName(name).V())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+constants.ManifestExtension), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	beta := receipt.New(testutil.NewPlugin().WithName("beta-only").V(), constants.DefaultIndexName)
+	beta.Status.Source.Channel = betaChannel
+	stable := receipt.New(testutil.NewPlugin().WithName("beta-only").V(), constants.DefaultIndexName)
+
+	receipts := []index.Receipt{
+		beta,
+		receipt.New(testutil.NewPlugin().WithName("indexed").V(), constants.DefaultIndexName),
+		receipt.New(testutil.NewPlugin().WithName("removed").V(), constants.DefaultIndexName),
+		receipt.New(testutil.NewPlugin().WithName("custom").V(), ""),
+		{Plugin: testutil.NewPlugin().WithName("legacy").V()}, // written before the source was recorded
+	}
+	receipts = append(receipts, stable) // a stable receipt is not in the beta channel
+	orphaned, fromManifest := orphanedReceipts(p, receipts)
+
+	var got []string
+	for _, r := range orphaned {
+		got = append(got, r.Name)
+	}
+	if diff := cmp.Diff([]string{"removed", "legacy", "beta-only"}, got); diff != "" {
+		t.Errorf("orphanedReceipts() mismatch (-want +got):\n%s", diff)
+	}
+	if fromManifest != 1 {
+		t.Errorf("orphanedReceipts() skipped %d plugins installed from a manifest, want 1", fromManifest)
+	}
+}
+
+func Test_printInstalledPluginsJSON(t *testing.T) {
+	installedAt := time.Date(2019, 11, 1, 10, 0, 0, 0, time.UTC)
+	receipts := []index.Receipt{receipt.New(testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").V(), constants.DefaultIndexName)}
+	kept := []index.Receipt{receipt.New(testutil.NewPlugin().WithName("bar").WithVersion("v2.0.0").V(), "")}
+	integrity := []installation.IntegrityResult{{Status: installation.IntegrityMismatch, Problems: []string{`file "kubectl-foo" was modified`}}}
+
+	var buf bytes.Buffer
+	if err := printInstalledPluginsJSON(&buf, receipts, map[string]time.Time{"foo": installedAt}, integrity, kept); err != nil {
+		t.Fatal(err)
+	}
+	var got []installedPlugin
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []installedPlugin{
+		{Name: "foo", Version: "v1.0.0", BinName: "foo", Index: constants.DefaultIndexName, InstalledAt: &installedAt, Present: true,
+			Integrity: installation.IntegrityMismatch, IntegrityProblems: []string{`file "kubectl-foo" was modified`}},
+		{Name: "bar", Version: "v2.0.0", BinName: "bar", Index: constants.ManifestSourceName},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("printInstalledPluginsJSON() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/installation"
+)
+
+// progress events reported by the commands, in addition to the events of
+// the installation package
+const (
+	progressStart = "start" // the installation or upgrade of a plugin starts
+	progressDone  = "done"  // the plugin was installed or upgraded
+	progressError = "error" // the plugin could not be installed or upgraded
+)
+
+// progressEvent is a line written with --json-stream.
+type progressEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Plugin  string    `json:"plugin"`
+	Version string    `json:"version,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// progressStream writes progress events as JSON lines. A nil stream discards
+// the events.
+type progressStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// newProgressStream returns the stream for progress events, which is nil
+// unless --json-stream is set.
+func newProgressStream() *progressStream {
+	if !*jsonStream {
+		return nil
+	}
+	return newProgressStreamTo(os.Stdout)
+}
+
+func newProgressStreamTo(w io.Writer) *progressStream {
+	return &progressStream{enc: json.NewEncoder(w)}
+}
+
+// emit writes the event for the plugin at the version.
+func (s *progressStream) emit(event, plugin, version, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(progressEvent{
+		Time:    time.Now().UTC(),
+		Event:   event,
+		Plugin:  plugin,
+		Version: version,
+		Message: message,
+	}); err != nil {
+		klog.V(1).Infof("Failed to write progress event: %v", err)
+	}
+}
+
+// emitError writes the error event for the plugin at the version.
+func (s *progressStream) emitError(plugin, version string, err error) {
+	s.emit(progressError, plugin, version, err.Error())
+}
+
+// forPlugin returns the installation.ProgressFunc that writes the events of
+// installing the plugin at the version, or nil if the stream is nil.
+func (s *progressStream) forPlugin(plugin, version string) installation.ProgressFunc {
+	if s == nil {
+		return nil
+	}
+	return func(event string) { s.emit(event, plugin, version, "") }
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/installation"
+)
+
+func Test_progressStream(t *testing.T) {
+	var buf bytes.Buffer
+	s := newProgressStreamTo(&buf)
+	s.emit(progressStart, "foo", "v1.0.0", "")
+	s.forPlugin("foo", "v1.0.0")(installation.ProgressDownloaded)
+	s.emitError("foo", "v1.0.0", errors.New("checksum mismatch"))
+
+	var got []progressEvent
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e progressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e)
+	}
+	want := []progressEvent{
+		{Event: progressStart, Plugin: "foo", Version: "v1.0.0"},
+		{Event: installation.ProgressDownloaded, Plugin: "foo", Version: "v1.0.0"},
+		{Event: progressError, Plugin: "foo", Version: "v1.0.0", Message: "checksum mismatch"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(progressEvent{}, "Time")); diff != "" {
+		t.Errorf("progress events mismatch (-want +got):\n%s", diff)
+	}
+
+	// a nil stream discards events
+	var nilStream *progressStream
+	nilStream.emit(progressStart, "foo", "v1.0.0", "")
+	if nilStream.forPlugin("foo", "v1.0.0") != nil {
+		t.Error("expected no progress func for a nil stream")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var cache *bool
+
+	// pruneCmd represents the prune command
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove data that krew can recreate",
+		Long: `Remove data that krew can recreate to free disk space.
+
+To remove the cached downloads of plugins, which are otherwise reused when
+the same download is installed again:
+  kubectl krew prune --cache`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !*cache {
+				return errors.New("specify what to prune, e.g. --cache")
+			}
+			n, size, err := downloadCache().Prune()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Removed %d cached download(s), %.1f MiB.\n", n, float64(size)/(1<<20))
+			return nil
+		},
+	}
+
+	cache = pruneCmd.Flags().Bool("cache", false, "remove the cached downloads")
+	rootCmd.AddCommand(pruneCmd)
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/cmd/krew/cmd/internal"
+	"sigs.k8s.io/krew/internal/installation"
+)
+
+// reinstallCmd represents the reinstall command
+var reinstallCmd = &cobra.Command{
+	Use:   "reinstall",
+	Short: "Reinstall plugins uninstalled with --keep-receipt",
+	Long: `Reinstall plugins that were uninstalled with "uninstall --keep-receipt".
+
+The plugins are installed at the version and from the source recorded in
+their receipts, also if the plugin index has a newer version or no longer
+has the plugin.
+
+Example:
+  kubectl krew reinstall NAME [NAME...]`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := globalInstallOpts()
+		if err != nil {
+			return err
+		}
+		var failed []string
+		var returnErr error
+		for _, name := range args {
+			fmt.Fprintf(os.Stderr, "Reinstalling plugin: %s\n", name)
+			err := installation.Reinstall(paths, name, opts)
+			if err == installation.ErrIsAlreadyInstalled {
+				klog.Warningf("Skipping plugin %q, it is already installed", name)
+				continue
+			}
+			if err == installation.ErrNoKeptReceipt {
+				err = withExitCode(exitNotFound, errors.Errorf("plugin %q has no kept receipt, install it with \"kubectl krew install\"", name))
+			}
+			if err != nil {
+				klog.Warningf("failed to reinstall plugin %q: %v", name, err)
+				if returnErr == nil {
+					returnErr = withPlugin(name, err)
+				}
+				failed = append(failed, name)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Reinstalled plugin: %s\n", name)
+			internal.PrintSecurityNotice(name)
+
+			if err := runHook(hookInstall, name, installedVersion(name)); err != nil {
+				if returnErr == nil {
+					returnErr = withPlugin(name, err)
+				}
+				failed = append(failed, name)
+			}
+		}
+		if len(failed) > 0 {
+			return errors.Wrapf(returnErr, "failed to reinstall some plugins: %+v", failed)
+		}
+		return nil
+	},
+	PreRunE: checkIndex,
+	Args:    cobra.MinimumNArgs(1),
+}
+
+func init() {
+	rootCmd.AddCommand(reinstallCmd)
+}
@@ -15,9 +15,17 @@
 package cmd
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
@@ -25,6 +33,7 @@ import (
 	"github.com/spf13/pflag"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/krew/internal/download"
 	"sigs.k8s.io/krew/internal/environment"
 	"sigs.k8s.io/krew/internal/gitutil"
 	"sigs.k8s.io/krew/internal/installation"
@@ -35,14 +44,45 @@ import (
 
 var (
 	paths environment.Paths // krew paths used by the process
+
+	downloadHosts   *[]string   // download host remaps specified with --download-host
+	offline         *pflag.Flag // --offline, set to never access the network
+	userAgent       *string     // User-Agent for downloads specified with --user-agent
+	downloadHeaders *[]string   // extra download headers specified with --download-header
+	maxDownloadRate *string     // per-download rate limit specified with --max-download-rate
+	jsonErrors      *bool       // set with --json-errors to write failures as JSON
+	jsonStream      *bool       // set with --json-stream to write progress events as JSON lines
 )
 
+// offlineEnv enables the offline mode if set to a true value.
+const offlineEnv = "KREW_OFFLINE"
+
+// downloadHostsEnv is a comma-separated list of download host remaps in the
+// same FROM=TO format as the --download-host flag.
+const downloadHostsEnv = "KREW_DOWNLOAD_HOSTS"
+
+// userAgentEnv overrides the User-Agent sent with downloads.
+const userAgentEnv = "KREW_USER_AGENT"
+
+// downloadHeadersEnv is a newline-separated list of extra headers sent with
+// downloads, in the same "Name: value" format as the --download-header flag.
+const downloadHeadersEnv = "KREW_DOWNLOAD_HEADERS"
+
+// maxDownloadRateEnv limits the rate of each download, in the same format as
+// the --max-download-rate flag.
+const maxDownloadRateEnv = "KREW_MAX_DOWNLOAD_RATE"
+
+// defaultIndexURIEnv overrides the git remote the plugin index is cloned and
+// updated from, for example to use a mirror of the default index.
+const defaultIndexURIEnv = "KREW_DEFAULT_INDEX_URI"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "krew",
 	Short: "krew is the kubectl plugin manager",
 	Long: `krew is the kubectl plugin manager.
-You can invoke krew through kubectl: "kubectl krew [command]..."`,
+You can invoke krew through kubectl: "kubectl krew [command]..."
+` + exitCodeHelp,
 	SilenceUsage:      true,
 	SilenceErrors:     true,
 	PersistentPreRunE: preRun,
@@ -52,11 +92,20 @@ You can invoke krew through kubectl: "kubectl krew [command]..."`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		if klog.V(1) {
-			klog.Fatalf("%+v", err) // with stack trace
+		if isReadOnlyRootError(err, paths.BasePath()) {
+			err = readOnlyRootError(err)
+		}
+		if *jsonErrors {
+			if jerr := writeJSONError(os.Stderr, err); jerr != nil {
+				klog.Error(err)
+			}
+		} else if klog.V(1) {
+			klog.Errorf("%+v", err) // with stack trace
 		} else {
-			klog.Fatal(err) // just error message
+			klog.Error(err) // just error message
 		}
+		klog.Flush()
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -76,16 +125,51 @@ func init() {
 		os.Exit(1)
 	}
 
+	downloadHosts = rootCmd.PersistentFlags().StringArray("download-host", nil,
+		"download from host TO instead of FROM, in the form FROM=TO (can be repeated, also read from "+downloadHostsEnv+")")
+
+	rootCmd.PersistentFlags().Bool("offline", false,
+		"never access the network, fail commands that need it (also enabled with "+offlineEnv+"=1)")
+	offline = rootCmd.PersistentFlags().Lookup("offline")
+
+	userAgent = rootCmd.PersistentFlags().String("user-agent", "",
+		"User-Agent sent with downloads, defaults to krew/<version> (also read from "+userAgentEnv+")")
+
+	downloadHeaders = rootCmd.PersistentFlags().StringArray("download-header", nil,
+		"extra header sent with downloads, in the form \"Name: value\" (can be repeated, also read from "+downloadHeadersEnv+")")
+
+	maxDownloadRate = rootCmd.PersistentFlags().String("max-download-rate", "",
+		"limit each download to the specified rate, e.g. 5MB/s or 512KiB/s, unlimited by default (also read from "+maxDownloadRateEnv+")")
+
+	jsonErrors = rootCmd.PersistentFlags().Bool("json-errors", false,
+		"write failures to stderr as a JSON object with the exit code, error category, plugin and message")
+
+	jsonStream = rootCmd.PersistentFlags().Bool("json-stream", false,
+		"write the progress of installs and upgrades to stdout as JSON lines, one per event")
+
+	configFile = rootCmd.PersistentFlags().String("config-file", "",
+		"read the config from the specified file instead of config.yaml in the krew root (also read from "+configEnv+")")
+	rootCmd.PersistentFlags().StringVar(configFile, "config", "", "same as --config-file")
+
 	paths = environment.MustGetKrewPaths()
 	if err := ensureDirs(paths.BasePath(),
+		paths.CachePath(),
 		paths.InstallPath(),
 		paths.BinPath(),
 		paths.InstallReceiptsPath()); err != nil {
+		if isReadOnlyRootError(err, paths.BasePath()) {
+			err = readOnlyRootError(err)
+		}
 		klog.Fatal(err)
 	}
 }
 
 func preRun(cmd *cobra.Command, _ []string) error {
+	var err error
+	if cfg, err = loadConfig(configPath()); err != nil {
+		return err
+	}
+
 	// detect if receipts migration (v0.2.x->v0.3.x) is complete
 	isMigrated, err := receiptsmigration.Done(paths)
 	if err != nil {
@@ -122,6 +206,9 @@ func cleanupStaleKrewInstallations() error {
 }
 
 func checkIndex(_ *cobra.Command, _ []string) error {
+	if isShallowIndex(paths) {
+		return nil
+	}
 	if ok, err := gitutil.IsGitCloned(paths.IndexPath()); err != nil {
 		return errors.Wrap(err, "failed to check local index git repository")
 	} else if !ok {
@@ -130,6 +217,230 @@ func checkIndex(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// checkIndexPlugins is like checkIndex, but also fails if the index has no
+// plugin manifests (e.g. because they were wiped).
+func checkIndexPlugins(cmd *cobra.Command, args []string) error {
+	if err := checkIndex(cmd, args); err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(paths.IndexPluginsPath())
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read local plugin index")
+	}
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == constants.ManifestExtension {
+			return nil
+		}
+	}
+	return errors.New(`krew local plugin index has no plugin manifests (run "kubectl krew update")`)
+}
+
+// ensureIndex is like checkIndexPlugins, but if the index is missing and krew
+// is used interactively, it offers to update the index instead of failing.
+func ensureIndex(cmd *cobra.Command, args []string) error {
+	err := checkIndexPlugins(cmd, args)
+	if err == nil || isOffline() || !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		return err
+	}
+	klog.V(1).Infof("%v", err)
+	if !confirm(os.Stdin, os.Stderr, "The local copy of plugin index is missing. Update it now?") {
+		return err
+	}
+	return ensureIndexUpdated(cmd, args)
+}
+
+// confirm asks the question on out and reports whether the answer read from
+// in is yes.
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// isOffline reports whether the offline mode is enabled with the --offline
+// flag, the environment or the config file. An explicit --offline=false turns
+// it off.
+func isOffline() bool {
+	if offline.Changed {
+		v, _ := strconv.ParseBool(offline.Value.String())
+		return v
+	}
+	if env := os.Getenv(offlineEnv); env != "" {
+		v, _ := strconv.ParseBool(env)
+		return v
+	}
+	return cfg.Offline
+}
+
+// updateNoticeDisabled reports whether the notice about a newer version of
+// krew is turned off with noUpdateNoticeEnv or the config file.
+func updateNoticeDisabled() bool {
+	if env := os.Getenv(noUpdateNoticeEnv); env != "" {
+		v, _ := strconv.ParseBool(env)
+		return v
+	}
+	return cfg.NoUpdateNotice
+}
+
+// defaultIndexURI returns the git remote of the plugin index, which is
+// constants.IndexURI unless overridden with defaultIndexURIEnv or the config
+// file.
+func defaultIndexURI() (string, error) {
+	uri := strings.TrimSpace(os.Getenv(defaultIndexURIEnv))
+	if uri == "" {
+		uri = cfg.DefaultIndexURI
+	}
+	if uri == "" {
+		return constants.IndexURI, nil
+	}
+	if err := gitutil.ValidateURL(uri); err != nil {
+		return "", errors.Wrapf(err, "invalid %s", defaultIndexURIEnv)
+	}
+	return uri, nil
+}
+
+// globalInstallOpts returns the installation options set with global flags.
+func globalInstallOpts() (installation.InstallOpts, error) {
+	hosts, err := downloadHostRemap()
+	if err != nil {
+		return installation.InstallOpts{}, err
+	}
+	fetcher, err := httpFetcher()
+	if err != nil {
+		return installation.InstallOpts{}, err
+	}
+	return installation.InstallOpts{
+		DownloadHosts: hosts,
+		Offline:       isOffline(),
+		HTTPFetcher:   fetcher,
+		DownloadCache: downloadCache(),
+	}, nil
+}
+
+// downloadCache returns the cache of downloads, trimmed to the size from the
+// config file.
+func downloadCache() *download.Cache {
+	sizeMB := cfg.DownloadCacheSizeMB
+	if sizeMB == 0 {
+		sizeMB = defaultDownloadCacheSizeMB
+	}
+	return download.NewCache(paths.DownloadCachePath(), int64(sizeMB)<<20)
+}
+
+// httpFetcher returns the fetcher for downloads with the User-Agent and extra
+// headers from the config file, the environment and the flags, where the flags
+// take precedence over the environment and the config file.
+func httpFetcher() (download.HTTPFetcher, error) {
+	ua := cfg.UserAgent
+	if env := os.Getenv(userAgentEnv); env != "" {
+		ua = env
+	}
+	if *userAgent != "" {
+		ua = *userAgent
+	}
+
+	values := append([]string(nil), cfg.DownloadHeaders...)
+	if env := os.Getenv(downloadHeadersEnv); env != "" {
+		values = append(values, strings.Split(env, "\n")...)
+	}
+	values = append(values, *downloadHeaders...)
+
+	header, err := parseDownloadHeaders(values)
+	if err != nil {
+		return download.HTTPFetcher{}, err
+	}
+
+	rate := cfg.MaxDownloadRate
+	if env := os.Getenv(maxDownloadRateEnv); env != "" {
+		rate = env
+	}
+	if *maxDownloadRate != "" {
+		rate = *maxDownloadRate
+	}
+	var maxRate int64
+	if rate != "" {
+		if maxRate, err = download.ParseRate(rate); err != nil {
+			return download.HTTPFetcher{}, err
+		}
+	}
+	return download.HTTPFetcher{UserAgent: ua, Header: header, MaxRate: maxRate}, nil
+}
+
+// parseDownloadHeaders parses headers in the "Name: value" format, where later
+// values override earlier ones of the same header.
+func parseDownloadHeaders(values []string) (http.Header, error) {
+	header := make(http.Header, len(values))
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		parts := strings.SplitN(v, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, errors.Errorf("invalid download header %q, expected \"Name: value\"", v)
+		}
+		header.Set(name, strings.TrimSpace(parts[1]))
+	}
+	return header, nil
+}
+
+// downloadHostRemap returns the download host remaps from the config file, the
+// environment and the --download-host flags, where the flags take precedence
+// over the environment and the config file.
+func downloadHostRemap() (map[string]string, error) {
+	values := append([]string(nil), cfg.DownloadHosts...)
+	if env := os.Getenv(downloadHostsEnv); env != "" {
+		values = append(values, strings.Split(env, ",")...)
+	}
+	values = append(values, *downloadHosts...)
+	return parseDownloadHosts(values)
+}
+
+// parseDownloadHosts parses host remaps in the FROM=TO format, where later
+// values override earlier ones for the same host.
+func parseDownloadHosts(values []string) (map[string]string, error) {
+	hosts := make(map[string]string, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(v, "/") {
+			return nil, errors.Errorf("invalid download host remap %q, expected FROM=TO (e.g. github.com=mirror.example.com)", v)
+		}
+		hosts[parts[0]] = parts[1]
+	}
+	return hosts, nil
+}
+
+// isReadOnlyRootError reports whether err is caused by a file operation that
+// failed because basePath is on a read-only file system or is not writable.
+func isReadOnlyRootError(err error, basePath string) bool {
+	var path string
+	var errno error
+	switch e := errors.Cause(err).(type) {
+	case *os.PathError:
+		path, errno = e.Path, e.Err
+	case *os.LinkError:
+		path, errno = e.New, e.Err
+	default:
+		return false
+	}
+	if errno == syscall.EROFS {
+		return true
+	}
+	rel, relErr := filepath.Rel(basePath, path)
+	return os.IsPermission(errno) && relErr == nil && !strings.HasPrefix(rel, "..")
+}
+
+// readOnlyRootError wraps err to explain that KREW_ROOT cannot be written to.
+func readOnlyRootError(err error) error {
+	return errors.Wrapf(err, "KREW_ROOT (%s) is read-only, only commands that do not "+
+		"change it (such as search, list and info) work, with --no-update-index or --offline where applicable", paths.BasePath())
+}
+
 func ensureDirs(paths ...string) error {
 	for _, p := range paths {
 		klog.V(4).Infof("Ensure creating dir: %q", p)
@@ -0,0 +1,262 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func Test_downloadHostRemap(t *testing.T) {
+	defer func(orig []string) { *downloadHosts = orig }(*downloadHosts)
+	defer os.Unsetenv(downloadHostsEnv)
+
+	os.Setenv(downloadHostsEnv, "github.com=env.internal, gitlab.com=gitlab.internal")
+	*downloadHosts = []string{"github.com=flag.internal"}
+	got, err := downloadHostRemap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"github.com": "flag.internal",
+		"gitlab.com": "gitlab.internal",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("downloadHostRemap() mismatch:\n%s", diff)
+	}
+
+	os.Unsetenv(downloadHostsEnv)
+	for _, invalid := range []string{"github.com", "=mirror", "github.com=", "https://github.com=mirror"} {
+		*downloadHosts = []string{invalid}
+		if _, err := downloadHostRemap(); err == nil {
+			t.Errorf("expected error for invalid remap %q", invalid)
+		}
+	}
+}
+
+func Test_httpFetcher(t *testing.T) {
+	defer func(orig []string) { *downloadHeaders = orig }(*downloadHeaders)
+	defer func(orig string) { *userAgent = orig }(*userAgent)
+	defer os.Unsetenv(downloadHeadersEnv)
+	defer os.Unsetenv(userAgentEnv)
+
+	os.Setenv(userAgentEnv, "env-agent")
+	os.Setenv(downloadHeadersEnv, "X-Team: platform\nX-Api-Key: from-env\n")
+	*downloadHeaders = []string{"X-Api-Key: from-flag"}
+	got, err := httpFetcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := download.HTTPFetcher{
+		UserAgent: "env-agent",
+		Header:    http.Header{"X-Team": {"platform"}, "X-Api-Key": {"from-flag"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("httpFetcher() mismatch:\n%s", diff)
+	}
+
+	*userAgent = "flag-agent"
+	if got, err := httpFetcher(); err != nil {
+		t.Fatal(err)
+	} else if got.UserAgent != "flag-agent" {
+		t.Errorf("UserAgent = %q, expected the flag to take precedence", got.UserAgent)
+	}
+
+	os.Unsetenv(downloadHeadersEnv)
+	for _, invalid := range []string{"X-Api-Key", ": value", "X Api Key: value"} {
+		*downloadHeaders = []string{invalid}
+		if _, err := httpFetcher(); err == nil {
+			t.Errorf("expected error for invalid header %q", invalid)
+		}
+	}
+}
+
+func Test_httpFetcher_maxRate(t *testing.T) {
+	defer func(orig string) { *maxDownloadRate = orig }(*maxDownloadRate)
+	defer func(orig config) { cfg = orig }(cfg)
+	defer os.Unsetenv(maxDownloadRateEnv)
+
+	if got, err := httpFetcher(); err != nil || got.MaxRate != 0 {
+		t.Errorf("httpFetcher() MaxRate = %d, %v, expected unlimited by default", got.MaxRate, err)
+	}
+	cfg.MaxDownloadRate = "1MB/s"
+	os.Setenv(maxDownloadRateEnv, "2MB/s")
+	if got, err := httpFetcher(); err != nil || got.MaxRate != 2000000 {
+		t.Errorf("httpFetcher() MaxRate = %d, %v, expected %s to override the config file", got.MaxRate, err, maxDownloadRateEnv)
+	}
+	*maxDownloadRate = "5MB/s"
+	if got, err := httpFetcher(); err != nil || got.MaxRate != 5000000 {
+		t.Errorf("httpFetcher() MaxRate = %d, %v, expected the flag to take precedence", got.MaxRate, err)
+	}
+	*maxDownloadRate = "fast"
+	if _, err := httpFetcher(); err == nil {
+		t.Error("expected error for an invalid download rate")
+	}
+}
+
+func Test_isOffline(t *testing.T) {
+	defer func() { offline.Changed = false; offline.Value.Set("false") }()
+	defer func(c config) { cfg = c }(cfg)
+	defer os.Unsetenv(offlineEnv)
+
+	tests := []struct {
+		flag   string // value of --offline, or empty if not specified
+		env    string
+		config bool
+		want   bool
+	}{
+		{flag: "", env: "", want: false},
+		{flag: "true", env: "", want: true},
+		{flag: "", env: "1", want: true},
+		{flag: "", env: "true", want: true},
+		{flag: "", env: "0", want: false},
+		{flag: "", env: "not-a-bool", want: false},
+		{flag: "", env: "", config: true, want: true},
+		{flag: "false", env: "", config: true, want: false},
+		{flag: "false", env: "1", want: false},
+		{flag: "", env: "0", config: true, want: false},
+	}
+	for _, tt := range tests {
+		offline.Changed = tt.flag != ""
+		offline.Value.Set("false")
+		if tt.flag != "" {
+			offline.Value.Set(tt.flag)
+		}
+		cfg = config{Offline: tt.config}
+		os.Setenv(offlineEnv, tt.env)
+		if got := isOffline(); got != tt.want {
+			t.Errorf("isOffline() with flag=%q env=%q config=%v = %v, want %v", tt.flag, tt.env, tt.config, got, tt.want)
+		}
+	}
+}
+
+func Test_updateNoticeDisabled(t *testing.T) {
+	defer func(c config) { cfg = c }(cfg)
+	defer os.Unsetenv(noUpdateNoticeEnv)
+
+	tests := []struct {
+		config bool
+		env    string
+		want   bool
+	}{
+		{config: false, env: "", want: false},
+		{config: true, env: "", want: true},
+		{config: false, env: "1", want: true},
+		{config: true, env: "0", want: false},
+	}
+	for _, tt := range tests {
+		cfg = config{NoUpdateNotice: tt.config}
+		os.Setenv(noUpdateNoticeEnv, tt.env)
+		if got := updateNoticeDisabled(); got != tt.want {
+			t.Errorf("updateNoticeDisabled() with config=%v env=%q = %v, want %v", tt.config, tt.env, got, tt.want)
+		}
+	}
+}
+
+func Test_defaultIndexURI(t *testing.T) {
+	defer os.Unsetenv(defaultIndexURIEnv)
+
+	os.Unsetenv(defaultIndexURIEnv)
+	if got, err := defaultIndexURI(); err != nil || got != constants.IndexURI {
+		t.Errorf("defaultIndexURI() = (%q, %v), want (%q, nil)", got, err, constants.IndexURI)
+	}
+
+	mirror := "https://git.example.com/mirrors/krew-index.git"
+	os.Setenv(defaultIndexURIEnv, mirror)
+	if got, err := defaultIndexURI(); err != nil || got != mirror {
+		t.Errorf("defaultIndexURI() = (%q, %v), want (%q, nil)", got, err, mirror)
+	}
+
+	os.Setenv(defaultIndexURIEnv, "ftp://example.com/index")
+	if _, err := defaultIndexURI(); err == nil {
+		t.Errorf("expected error for an invalid %s", defaultIndexURIEnv)
+	}
+}
+
+func Test_isReadOnlyRootError(t *testing.T) {
+	base := filepath.FromSlash("/krew/root")
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"read-only file system", errors.Wrap(&os.PathError{Op: "open", Path: "/elsewhere", Err: syscall.EROFS}, "install"), true},
+		{"permission denied in root", &os.PathError{Op: "mkdir", Path: filepath.Join(base, "store", "foo"), Err: syscall.EACCES}, true},
+		{"link in root", &os.LinkError{Op: "symlink", Old: "/tmp/x", New: filepath.Join(base, "bin", "kubectl-foo"), Err: syscall.EPERM}, true},
+		{"permission denied elsewhere", &os.PathError{Op: "open", Path: "/etc/foo", Err: syscall.EACCES}, false},
+		{"other file error in root", &os.PathError{Op: "open", Path: filepath.Join(base, "foo"), Err: syscall.ENOENT}, false},
+		{"other error", errors.New("failed"), false},
+	}
+	for _, tt := range tests {
+		if got := isReadOnlyRootError(tt.err, base); got != tt.want {
+			t.Errorf("%s: isReadOnlyRootError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_checkIndexPlugins(t *testing.T) {
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	paths = environment.NewPaths(tmpDir.Root())
+
+	if err := checkIndexPlugins(nil, nil); err == nil || !strings.Contains(err.Error(), "not initialized") {
+		t.Errorf("expected error for a missing index, got: %v", err)
+	}
+	tmpDir.Write("index/.git/HEAD", nil)
+	if err := checkIndexPlugins(nil, nil); err == nil || !strings.Contains(err.Error(), "kubectl krew update") {
+		t.Errorf("expected error for an index without plugins directory, got: %v", err)
+	}
+	tmpDir.Write("index/plugins/README.md", nil)
+	if err := checkIndexPlugins(nil, nil); err == nil {
+		t.Error("expected error for an index without plugin manifests")
+	}
+	tmpDir.Write("index/plugins/foo"+constants.ManifestExtension, nil)
+	if err := checkIndexPlugins(nil, nil); err != nil {
+		t.Errorf("expected no error for an index with plugin manifests, got: %v", err)
+	}
+}
+
+func Test_confirm(t *testing.T) {
+	for answer, want := range map[string]bool{
+		"y\n":   true,
+		"Yes\n": true,
+		"n\n":   false,
+		"\n":    false,
+		"":      false,
+		"yep\n": false,
+	} {
+		var out bytes.Buffer
+		if got := confirm(strings.NewReader(answer), &out, "Continue?"); got != want {
+			t.Errorf("confirm() with answer %q = %v, want %v", answer, got, want)
+		}
+		if out.String() != "Continue? [y/N] " {
+			t.Errorf("confirm() wrote %q", out.String())
+		}
+	}
+}
@@ -15,84 +15,436 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"github.com/sahilm/fuzzy"
 	"github.com/spf13/cobra"
 
 	"sigs.k8s.io/krew/internal/index/indexscanner"
 	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
 
-// searchCmd represents the search command
-var searchCmd = &cobra.Command{
-	Use:   "search",
-	Short: "Discover kubectl plugins",
-	Long: `List kubectl plugins available on krew and search among them.
+func init() {
+	var tags *[]string
+	var noColor *bool
+	var output *string
+	var fields *[]string
+	var limit *int
+
+	// searchCmd represents the search command
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "Discover kubectl plugins",
+		Long: `List kubectl plugins available on krew and search among them.
 If no arguments are provided, all plugins will be listed.
 
 Examples:
   To list all plugins:
     kubectl krew search
 
-  To fuzzy search plugins with a keyword:
-    kubectl krew search KEYWORD`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		plugins, err := indexscanner.LoadPluginListFromFS(paths.IndexPluginsPath())
-		if err != nil {
-			return errors.Wrap(err, "failed to load the list of plugins from the index")
+  To fuzzy search plugins with a keyword (also matches plugin descriptions):
+    kubectl krew search KEYWORD
+
+  To only match the keyword against plugin names (or only descriptions):
+    kubectl krew search KEYWORD --fields name
+
+  To list plugins with a tag (can be repeated to require multiple tags):
+    kubectl krew search --tag security
+
+  To print the matching plugins as JSON:
+    kubectl krew search KEYWORD -o json
+
+  To only show the 10 best matches (the most relevant name matches are kept):
+    kubectl krew search KEYWORD --limit 10
+  With -o json, the results are then wrapped in an object with the total
+  number of matches.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *output != "" && *output != "json" {
+				return errors.Errorf("unsupported output format %q, only \"json\" is supported", *output)
+			}
+			if *limit < 0 {
+				return errors.New("--limit must not be negative")
+			}
+			searchIn, err := parseSearchFields(*fields)
+			if err != nil {
+				return err
+			}
+
+			lock, err := lockIndexShared()
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			plugins, err := indexscanner.LoadPluginListFromFS(paths.IndexPluginsPath())
+			if err != nil {
+				return errors.Wrap(err, "failed to load the list of plugins from the index")
+			}
+			pluginMap := make(map[string]index.Plugin, len(plugins))
+			for _, p := range plugins {
+				pluginMap[p.Name] = p
+			}
+			names := filterByTags(plugins, *tags)
+
+			receipts, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
+			if err != nil {
+				return errors.Wrap(err, "failed to load installed plugins")
+			}
+			installed := make(map[string]bool, len(receipts))
+			for _, r := range receipts {
+				installed[r.Name] = true
+			}
+
+			keyword := strings.Join(args, " ")
+			matches := searchPlugins(keyword, names, pluginMap, searchIn)
+			total := len(matches)
+			shown := sortedMatchNames(matches)
+			if *limit > 0 {
+				shown = limitMatches(matches, *limit)
+			}
+
+			if *output == "json" {
+				if *limit > 0 {
+					return printSearchResultsPageJSON(os.Stdout, shown, matches, total, pluginMap)
+				}
+				return printSearchResultsJSON(os.Stdout, shown, matches, pluginMap)
+			}
+			if len(shown) < total {
+				defer fmt.Fprintf(os.Stderr, "Showing %d of %d matching plugins, use --limit to show more.\n", len(shown), total)
+			}
+
+			// No plugins found
+			if len(shown) == 0 {
+				return nil
+			}
+
+			var rows [][]string
+			cols := []string{"NAME", "DESCRIPTION", "INSTALLED"}
+			for _, name := range shown {
+				plugin := pluginMap[name]
+				var status string
+				if installed[name] {
+					status = "yes"
+				} else if _, ok, err := installation.GetMatchingPlatform(plugin.Spec.Platforms); err != nil {
+					return errors.Wrapf(err, "failed to get the matching platform for plugin %s", name)
+				} else if ok {
+					status = "no"
+				} else {
+					status = "unavailable on " + runtime.GOOS
+				}
+				rows = append(rows, []string{name, limitString(plugin.Spec.ShortDescription, 50), status})
+			}
+			if *noColor {
+				color.NoColor = true
+			}
+			if keyword == "" || color.NoColor {
+				return printTable(os.Stdout, cols, rows)
+			}
+
+			var b bytes.Buffer
+			if err := printTable(&b, cols, rows); err != nil {
+				return err
+			}
+			hl := color.New(color.FgYellow, color.Bold).SprintFunc()
+			_, err = fmt.Fprint(os.Stdout, highlightTable(b.String(), cols, rows, func(row, col int) string {
+				cell := rows[row][col]
+				switch col {
+				case 0:
+					return highlightIndexes(cell, matches[cell].indexes, hl)
+				case 1:
+					if searchIn.description {
+						return highlightSubstring(cell, keyword, hl)
+					}
+				}
+				return cell
+			}))
+			return err
+		},
+		PreRunE: ensureIndex,
+	}
+
+	tags = searchCmd.Flags().StringArray("tag", nil, "only show plugins with the specified tag (can be repeated)")
+	noColor = searchCmd.Flags().Bool("no-color", false, "do not highlight the matches in the output")
+	output = searchCmd.Flags().StringP("output", "o", "", "output format, one of: json")
+	limit = searchCmd.Flags().Int("limit", 0, "only show the specified number of best matching plugins, best match first")
+	fields = searchCmd.Flags().StringSlice("fields", []string{searchFieldName, searchFieldDescription}, "plugin fields to match the keyword against, any of: name, description")
+	rootCmd.AddCommand(searchCmd)
+}
+
+// filterByTags returns the names of the plugins that have all the specified
+// tags. Tags are matched case-insensitively.
+func filterByTags(plugins []index.Plugin, tags []string) []string {
+	// tag -> names of plugins with that tag
+	tagIndex := make(map[string]map[string]bool)
+	names := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		names = append(names, p.Name)
+		for _, tag := range p.Spec.Tags {
+			tag = strings.ToLower(tag)
+			if tagIndex[tag] == nil {
+				tagIndex[tag] = make(map[string]bool)
+			}
+			tagIndex[tag][p.Name] = true
 		}
-		names := make([]string, len(plugins))
-		pluginMap := make(map[string]index.Plugin, len(plugins))
-		for i, p := range plugins {
-			names[i] = p.Name
-			pluginMap[p.Name] = p
+	}
+	if len(tags) == 0 {
+		return names
+	}
+
+	var out []string
+	for _, name := range names {
+		hasAll := true
+		for _, tag := range tags {
+			if !tagIndex[strings.ToLower(tag)][name] {
+				hasAll = false
+				break
+			}
+		}
+		if hasAll {
+			out = append(out, name)
 		}
+	}
+	return out
+}
+
+// fields the search keyword can be matched against
+const (
+	searchFieldName        = "name"
+	searchFieldDescription = "description"
+)
 
-		installed, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
-		if err != nil {
-			return errors.Wrap(err, "failed to load installed plugins")
+// searchFields are the plugin fields that are matched against the keyword.
+type searchFields struct {
+	name, description bool
+}
+
+// parseSearchFields parses the values of the --fields flag.
+func parseSearchFields(values []string) (searchFields, error) {
+	var f searchFields
+	for _, v := range values {
+		switch strings.TrimSpace(v) {
+		case searchFieldName:
+			f.name = true
+		case searchFieldDescription:
+			f.description = true
+		default:
+			return searchFields{}, errors.Errorf("unsupported search field %q, must be one of: %s, %s", v, searchFieldName, searchFieldDescription)
 		}
+	}
+	if !f.name && !f.description {
+		return searchFields{}, errors.New("--fields must specify at least one field to search")
+	}
+	return f, nil
+}
 
-		var matchNames []string
-		if len(args) > 0 {
-			matches := fuzzy.Find(strings.Join(args, ""), names)
-			for _, m := range matches {
-				matchNames = append(matchNames, m.Str)
-			}
+// searchMatch describes how a plugin matched the search keyword.
+type searchMatch struct {
+	// indexes of the characters in the plugin name that matched
+	indexes []int
+	// rank is the relevance of a fuzzy name match among all fuzzy matches,
+	// starting at 1 for the most relevant match
+	rank *int
+}
+
+// searchPlugins returns the plugins among names that match the keyword in the
+// given fields. Plugins are fuzzy-matched by name, and also match if their
+// short description contains the keyword, in which case no characters of the
+// name are matched.
+func searchPlugins(keyword string, names []string, plugins map[string]index.Plugin, fields searchFields) map[string]searchMatch {
+	out := make(map[string]searchMatch)
+	if keyword == "" {
+		for _, name := range names {
+			out[name] = searchMatch{}
+		}
+		return out
+	}
+
+	if fields.name {
+		// fuzzy.Find returns the matches ordered by descending relevance
+		for i, m := range fuzzy.Find(strings.ReplaceAll(keyword, " ", ""), names) {
+			rank := i + 1
+			out[m.Str] = searchMatch{indexes: m.MatchedIndexes, rank: &rank}
+		}
+	}
+	if !fields.description {
+		return out
+	}
+	lowerKeyword := strings.ToLower(keyword)
+	for _, name := range names {
+		if _, ok := out[name]; ok {
+			continue
+		}
+		if strings.Contains(strings.ToLower(plugins[name].Spec.ShortDescription), lowerKeyword) {
+			out[name] = searchMatch{}
+		}
+	}
+	return out
+}
+
+// searchResult is a plugin matching the search in the JSON output.
+type searchResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Homepage    string `json:"homepage"`
+	Version     string `json:"version"`
+	Index       string `json:"index"`
+	// Rank is only set for fuzzy matches on the plugin name.
+	Rank *int `json:"rank,omitempty"`
+}
+
+// limitMatches returns the names of the n most relevant matches, most relevant
+// first: fuzzy name matches by rank, followed by the other matches by name.
+func limitMatches(matches map[string]searchMatch, n int) []string {
+	names := sortedMatchNames(matches)
+	sort.SliceStable(names, func(i, j int) bool {
+		ri, rj := matches[names[i]].rank, matches[names[j]].rank
+		if ri == nil || rj == nil {
+			return ri != nil && rj == nil
+		}
+		return *ri < *rj
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// printSearchResultsJSON writes the named matching plugins in the given order
+// as a JSON array to out.
+func printSearchResultsJSON(out io.Writer, names []string, matches map[string]searchMatch, plugins map[string]index.Plugin) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(searchResults(names, matches, plugins)), "failed to write search results")
+}
+
+// searchResultsPage is the JSON output of a search with --limit.
+type searchResultsPage struct {
+	// Total is the number of matches before they were limited.
+	Total   int            `json:"total"`
+	Results []searchResult `json:"results"`
+}
+
+// printSearchResultsPageJSON writes the named matching plugins like
+// printSearchResultsJSON, wrapped in an object with the total number of
+// matches.
+func printSearchResultsPageJSON(out io.Writer, names []string, matches map[string]searchMatch, total int, plugins map[string]index.Plugin) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	page := searchResultsPage{Total: total, Results: searchResults(names, matches, plugins)}
+	return errors.Wrap(enc.Encode(page), "failed to write search results")
+}
+
+func searchResults(names []string, matches map[string]searchMatch, plugins map[string]index.Plugin) []searchResult {
+	results := []searchResult{}
+	for _, name := range names {
+		p := plugins[name]
+		results = append(results, searchResult{
+			Name:        name,
+			Description: p.Spec.ShortDescription,
+			Homepage:    p.Spec.Homepage,
+			Version:     p.Spec.Version,
+			Index:       constants.DefaultIndexName,
+			Rank:        matches[name].rank,
+		})
+	}
+	return results
+}
+
+func sortedMatchNames(matches map[string]searchMatch) []string {
+	names := make([]string, 0, len(matches))
+	for name := range matches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// highlightIndexes highlights the characters of s at the given byte indexes.
+func highlightIndexes(s string, indexes []int, hl func(a ...interface{}) string) string {
+	if len(indexes) == 0 {
+		return s
+	}
+	matched := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		matched[i] = true
+	}
+	var b strings.Builder
+	for i, r := range s {
+		if matched[i] {
+			b.WriteString(hl(string(r)))
 		} else {
-			matchNames = names
+			b.WriteRune(r)
 		}
+	}
+	return b.String()
+}
 
-		// No plugins found
-		if len(matchNames) == 0 {
-			return nil
+// highlightSubstring highlights all case-insensitive occurrences of substr in s.
+func highlightSubstring(s, substr string, hl func(a ...interface{}) string) string {
+	if substr == "" {
+		return s
+	}
+	lower, lowerSubstr := strings.ToLower(s), strings.ToLower(substr)
+	if len(lower) != len(s) {
+		// case folding changed the byte offsets, don't risk a corrupt output
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, lowerSubstr)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
 		}
+		b.WriteString(s[:i])
+		b.WriteString(hl(s[i : i+len(substr)]))
+		s, lower = s[i+len(substr):], lower[i+len(substr):]
+	}
+}
+
+// highlightTable replaces the cells in a table formatted by printTable with
+// the value returned by highlight. The table is aligned on the plain cell
+// values before highlighting, so the color codes don't affect the alignment.
+func highlightTable(table string, columns []string, rows [][]string, highlight func(row, col int) string) string {
+	lines := strings.Split(table, "\n")
+	if len(lines) < len(rows)+1 {
+		return table
+	}
+
+	starts := make([]int, len(columns))
+	offset := 0
+	for i, c := range columns {
+		idx := strings.Index(lines[0][offset:], c)
+		if idx < 0 {
+			return table
+		}
+		starts[i] = offset + idx
+		offset = starts[i] + len(c)
+	}
 
-		var rows [][]string
-		cols := []string{"NAME", "DESCRIPTION", "INSTALLED"}
-		for _, name := range matchNames {
-			plugin := pluginMap[name]
-			var status string
-			if _, ok := installed[name]; ok {
-				status = "yes"
-			} else if _, ok, err := installation.GetMatchingPlatform(plugin.Spec.Platforms); err != nil {
-				return errors.Wrapf(err, "failed to get the matching platform for plugin %s", name)
-			} else if ok {
-				status = "no"
-			} else {
-				status = "unavailable on " + runtime.GOOS
-			}
-			rows = append(rows, []string{name, limitString(plugin.Spec.ShortDescription, 50), status})
+	for i, row := range rows {
+		line := lines[i+1]
+		// replace right to left so that the column offsets stay valid
+		for c := len(columns) - 1; c >= 0; c-- {
+			start, end := starts[c], starts[c]+len(row[c])
+			if end > len(line) || line[start:end] != row[c] {
+				continue
+			}
+			line = line[:start] + highlight(i, c) + line[end:]
 		}
-		rows = sortByFirstColumn(rows)
-		return printTable(os.Stdout, cols, rows)
-	},
-	PreRunE: checkIndex,
+		lines[i+1] = line
+	}
+	return strings.Join(lines, "\n")
 }
 
 func limitString(s string, length int) string {
@@ -101,7 +453,3 @@ func limitString(s string, length int) string {
 	}
 	return s
 }
-
-func init() {
-	rootCmd.AddCommand(searchCmd)
-}
@@ -0,0 +1,238 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func Test_filterByTags(t *testing.T) {
+	plugins := []index.Plugin{
+		testutil.NewPlugin().WithName("a").WithTags("security", "networking").V(),
+		testutil.NewPlugin().WithName("b").WithTags("Security").V(),
+		testutil.NewPlugin().WithName("c").V(),
+	}
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"no tags", nil, []string{"a", "b", "c"}},
+		{"single tag is case-insensitive", []string{"security"}, []string{"a", "b"}},
+		{"all tags required", []string{"security", "networking"}, []string{"a"}},
+		{"unknown tag", []string{"debugging"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterByTags(plugins, tt.tags)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("filterByTags() mismatch:\n%s", diff)
+			}
+		})
+	}
+}
+
+func testHighlight(a ...interface{}) string { return "[" + fmt.Sprint(a...) + "]" }
+
+func Test_searchPlugins(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"ctx":   testutil.NewPlugin().WithName("ctx").WithShortDescription("Switch between contexts").V(),
+		"ns":    testutil.NewPlugin().WithName("ns").WithShortDescription("Switch between namespaces").V(),
+		"trace": testutil.NewPlugin().WithName("trace").WithShortDescription("Trace programs on nodes").V(),
+	}
+	names := []string{"ctx", "ns", "trace"}
+
+	rank := func(i int) *int { return &i }
+	both := searchFields{name: true, description: true}
+	tests := []struct {
+		name    string
+		keyword string
+		fields  searchFields
+		want    map[string]searchMatch
+	}{
+		{"no keyword", "", both, map[string]searchMatch{"ctx": {}, "ns": {}, "trace": {}}},
+		{"name match", "ctx", both, map[string]searchMatch{"ctx": {indexes: []int{0, 1, 2}, rank: rank(1)}}},
+		{"description-only match", "namespace", both, map[string]searchMatch{"ns": {}}},
+		{"description match is case-insensitive", "SWITCH", both, map[string]searchMatch{"ctx": {}, "ns": {}}},
+		{"no match", "foobar", both, map[string]searchMatch{}},
+		{"names only", "namespace", searchFields{name: true}, map[string]searchMatch{}},
+		{"names only match", "trace", searchFields{name: true}, map[string]searchMatch{"trace": {indexes: []int{0, 1, 2, 3, 4}, rank: rank(1)}}},
+		{"descriptions only", "ctx", searchFields{description: true}, map[string]searchMatch{}},
+		{"descriptions only match", "nodes", searchFields{description: true}, map[string]searchMatch{"trace": {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchPlugins(tt.keyword, names, plugins, tt.fields)
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(searchMatch{})); diff != "" {
+				t.Fatalf("searchPlugins() mismatch:\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_highlightSubstring(t *testing.T) {
+	tests := []struct {
+		in, substr, want string
+	}{
+		{"Switch between contexts", "", "Switch between contexts"},
+		{"Switch between contexts", "switch", "[Switch] between contexts"},
+		{"abcabc", "bc", "a[bc]a[bc]"},
+		{"no match here", "xyz", "no match here"},
+	}
+	for _, tt := range tests {
+		if got := highlightSubstring(tt.in, tt.substr, testHighlight); got != tt.want {
+			t.Errorf("highlightSubstring(%q, %q) = %q, want %q", tt.in, tt.substr, got, tt.want)
+		}
+	}
+}
+
+func Test_highlightTable(t *testing.T) {
+	cols := []string{"NAME", "DESCRIPTION", "INSTALLED"}
+	rows := [][]string{
+		{"ctx", "Switch between contexts", "no"},
+		{"ns", "Switch between namespaces", "yes"},
+	}
+	var b bytes.Buffer
+	if err := printTable(&b, cols, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	got := highlightTable(b.String(), cols, rows, func(row, col int) string {
+		if col == 1 {
+			return highlightSubstring(rows[row][col], "between", testHighlight)
+		}
+		return rows[row][col]
+	})
+	want := "NAME  DESCRIPTION                INSTALLED\n" +
+		"ctx   Switch [between] contexts    no\n" +
+		"ns    Switch [between] namespaces  yes\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("highlightTable() mismatch:\n%s", diff)
+	}
+}
+
+func Test_printSearchResultsJSON(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"ctx": testutil.NewPlugin().WithName("ctx").WithVersion("v1.0.0").WithShortDescription("Switch contexts").V(),
+		"ns":  testutil.NewPlugin().WithName("ns").WithVersion("v2.0.0").WithShortDescription("Switch namespaces").V(),
+	}
+	rank := 1
+
+	var b bytes.Buffer
+	matches := map[string]searchMatch{"ns": {}, "ctx": {rank: &rank}}
+	if err := printSearchResultsJSON(&b, sortedMatchNames(matches), matches, plugins); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "name": "ctx",
+    "description": "Switch contexts",
+    "homepage": "",
+    "version": "v1.0.0",
+    "index": "default",
+    "rank": 1
+  },
+  {
+    "name": "ns",
+    "description": "Switch namespaces",
+    "homepage": "",
+    "version": "v2.0.0",
+    "index": "default"
+  }
+]
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Fatalf("printSearchResultsJSON() mismatch:\n%s", diff)
+	}
+
+	b.Reset()
+	if err := printSearchResultsJSON(&b, nil, map[string]searchMatch{}, plugins); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "[]\n" {
+		t.Fatalf("expected empty JSON array for no matches, got %q", got)
+	}
+}
+
+func Test_limitMatches(t *testing.T) {
+	rank1, rank2 := 1, 2
+	matches := map[string]searchMatch{
+		"a-description": {},
+		"b-second":      {rank: &rank2},
+		"c-first":       {rank: &rank1},
+		"d-description": {},
+	}
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: 1, want: []string{"c-first"}},
+		{n: 3, want: []string{"c-first", "b-second", "a-description"}},
+		{n: 10, want: []string{"c-first", "b-second", "a-description", "d-description"}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, limitMatches(matches, tt.n)); diff != "" {
+			t.Errorf("limitMatches(%d) mismatch (-want +got):\n%s", tt.n, diff)
+		}
+	}
+}
+
+func Test_printSearchResultsPageJSON(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"ctx": testutil.NewPlugin().WithName("ctx").WithVersion("v1.0.0").WithShortDescription("Switch contexts").V(),
+	}
+	var b bytes.Buffer
+	if err := printSearchResultsPageJSON(&b, []string{"ctx"}, map[string]searchMatch{"ctx": {}}, 5, plugins); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "total": 5,
+  "results": [
+    {
+      "name": "ctx",
+      "description": "Switch contexts",
+      "homepage": "",
+      "version": "v1.0.0",
+      "index": "default"
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Fatalf("printSearchResultsPageJSON() mismatch:\n%s", diff)
+	}
+}
+
+func Test_parseSearchFields(t *testing.T) {
+	got, err := parseSearchFields([]string{"description", " name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (searchFields{name: true, description: true}); got != want {
+		t.Errorf("parseSearchFields() = %+v, want %+v", got, want)
+	}
+	for _, invalid := range [][]string{nil, {"tags"}} {
+		if _, err := parseSearchFields(invalid); err == nil {
+			t.Errorf("parseSearchFields(%q) expected error", invalid)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func init() {
+	var noUpdateIndex *bool
+
+	// selfUpgradeCmd represents the self-upgrade command
+	var selfUpgradeCmd = &cobra.Command{
+		Use:   "self-upgrade",
+		Short: "Upgrade krew itself to the newest version",
+		Long: `Upgrade krew itself to the newest version in the index.
+
+The new version is downloaded next to the current one and has to run
+successfully before it replaces the current version. If anything fails, the
+current version is kept. "kubectl krew upgrade krew" does the same.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := globalInstallOpts()
+			if err != nil {
+				return err
+			}
+			name := constants.KrewPluginName
+			plugin, err := loadChannelPlugin(paths, name, installedChannel(paths, name))
+			if os.IsNotExist(err) {
+				return withExitCode(exitNotFound, errors.New("krew does not exist in the plugin index"))
+			} else if err != nil {
+				return errors.Wrap(err, "failed to load the plugin manifest of krew")
+			}
+
+			fmt.Fprintf(os.Stderr, "Upgrading krew to %s\n", plugin.Spec.Version)
+			switch err := installation.UpgradeKrew(paths, plugin, opts); err {
+			case nil:
+			case installation.ErrIsAlreadyUpgraded:
+				fmt.Fprintln(os.Stderr, "krew is already on the newest version")
+				return nil
+			case installation.ErrIsNotInstalled:
+				return errors.New("krew is not installed as a krew plugin, upgrade it the way it was installed")
+			case installation.ErrIsDevLinked:
+				return errors.New("krew is linked to a development build, uninstall it first")
+			default:
+				return errors.Wrap(err, "failed to upgrade krew")
+			}
+			fmt.Fprintf(os.Stderr, "Upgraded krew to %s\n", plugin.Spec.Version)
+			return runHook(hookUpgrade, plugin.Name, plugin.Spec.Version)
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if *noUpdateIndex {
+				klog.V(4).Infof("--no-update-index specified, skipping updating local copy of plugin index")
+				return ensureIndex(cmd, args)
+			}
+			if err := ensureIndexUpdated(cmd, args); err != nil {
+				return err
+			}
+			return ensureIndex(cmd, args)
+		},
+	}
+
+	noUpdateIndex = selfUpgradeCmd.Flags().Bool("no-update-index", false, "(Experimental) do not update local copy of plugin index before upgrading")
+	rootCmd.AddCommand(selfUpgradeCmd)
+}
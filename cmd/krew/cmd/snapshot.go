@@ -0,0 +1,207 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/gitutil"
+	"sigs.k8s.io/krew/internal/pathutil"
+)
+
+// indexSnapshotEnv is the URL of a .tar.gz or .zip archive of a clone of the
+// plugin index. If set, the index is updated by downloading the archive instead
+// of fetching with git. Its sha256 checksum is read from the same URL with a
+// ".sha256" suffix.
+const indexSnapshotEnv = "KREW_INDEX_SNAPSHOT_URI"
+
+// indexSnapshotShallowEnv enables (or, if false, disables) the shallow mode of
+// indexSnapshotEnv, like the --shallow-snapshot flag of "krew update". The
+// archive then only has to contain the plugin manifests of the index, which is
+// stored without git to save disk space, and git is never used to update it.
+const indexSnapshotShallowEnv = "KREW_INDEX_SNAPSHOT_SHALLOW"
+
+// snapshotChecksumSuffix is appended to the snapshot URL to find its checksum.
+const snapshotChecksumSuffix = ".sha256"
+
+// shallowSnapshotMarker is the file that marks the local index as a shallow
+// snapshot. It records the URL the snapshot was downloaded from.
+const shallowSnapshotMarker = ".krew-snapshot"
+
+// shallowSnapshotDirs are the directories of the index that a shallow
+// snapshot keeps, everything else in the archive is dropped. Only the first
+// one, with the plugin manifests, is required.
+var shallowSnapshotDirs = []string{"plugins", "channels"}
+
+// isShallowSnapshotMode reports whether the index is to be updated in shallow
+// snapshot mode. The --shallow-snapshot flag of "krew update" takes precedence
+// over indexSnapshotShallowEnv. If neither is set, an index that is a shallow
+// snapshot stays one, so that updates run by other commands keep the mode.
+func isShallowSnapshotMode() bool {
+	if shallowSnapshot != nil && shallowSnapshot.Changed {
+		v, _ := strconv.ParseBool(shallowSnapshot.Value.String())
+		return v
+	}
+	if v, err := strconv.ParseBool(os.Getenv(indexSnapshotShallowEnv)); err == nil {
+		return v
+	}
+	return isShallowIndex(paths)
+}
+
+// isShallowIndex reports whether the local index is a shallow snapshot.
+func isShallowIndex(p environment.Paths) bool {
+	_, err := os.Stat(filepath.Join(p.IndexPath(), shallowSnapshotMarker))
+	return err == nil
+}
+
+// shallowSnapshotURI returns the URL the shallow snapshot of the local index
+// was downloaded from, or an empty string if it is not recorded.
+func shallowSnapshotURI(p environment.Paths) string {
+	b, err := ioutil.ReadFile(filepath.Join(p.IndexPath(), shallowSnapshotMarker))
+	if err != nil {
+		klog.V(2).Infof("Cannot read the URL of the index snapshot: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// checkIndexHistory fails if the local index is a shallow snapshot, which has
+// no git history for the feature.
+func checkIndexHistory(feature string) error {
+	if isShallowIndex(paths) {
+		return errors.Errorf("%s needs the git history of the plugin index, which is not stored in shallow snapshot mode", feature)
+	}
+	return nil
+}
+
+// updateIndexFromSnapshot downloads the index snapshot at uri, verifies it
+// against its published checksum and replaces the local index with it. A
+// shallow snapshot does not have to be a git clone, and only its plugin
+// manifests are kept.
+func updateIndexFromSnapshot(p environment.Paths, uri string, fetcher download.Fetcher, shallow bool) error {
+	sum, err := fetchSnapshotChecksum(uri+snapshotChecksumSuffix, fetcher)
+	if err != nil {
+		return err
+	}
+
+	// extract next to the index so that swapping it in is a rename
+	tmpDir, err := ioutil.TempDir(p.CachePath(), "index-snapshot-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create a directory for the index snapshot")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	klog.V(1).Infof("Downloading the plugin index snapshot from %s", uri)
+	extracted := filepath.Join(tmpDir, "index")
+	if err := download.NewDownloader(download.NewSha256Verifier(sum), fetcher).Get(uri, extracted); err != nil {
+		return errors.Wrap(err, "failed to download the index snapshot")
+	}
+	if shallow {
+		root, err := shallowSnapshotRoot(extracted)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, shallowSnapshotMarker), []byte(uri+"\n"), 0644); err != nil {
+			return errors.Wrap(err, "failed to mark the index as a snapshot")
+		}
+		return pathutil.ReplaceDir(p.IndexPath(), root, filepath.Join(tmpDir, "old"))
+	}
+	if ok, err := gitutil.IsGitCloned(extracted); err != nil {
+		return errors.Wrap(err, "failed to check the index snapshot")
+	} else if !ok {
+		return errors.New("the index snapshot does not contain a git clone of the plugin index")
+	}
+	return pathutil.ReplaceDir(p.IndexPath(), extracted, filepath.Join(tmpDir, "old"))
+}
+
+// shallowSnapshotRoot returns the directory of the extracted shallow snapshot
+// with the plugins directory, after removing everything but the
+// shallowSnapshotDirs from it. Archives of a repository often have all files
+// in one top-level directory, which is looked into as well.
+func shallowSnapshotRoot(extracted string) (string, error) {
+	root := extracted
+	if !isDir(filepath.Join(root, shallowSnapshotDirs[0])) {
+		entries, err := ioutil.ReadDir(extracted)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read the index snapshot")
+		}
+		if len(entries) != 1 || !entries[0].IsDir() || !isDir(filepath.Join(extracted, entries[0].Name(), shallowSnapshotDirs[0])) {
+			return "", errors.New("the index snapshot does not contain the plugins directory of the plugin index")
+		}
+		root = filepath.Join(extracted, entries[0].Name())
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the index snapshot")
+	}
+	for _, e := range entries {
+		if e.IsDir() && isShallowSnapshotDir(e.Name()) {
+			continue
+		}
+		klog.V(3).Infof("Dropping %q from the shallow index snapshot", e.Name())
+		if err := os.RemoveAll(filepath.Join(root, e.Name())); err != nil {
+			return "", errors.Wrapf(err, "failed to remove %q from the index snapshot", e.Name())
+		}
+	}
+	return root, nil
+}
+
+func isShallowSnapshotDir(name string) bool {
+	for _, d := range shallowSnapshotDirs {
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// fetchSnapshotChecksum returns the hex sha256 checksum published at uri, in
+// the format written by sha256sum.
+func fetchSnapshotChecksum(uri string, fetcher download.Fetcher) (string, error) {
+	body, err := fetcher.Get(uri)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to download the checksum of the index snapshot")
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(body, 1024))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the checksum of the index snapshot")
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", errors.Errorf("checksum of the index snapshot at %q is empty", uri)
+	}
+	if raw, err := hex.DecodeString(fields[0]); err != nil || len(raw) != 32 {
+		return "", errors.Errorf("checksum of the index snapshot at %q is not a sha256 checksum", uri)
+	}
+	return strings.ToLower(fields[0]), nil
+}
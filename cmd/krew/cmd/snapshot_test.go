@@ -0,0 +1,301 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/download"
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func serveSnapshot(archive []byte, checksum string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.tar.gz", func(w http.ResponseWriter, _ *http.Request) { w.Write(archive) })
+	mux.HandleFunc("/index.tar.gz.sha256", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, "%s  index.tar.gz\n", checksum)
+	})
+	return httptest.NewServer(mux)
+}
+
+func Test_updateIndexFromSnapshot(t *testing.T) {
+	validSnapshot := tarGz(t, map[string]string{
+		".git/HEAD":       "ref: refs/heads/master\n",
+		"plugins/foo.txt": "new",
+	})
+	validSum := fmt.Sprintf("%x", sha256.Sum256(validSnapshot))
+	noGitSnapshot := tarGz(t, map[string]string{"plugins/foo.txt": "new"})
+
+	tests := []struct {
+		name     string
+		archive  []byte
+		checksum string
+		wantErr  bool
+	}{
+		{name: "valid snapshot", archive: validSnapshot, checksum: validSum},
+		{name: "checksum mismatch", archive: validSnapshot, checksum: fmt.Sprintf("%x", sha256.Sum256(nil)), wantErr: true},
+		{name: "malformed checksum", archive: validSnapshot, checksum: "not-a-checksum", wantErr: true},
+		{name: "not a git clone", archive: noGitSnapshot, checksum: fmt.Sprintf("%x", sha256.Sum256(noGitSnapshot)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			p := environment.NewPaths(tmpDir.Root())
+			tmpDir.Write("index/plugins/foo.txt", []byte("old"))
+
+			server := serveSnapshot(tt.archive, tt.checksum)
+			defer server.Close()
+
+			err := updateIndexFromSnapshot(p, server.URL+"/index.tar.gz", download.HTTPFetcher{}, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("updateIndexFromSnapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := "new"
+			if tt.wantErr {
+				want = "old"
+			}
+			b, err := ioutil.ReadFile(tmpDir.Path("index/plugins/foo.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != want {
+				t.Errorf("index file content = %q, want %q", b, want)
+			}
+
+			// nothing but the index should be left behind
+			entries, err := ioutil.ReadDir(tmpDir.Root())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the index directory in %s, got %d entries", tmpDir.Root(), len(entries))
+			}
+		})
+	}
+
+	// the index does not have to exist yet
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	server := serveSnapshot(validSnapshot, validSum)
+	defer server.Close()
+	if err := updateIndexFromSnapshot(environment.NewPaths(tmpDir.Root()), server.URL+"/index.tar.gz", download.HTTPFetcher{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("index/.git/HEAD")); err != nil {
+		t.Errorf("expected the snapshot to be extracted to the index: %v", err)
+	}
+}
+
+func Test_updateIndexFromSnapshot_shallow(t *testing.T) {
+	b, err := yaml.Marshal(testutil.NewPlugin().WithName("foo").V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := "plugins/foo" + constants.ManifestExtension
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{name: "manifests at the top", files: map[string]string{manifest: string(b), "README.md": "dropped"}},
+		{name: "manifests in a top-level directory", files: map[string]string{"krew-index-master/" + manifest: string(b), "krew-index-master/README.md": "dropped"}},
+		{name: "no manifests", files: map[string]string{"README.md": "dropped"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			p := environment.NewPaths(tmpDir.Root())
+
+			archive := tarGz(t, tt.files)
+			server := serveSnapshot(archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+			defer server.Close()
+
+			err := updateIndexFromSnapshot(p, server.URL+"/index.tar.gz", download.HTTPFetcher{}, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("updateIndexFromSnapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !isShallowIndex(p) {
+				t.Error("expected the index to be marked as a shallow snapshot")
+			}
+			plugins, err := indexscanner.LoadPluginListFromFS(p.IndexPluginsPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plugins) != 1 || plugins[0].Name != "foo" {
+				t.Errorf("expected plugin foo in the index, got %v", plugins)
+			}
+			if _, err := os.Stat(tmpDir.Path("index/README.md")); !os.IsNotExist(err) {
+				t.Errorf("expected files other than the manifests to be dropped, got err=%v", err)
+			}
+		})
+	}
+}
+
+func Test_isShallowSnapshotMode(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Root())
+	defer os.Unsetenv(indexSnapshotShallowEnv)
+	defer func() { shallowSnapshot.Changed = false; shallowSnapshot.Value.Set("false") }()
+
+	os.Unsetenv(indexSnapshotShallowEnv)
+	if isShallowSnapshotMode() {
+		t.Error("expected a git index not to be in shallow snapshot mode")
+	}
+	tmpDir.Write("index/"+shallowSnapshotMarker, nil)
+	if !isShallowSnapshotMode() {
+		t.Error("expected a shallow snapshot index to stay in shallow snapshot mode")
+	}
+	os.Setenv(indexSnapshotShallowEnv, "false")
+	if isShallowSnapshotMode() {
+		t.Errorf("expected %s=false to switch back to git", indexSnapshotShallowEnv)
+	}
+	if err := updateCmd.Flags().Set("shallow-snapshot", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if !isShallowSnapshotMode() {
+		t.Error("expected --shallow-snapshot to take precedence over the environment")
+	}
+}
+
+func Test_ensureIndexUpdated_shallowInGitRepo(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	// a repository above the krew root, like a dotfiles repository in $HOME
+	git := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"-C", tmpDir.Root(), "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Errorf("git %v: %v: %s", args, err, out)
+		}
+		return nil
+	}
+	if err := git("init", "-q"); err != nil {
+		t.Skip(err)
+	}
+	if err := git("commit", "-q", "--allow-empty", "-m", "init"); err != nil {
+		t.Fatal(err)
+	}
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Path("krew"))
+
+	b, err := yaml.Marshal(testutil.NewPlugin().WithName("foo").V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tarGz(t, map[string]string{"plugins/foo" + constants.ManifestExtension: string(b)})
+	server := serveSnapshot(archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+	defer server.Close()
+	os.Setenv(indexSnapshotEnv, server.URL+"/index.tar.gz")
+	defer os.Unsetenv(indexSnapshotEnv)
+	os.Setenv(indexSnapshotShallowEnv, "true")
+	defer os.Unsetenv(indexSnapshotShallowEnv)
+
+	for i := 0; i < 2; i++ {
+		if err := ensureIndexUpdated(nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := git("rev-parse", "--verify", "--quiet", previousIndexRef); err == nil {
+		t.Errorf("expected %s not to be written to the repository above the shallow index", previousIndexRef)
+	}
+}
+
+func Test_ensureIndexUpdated_shallowWithoutEnv(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Root())
+
+	manifest := func(version string) string {
+		b, err := yaml.Marshal(testutil.NewPlugin().WithName("foo").WithVersion(version).V())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	archive := tarGz(t, map[string]string{"plugins/foo" + constants.ManifestExtension: manifest("v1.0.0")})
+	server := serveSnapshot(archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+	defer server.Close()
+	if err := updateIndexFromSnapshot(paths, server.URL+"/index.tar.gz", download.HTTPFetcher{}, true); err != nil {
+		t.Fatal(err)
+	}
+	// the snapshot is updated upstream, the marker records the URL to use
+	archive = tarGz(t, map[string]string{"plugins/foo" + constants.ManifestExtension: manifest("v2.0.0")})
+	updated := serveSnapshot(archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+	defer updated.Close()
+	tmpDir.Write("index/"+shallowSnapshotMarker, []byte(updated.URL+"/index.tar.gz\n"))
+
+	os.Unsetenv(indexSnapshotEnv)
+	os.Unsetenv(indexSnapshotShallowEnv)
+	if err := ensureIndexUpdated(nil, nil); err != nil {
+		t.Fatalf("implicit update of a shallow index without %s: %v", indexSnapshotEnv, err)
+	}
+	plugin, err := indexscanner.LoadPluginByName(paths.IndexPluginsPath(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plugin.Spec.Version != "v2.0.0" {
+		t.Errorf("expected the snapshot to be downloaded again, got version %s", plugin.Spec.Version)
+	}
+	if !isShallowIndex(paths) {
+		t.Error("expected the index to stay a shallow snapshot")
+	}
+}
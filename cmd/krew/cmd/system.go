@@ -15,9 +15,16 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/installation"
 	"sigs.k8s.io/krew/internal/receiptsmigration"
+	"sigs.k8s.io/krew/pkg/index"
 )
 
 // todo(corneliusweig) remove migration code with v0.4
@@ -50,7 +57,111 @@ This command will be removed without further notice from future versions of krew
 	PreRunE: ensureIndexUpdated,
 }
 
+// relinkCmd represents the system relink command
+var relinkCmd = &cobra.Command{
+	Use:   "relink",
+	Short: "Recreate the symlinks of installed plugins",
+	Long: `Recreate the symlinks of all installed plugins so that they point to the
+plugin binaries under the current krew root.
+
+Use this after copying or moving the krew root (e.g. to a new machine) where
+the plugin symlinks still point to the old location. Plugins whose binaries
+cannot be found are reported and the command fails after relinking the rest.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := installation.Relink(paths)
+		if err != nil {
+			return err
+		}
+		var failed []string
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to relink plugin %s: %v\n", r.Plugin, r.Err)
+				failed = append(failed, r.Plugin)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Relinked plugin %s\n", r.Plugin)
+		}
+		if len(failed) > 0 {
+			return errors.Errorf("failed to relink plugins: %v", failed)
+		}
+		return nil
+	},
+}
+
+// refreshReceiptsCmd represents the system refresh-receipts command
+var refreshReceiptsCmd = &cobra.Command{
+	Use:   "refresh-receipts",
+	Short: "Recreate missing or invalid receipts of installed plugins",
+	Long: `Recreate the receipts of installed plugins whose receipt is missing or
+invalid, for example after copying plugins into the krew root or recovering
+from a corrupted receipt.
+
+Each plugin installation directory without a valid receipt is matched to the
+manifest of the plugin at the installed version in the index, including its
+beta channel and, for indexes with git history, previous versions. Directories
+that can't be matched are reported and the command fails after refreshing the
+rest. Directories left behind by an uninstall, which only hold files the plugin
+created, are skipped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := installation.RefreshReceipts(paths, findIndexManifest)
+		if err != nil {
+			return err
+		}
+		var failed []string
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to refresh the receipt of %s: %v\n", r.Plugin, r.Err)
+				failed = append(failed, r.Plugin)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Refreshed the receipt of plugin %s (%s)\n", r.Plugin, r.Version)
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(os.Stderr, "All installed plugins have a valid receipt.")
+		}
+		if len(failed) > 0 {
+			return errors.Errorf("failed to refresh receipts: %v", failed)
+		}
+		return nil
+	},
+	PreRunE: checkIndex,
+}
+
+// findIndexManifest finds the manifest of the plugin at the version in the
+// channels of the index, then in the git history of the index.
+func findIndexManifest(name, version string) (index.Plugin, string, error) {
+	for _, channel := range indexChannels {
+		dir := paths.IndexPluginsPath()
+		if channel != stableChannel {
+			dir = paths.IndexChannelPath(channel)
+		}
+		plugin, err := indexscanner.LoadPluginByName(dir, name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return index.Plugin{}, "", err
+		}
+		if plugin.Spec.Version == version {
+			c, _ := parseChannel(channel)
+			return plugin, c, nil
+		}
+	}
+	notFound := &os.PathError{Op: "find", Path: name + "@" + version, Err: os.ErrNotExist}
+	if isShallowIndex(paths) {
+		return index.Plugin{}, "", notFound
+	}
+	plugin, err := indexscanner.LoadPluginAtVersion(paths.IndexPath(), name, version)
+	if err == indexscanner.ErrVersionNotFound {
+		return index.Plugin{}, "", notFound
+	}
+	return plugin, "", err
+}
+
 func init() {
 	systemCmd.AddCommand(receiptsUpgradeCmd)
+	systemCmd.AddCommand(relinkCmd)
+	systemCmd.AddCommand(refreshReceiptsCmd)
 	rootCmd.AddCommand(systemCmd)
 }
@@ -22,9 +22,20 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/krew/internal/environment"
 	"sigs.k8s.io/krew/internal/installation"
 )
 
+var (
+	// ignoreNotFound is set to not fail for plugins that are not installed.
+	ignoreNotFound *bool
+	// keepReceipt is set to keep the receipts of the uninstalled plugins.
+	keepReceipt *bool
+	// purge is set to also remove the cached downloads and all files of the
+	// uninstalled plugins.
+	purge *bool
+)
+
 // uninstallCmd represents the uninstall command
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall",
@@ -35,14 +46,67 @@ Example:
   kubectl krew uninstall NAME [NAME...]
 
 Remarks:
-  Failure to uninstall a plugin will result in an error and exit immediately.`,
+  Failure to uninstall a plugin will not stop the uninstallation of other
+  plugins. Use --ignore-not-found to not fail for plugins that are not
+  installed.
+
+  Use --keep-receipt to only remove the files of the plugins. Their receipts
+  are kept, so "kubectl krew reinstall" can install the same versions from
+  the same sources again later.
+
+  Use --purge to remove the plugins as if they were never installed: their
+  receipts, their installation directories including files the plugins
+  created there, and their downloads in the download cache. Cached downloads
+  that other installed plugins use are kept.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if *purge && *keepReceipt {
+			return errors.New("--purge cannot be used with --keep-receipt")
+		}
+		var failed []string
+		var returnErr error
 		for _, name := range args {
 			klog.V(4).Infof("Going to uninstall plugin %s\n", name)
-			if err := installation.Uninstall(paths, name); err != nil {
-				return errors.Wrapf(err, "failed to uninstall plugin %s", name)
+			version := installedVersion(name)
+			uninstall := installation.Uninstall
+			if *keepReceipt {
+				uninstall = installation.UninstallKeepReceipt
+			}
+			var purged int
+			if *purge {
+				uninstall = func(p environment.Paths, name string) (err error) {
+					purged, err = installation.UninstallPurge(p, name, downloadCache())
+					return err
+				}
+			}
+			err := uninstall(paths, name)
+			if err == installation.ErrIsNotInstalled && *ignoreNotFound {
+				fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is not installed\n", name)
+				continue
 			}
-			fmt.Fprintf(os.Stderr, "Uninstalled plugin %s\n", name)
+			if err != nil {
+				klog.Warningf("failed to uninstall plugin %q: %v", name, err)
+				if returnErr == nil {
+					returnErr = withPlugin(name, errors.Wrapf(err, "failed to uninstall plugin %s", name))
+				}
+				failed = append(failed, name)
+				continue
+			}
+			if *keepReceipt {
+				fmt.Fprintf(os.Stderr, "Uninstalled plugin %s, kept its receipt to reinstall it later\n", name)
+			} else if *purge {
+				fmt.Fprintf(os.Stderr, "Uninstalled plugin %s, removed %d cached download(s)\n", name, purged)
+			} else {
+				fmt.Fprintf(os.Stderr, "Uninstalled plugin %s\n", name)
+			}
+			if err := runHook(hookUninstall, name, version); err != nil {
+				if returnErr == nil {
+					returnErr = withPlugin(name, err)
+				}
+				failed = append(failed, name)
+			}
+		}
+		if len(failed) > 0 {
+			return errors.Wrapf(returnErr, "failed to uninstall some plugins: %+v", failed)
 		}
 		return nil
 	},
@@ -52,5 +116,24 @@ Remarks:
 }
 
 func init() {
+	ignoreNotFound = uninstallCmd.Flags().Bool("ignore-not-found", false, "do not fail for plugins that are not installed")
+	keepReceipt = uninstallCmd.Flags().Bool("keep-receipt", false, "keep the receipts of the plugins to reinstall them later")
+	purge = uninstallCmd.Flags().Bool("purge", false, "also remove all files and the cached downloads of the plugins")
 	rootCmd.AddCommand(uninstallCmd)
 }
+
+// installedVersion returns the version of the plugin installed as name (its
+// plugin name or the name it is invoked with), or "" if it is not installed.
+func installedVersion(name string) string {
+	receipts, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
+	if err != nil {
+		klog.V(2).Infof("Failed to list installed plugins: %v", err)
+		return ""
+	}
+	for _, r := range receipts {
+		if r.Name == name || r.Status.BinName == name {
+			return r.Spec.Version
+		}
+	}
+	return ""
+}
@@ -19,14 +19,19 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/filelock"
 	"sigs.k8s.io/krew/internal/gitutil"
 	"sigs.k8s.io/krew/internal/index/indexscanner"
 	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
 	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
@@ -42,10 +47,81 @@ plugin index from the internet.
 
 Remarks:
   You don't need to run this command: Running "krew update" or "krew upgrade"
-  will silently run this command.`,
-	RunE: ensureIndexUpdated,
+  will silently run this command.
+
+  If the updated index has a newer version of krew than the installed one, a
+  notice is printed. Set ` + noUpdateNoticeEnv + `=1 or noUpdateNotice: true in
+  the config file to disable it.
+
+  With ` + indexSnapshotEnv + ` set to the URL of an archive of the index,
+  --shallow-snapshot stores only the plugin manifests of the index, without
+  git. Later updates keep this mode until --shallow-snapshot=false is used.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if isOffline() {
+			return errors.New("updating the local copy of plugin index needs network access, which is blocked by offline mode")
+		}
+		if err := ensureIndexUpdated(cmd, args); err != nil {
+			return err
+		}
+		if !updateNoticeDisabled() {
+			if notice := krewUpgradeNotice(paths); notice != "" {
+				fmt.Fprintln(os.Stderr, notice)
+			}
+		}
+		return nil
+	},
 }
 
+// previousIndexRef is the git ref in the index that points to the commit
+// checked out before the last update.
+const previousIndexRef = "refs/krew/previous-update"
+
+// noUpdateNoticeEnv disables the notice about a newer version of krew in the
+// index if set to a true value. It overrides the noUpdateNotice config
+// setting.
+const noUpdateNoticeEnv = "KREW_NO_UPDATE_NOTICE"
+
+// krewUpgradeNotice returns a notice if the local copy of the index has a
+// newer version of krew than the installed one, or "" otherwise. Failures to
+// find out are only logged.
+func krewUpgradeNotice(p environment.Paths) string {
+	r, err := receipt.Load(p.PluginInstallReceiptPath(constants.KrewPluginName))
+	if err != nil {
+		klog.V(2).Infof("Not checking for a newer version of krew, its receipt cannot be loaded: %v", err)
+		return ""
+	}
+	if r.Status.DevLink != "" {
+		return ""
+	}
+	plugin, err := indexscanner.LoadPluginByName(p.IndexPluginsPath(), constants.KrewPluginName)
+	if err != nil {
+		klog.V(2).Infof("Not checking for a newer version of krew, it cannot be loaded from the index: %v", err)
+		return ""
+	}
+	newer, err := installation.NeedsUpgrade(r, plugin)
+	if err != nil {
+		klog.V(2).Infof("Not checking for a newer version of krew: %v", err)
+		return ""
+	}
+	if !newer {
+		return ""
+	}
+	return fmt.Sprintf("A new version of krew is available: %s -> %s. Run \"kubectl krew upgrade krew\".", r.Spec.Version, plugin.Spec.Version)
+}
+
+var (
+	// updateRetries is the number of times a failed index update is retried.
+	updateRetries *int
+
+	// shallowSnapshot is the flag that selects the shallow snapshot mode of
+	// the index.
+	shallowSnapshot *pflag.Flag
+
+	// updateRetryBackoff is the delay before the first retry, it doubles
+	// after each failed attempt.
+	updateRetryBackoff = 2 * time.Second
+)
+
 func showFormattedPluginsInfo(out io.Writer, header string, plugins []string) {
 	var b bytes.Buffer
 	b.WriteString(fmt.Sprintf("  %s:\n", header))
@@ -57,7 +133,7 @@ func showFormattedPluginsInfo(out io.Writer, header string, plugins []string) {
 	fmt.Fprintf(out, "%s", b.String())
 }
 
-func showUpdatedPlugins(out io.Writer, preUpdate, posUpdate []index.Plugin, installedPlugins map[string]string) {
+func showUpdatedPlugins(out io.Writer, preUpdate, posUpdate []index.Plugin, installedPlugins []index.Receipt) {
 	var newPlugins []index.Plugin
 	var updatedPlugins []index.Plugin
 
@@ -66,6 +142,11 @@ func showUpdatedPlugins(out io.Writer, preUpdate, posUpdate []index.Plugin, inst
 		oldIndex[p.Name] = p
 	}
 
+	installed := make(map[string]bool, len(installedPlugins))
+	for _, r := range installedPlugins {
+		installed[r.Name] = true
+	}
+
 	for _, p := range posUpdate {
 		old, ok := oldIndex[p.Name]
 		if !ok {
@@ -73,7 +154,7 @@ func showUpdatedPlugins(out io.Writer, preUpdate, posUpdate []index.Plugin, inst
 			continue
 		}
 
-		if _, ok := installedPlugins[p.Name]; !ok {
+		if !installed[p.Name] {
 			continue
 		}
 
@@ -102,14 +183,97 @@ func showUpdatedPlugins(out io.Writer, preUpdate, posUpdate []index.Plugin, inst
 	}
 }
 
+// lockIndexShared acquires a shared lock on the local copy of the plugin index,
+// so that it is not updated while the lock is held. On a read-only KREW_ROOT
+// no lock is taken, as the index cannot be updated there either.
+func lockIndexShared() (*filelock.Lock, error) {
+	lock, err := filelock.Shared(paths.IndexLockPath())
+	if err != nil && isReadOnlyRootError(err, paths.BasePath()) {
+		klog.V(1).Infof("Not locking the local copy of plugin index, KREW_ROOT is read-only: %v", err)
+		return &filelock.Lock{}, nil
+	}
+	return lock, errors.Wrap(err, "failed to lock the local copy of plugin index")
+}
+
 func ensureIndexUpdated(_ *cobra.Command, _ []string) error {
+	if isOffline() {
+		klog.V(1).Infof("Offline mode, not updating the local copy of plugin index")
+		return nil
+	}
+
+	indexURI, err := defaultIndexURI()
+	if err != nil {
+		return err
+	}
+
+	// concurrent krew processes wait for the update instead of running git
+	// in the same repository, or reading a half-updated index
+	lock, err := filelock.Exclusive(paths.IndexLockPath())
+	if err != nil {
+		return errors.Wrap(err, "failed to lock the local copy of plugin index")
+	}
+	defer lock.Unlock()
+
 	preUpdateIndex, _ := indexscanner.LoadPluginListFromFS(paths.IndexPluginsPath())
+	// a shallow snapshot has no .git, git would use a repository above it
+	var preUpdateHead string
+	if ok, _ := gitutil.IsGitCloned(paths.IndexPath()); ok {
+		if preUpdateHead, err = gitutil.Head(paths.IndexPath()); err != nil {
+			klog.V(4).Infof("Cannot find the commit of the index before the update: %v", err)
+		}
+	}
+
+	var fromSnapshot bool
+	shallow := isShallowSnapshotMode()
+	snapshotURI := os.Getenv(indexSnapshotEnv)
+	if snapshotURI == "" && shallow {
+		// download the shallow snapshot again from where it came from
+		snapshotURI = shallowSnapshotURI(paths)
+	}
+	if snapshotURI != "" {
+		fetcher, err := httpFetcher()
+		if err != nil {
+			return err
+		}
+		if err := updateIndexFromSnapshot(paths, snapshotURI, fetcher, shallow); err != nil {
+			if shallow {
+				return withExitCode(exitNetwork, errors.Wrap(err, "failed to update the local copy of plugin index from the snapshot"))
+			}
+			klog.Warningf("Failed to update the local copy of plugin index from the snapshot, falling back to git: %v", err)
+		} else {
+			fromSnapshot = true
+		}
+	} else if shallow {
+		return errors.Errorf("shallow snapshot mode requires %s to be set to the URL of the index snapshot", indexSnapshotEnv)
+	}
 
-	klog.V(1).Infof("Updating the local copy of plugin index (%s)", paths.IndexPath())
-	if err := gitutil.EnsureUpdated(constants.IndexURI, paths.IndexPath()); err != nil {
-		return errors.Wrap(err, "failed to update the local index")
+	attempts := 1
+	if !fromSnapshot && isShallowIndex(paths) {
+		klog.V(1).Infof("Replacing the shallow snapshot of plugin index with a git clone")
+		if err := os.RemoveAll(paths.IndexPath()); err != nil {
+			return errors.Wrap(err, "failed to remove the shallow snapshot of plugin index")
+		}
+	}
+	if !fromSnapshot {
+		klog.V(1).Infof("Updating the local copy of plugin index (%s) from %s", paths.IndexPath(), indexURI)
+		attempts, err = withRetries(*updateRetries, updateRetryBackoff, func() error {
+			return gitutil.EnsureUpdated(indexURI, paths.IndexPath())
+		})
+		if err != nil {
+			return withExitCode(exitNetwork, errors.Wrapf(err, "failed to update the local index %q after %d attempt(s)", constants.DefaultIndexName, attempts))
+		}
+	}
+	if ok, _ := gitutil.IsGitCloned(paths.IndexPath()); ok && preUpdateHead != "" {
+		// recorded for "krew index diff"
+		if err := gitutil.UpdateRef(paths.IndexPath(), previousIndexRef, preUpdateHead); err != nil {
+			klog.V(1).Infof("Failed to record the commit of the index before the update: %v", err)
+		}
+	}
+	if attempts > 1 {
+		fmt.Fprintf(os.Stderr, "Updated the local copy of plugin index after %d attempts.\n", attempts)
+	} else {
+		fmt.Fprintln(os.Stderr, "Updated the local copy of plugin index.")
 	}
-	fmt.Fprintln(os.Stderr, "Updated the local copy of plugin index.")
 
 	if len(preUpdateIndex) == 0 {
 		return nil
@@ -130,6 +294,25 @@ func ensureIndexUpdated(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// withRetries calls fn until it succeeds or has been retried the given number
+// of times, and returns the number of attempts made with the last error.
+func withRetries(retries int, backoff time.Duration, fn func() error) (int, error) {
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		if err = fn(); err == nil || attempt > retries {
+			return attempt, err
+		}
+		klog.Warningf("Attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
 func init() {
+	updateRetries = updateCmd.Flags().Int("retries", 0, "Number of times to retry updating the index if it fails")
+	updateCmd.Flags().Bool("shallow-snapshot", false, "store the index as a snapshot of its plugin manifests without git, downloaded from "+indexSnapshotEnv)
+	shallowSnapshot = updateCmd.Flags().Lookup("shallow-snapshot")
 	rootCmd.AddCommand(updateCmd)
 }
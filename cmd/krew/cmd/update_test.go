@@ -0,0 +1,99 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func Test_withRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "succeeds immediately", retries: 2, failures: 0, wantAttempts: 1},
+		{name: "succeeds after retry", retries: 2, failures: 2, wantAttempts: 3},
+		{name: "fails after retries", retries: 2, failures: 5, wantAttempts: 3, wantErr: true},
+		{name: "no retries", retries: 0, failures: 1, wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			attempts, err := withRetries(tt.retries, 0, func() error {
+				calls++
+				if calls <= tt.failures {
+					return errors.New("transient failure")
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withRetries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("withRetries() attempts = %d (calls = %d), want %d", attempts, calls, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_krewUpgradeNotice(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if notice := krewUpgradeNotice(p); notice != "" {
+		t.Errorf("expected no notice without krew installed, got %q", notice)
+	}
+
+	installed := testutil.NewPlugin().WithName(constants.KrewPluginName).WithVersion("v0.3.0").V()
+	if err := receipt.Store(receipt.New(installed, constants.DefaultIndexName), p.PluginInstallReceiptPath(constants.KrewPluginName)); err != nil {
+		t.Fatal(err)
+	}
+	writeIndexVersion := func(version string) {
+		b, err := yaml.Marshal(testutil.NewPlugin().WithName(constants.KrewPluginName).WithVersion(version).V())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir.Write(filepath.Join("index", "plugins", constants.KrewPluginName+constants.ManifestExtension), b)
+	}
+
+	writeIndexVersion("v0.3.0")
+	if notice := krewUpgradeNotice(p); notice != "" {
+		t.Errorf("expected no notice when krew is up to date, got %q", notice)
+	}
+
+	writeIndexVersion("v0.4.0")
+	notice := krewUpgradeNotice(p)
+	if !strings.Contains(notice, "v0.3.0 -> v0.4.0") {
+		t.Errorf("expected a notice about the new version, got %q", notice)
+	}
+}
@@ -15,20 +15,38 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
 	"sigs.k8s.io/krew/cmd/krew/cmd/internal"
-	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/index/validation"
 	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
 )
 
 func init() {
-	var noUpdateIndex *bool
+	var (
+		noUpdateIndex  *bool
+		toVersion      *string
+		reportOnlyJSON *bool
+		check          *bool
+		exclude        *[]string
+		verifyRun      *bool
+		requireRun     *bool
+		failOnError    *bool
+		checksumChange *bool
+		downgradeOK    *bool
+		manifestAfter  *string
+	)
 
 	// upgradeCmd represents the upgrade command
 	var upgradeCmd = &cobra.Command{
@@ -38,20 +56,140 @@ func init() {
 This will reinstall all plugins that have a newer version in the local index.
 Use "kubectl krew update" to renew the index.
 To only upgrade single plugins provide them as arguments:
-kubectl krew upgrade foo bar"`,
+kubectl krew upgrade foo bar"
+
+To upgrade a plugin to a specific version found in the index history, or to
+the highest version in a range:
+kubectl krew upgrade foo --to v1.2.0
+kubectl krew upgrade foo --to "~v1.2.0"
+kubectl krew upgrade foo@v1.2.0
+
+To upgrade all plugins except some, use --exclude (can be repeated):
+kubectl krew upgrade --exclude foo --exclude bar
+
+When upgrading all plugins, plugins that fail to upgrade are skipped with a
+warning. To still attempt all of them but exit with a nonzero status if any
+failed, use --fail-on-error:
+kubectl krew upgrade --fail-on-error
+
+To also reinstall plugins whose version did not change, but whose download
+for this platform has a new checksum (e.g. the same version was rebuilt), use
+--reinstall-on-checksum-change:
+kubectl krew upgrade --reinstall-on-checksum-change
+
+Plugins are never downgraded by default. If the index has an older version of a
+plugin than the installed one, e.g. because a bad release was pulled, use
+--downgrade-ok to install the version in the index instead:
+kubectl krew upgrade foo --downgrade-ok
+
+To record the manifests of the upgraded plugins, with the installed version and
+only the platform installed on this machine, in a file (or on stdout with "-"),
+use --print-manifest-after:
+kubectl krew upgrade --print-manifest-after=FILE
+
+To only report which plugins have upgrades available as JSON, without
+upgrading anything, use --report-only-json. If the plugin index cannot be
+updated, it reports from the local copy. It always exits with status 0 unless
+the report cannot be produced:
+kubectl krew upgrade --report-only-json
+
+To check whether upgrades are available, without upgrading anything, use
+--check. It lists the plugins that can be upgraded and exits with status 6 if
+there are any:
+kubectl krew upgrade --check`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var ignoreUpgraded bool
 			var skipErrors bool
 
-			var pluginNames []string
+			lock, err := lockIndexShared()
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+
+			to := *toVersion
+			for i, arg := range args {
+				name, spec := splitNameSpec(arg)
+				if spec == "" {
+					args[i] = name
+					continue
+				}
+				if len(args) != 1 {
+					return errors.New("NAME@VERSION can only be used when upgrading a single plugin")
+				}
+				if to != "" {
+					return errors.New("--to cannot be used with NAME@VERSION")
+				}
+				args[i], to = name, spec
+			}
+
+			if *check {
+				if to != "" || *reportOnlyJSON || *manifestAfter != "" {
+					return errors.New("--check cannot be used with --to, --report-only-json or --print-manifest-after")
+				}
+				return checkUpgrades(os.Stdout, args, *exclude)
+			}
+
+			if *failOnError && len(args) > 0 {
+				return errors.New("--fail-on-error can only be used when upgrading all plugins")
+			}
+
+			if *reportOnlyJSON {
+				if to != "" || *manifestAfter != "" {
+					return errors.New("--to and --print-manifest-after cannot be used with --report-only-json")
+				}
+				return reportUpgrades(os.Stdout, args)
+			}
+
+			opts, err := globalInstallOpts()
+			if err != nil {
+				return err
+			}
+			opts.VerifyRun = *verifyRun
+			opts.RequireRun = *requireRun
+			opts.ReinstallOnChecksumChange = *checksumChange
+			opts.DowngradeOK = *downgradeOK
+
+			if to != "" {
+				if len(*exclude) > 0 {
+					return errors.New("--exclude cannot be used with --to")
+				}
+				if len(args) != 1 {
+					return errors.New("--to can only be used when upgrading a single plugin")
+				}
+				spec, err := semver.ParseSpec(to)
+				if err != nil {
+					return errors.Wrapf(err, "invalid version %q specified", to)
+				}
+				// upgrading to the latest version is the same as without --to
+				if !spec.IsLatest() {
+					if err := checkIndexHistory("--to"); err != nil {
+						return err
+					}
+					if err := upgradeToVersion(args[0], spec, opts); err != nil {
+						return err
+					}
+					if *manifestAfter != "" {
+						return writeInstalledManifests(*manifestAfter, args)
+					}
+					return nil
+				}
+			}
+
+			var pluginNames, skipped []string
 			if len(args) == 0 {
 				// Upgrade all plugins.
 				installed, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
 				if err != nil {
 					return errors.Wrap(err, "failed to find all installed versions")
 				}
-				for name := range installed {
-					pluginNames = append(pluginNames, name)
+				for _, r := range installed {
+					if r.Status.DevLink != "" {
+						fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is linked to a development build\n", r.Name)
+						skipped = append(skipped, r.Name)
+						continue
+					}
+					pluginNames = append(pluginNames, r.Name)
 				}
 				ignoreUpgraded = true
 				skipErrors = true
@@ -59,51 +197,297 @@ kubectl krew upgrade foo bar"`,
 				// Upgrade certain plugins
 				pluginNames = args
 			}
+			pluginNames = excludePlugins(pluginNames, *exclude)
 
-			var nErrors int
+			var upgraded, failed, needNewerKrew, notInIndex []string
+			var hookErr error // first failure of a strict hook
+			progress := newProgressStream()
 			for _, name := range pluginNames {
-				plugin, err := indexscanner.LoadPluginByName(paths.IndexPluginsPath(), name)
+				plugin, err := loadChannelPlugin(paths, name, installedChannel(paths, name))
 				if err != nil {
+					if skipErrors && requiresNewerKrew(err) {
+						fmt.Fprintf(os.Stderr, "Skipping plugin %s, it requires a newer version of krew\n", name)
+						needNewerKrew = append(needNewerKrew, name)
+						continue
+					}
 					if !os.IsNotExist(err) {
-						return errors.Wrapf(err, "failed to load the plugin manifest for plugin %s", name)
+						return withPlugin(name, errors.Wrapf(err, "failed to load the plugin manifest for plugin %s", name))
 					} else if !skipErrors {
-						return errors.Errorf("plugin %q does not exist in the plugin index", name)
+						return withPlugin(name, withExitCode(exitNotFound, errors.Errorf("plugin %q does not exist in the plugin index%s", name, didYouMean(paths.IndexPluginsPath(), name))))
 					}
+					// e.g. installed from a custom manifest, nothing to upgrade to
+					fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is not in the plugin index\n", name)
+					notInIndex = append(notInIndex, name)
+					continue
 				}
 
-				if err == nil {
-					fmt.Fprintf(os.Stderr, "Upgrading plugin: %s\n", name)
-					err = installation.Upgrade(paths, plugin)
-					if ignoreUpgraded && err == installation.ErrIsAlreadyUpgraded {
-						fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is already on the newest version\n", name)
-						continue
-					}
+				fmt.Fprintf(os.Stderr, "Upgrading plugin: %s\n", name)
+				progress.emit(progressStart, name, plugin.Spec.Version, "")
+				opts.Progress = progress.forPlugin(name, plugin.Spec.Version)
+				err = upgradePlugin(plugin, opts)
+				if ignoreUpgraded && err == installation.ErrIsAlreadyUpgraded {
+					fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is already on the newest version\n", name)
+					progress.emit(progressDone, name, plugin.Spec.Version, "already on the newest version")
+					continue
 				}
 				if err != nil {
-					nErrors++
+					progress.emitError(name, plugin.Spec.Version, err)
+					failed = append(failed, name)
 					if skipErrors {
 						fmt.Fprintf(os.Stderr, "WARNING: failed to upgrade plugin %q, skipping (error: %v)\n", name, err)
 						continue
 					}
-					return errors.Wrapf(err, "failed to upgrade plugin %q", name)
+					return withPlugin(name, errors.Wrapf(err, "failed to upgrade plugin %q", name))
 				}
 				fmt.Fprintf(os.Stderr, "Upgraded plugin: %s\n", name)
 				internal.PrintSecurityNotice(plugin.Name)
+				if err := runHook(hookUpgrade, plugin.Name, plugin.Spec.Version); err != nil {
+					progress.emitError(name, plugin.Spec.Version, err)
+					fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+					if hookErr == nil {
+						hookErr = withPlugin(name, err)
+					}
+					failed = append(failed, name)
+					continue
+				}
+				progress.emit(progressDone, name, plugin.Spec.Version, "")
+				upgraded = append(upgraded, name)
+			}
+			if *manifestAfter != "" {
+				if err := writeInstalledManifests(*manifestAfter, upgraded); err != nil {
+					return err
+				}
 			}
-			if nErrors > 0 {
-				fmt.Fprintf(os.Stderr, "WARNING: Some plugins failed to upgrade, check logs above.\n")
+			if len(skipped) > 0 {
+				fmt.Fprintf(os.Stderr, "Skipped plugins linked to development builds: %v\n", skipped)
+			}
+			if len(notInIndex) > 0 {
+				fmt.Fprintf(os.Stderr, "Skipped plugins that are not in the plugin index: %v\n", notInIndex)
+			}
+			if len(needNewerKrew) > 0 {
+				fmt.Fprintf(os.Stderr, "WARNING: Skipped plugins that require a newer version of krew: %v. Upgrade krew with \"kubectl krew upgrade krew\".\n", needNewerKrew)
+			}
+			if len(failed) > 0 {
+				fmt.Fprintf(os.Stderr, "WARNING: Some plugins failed to upgrade: %v, check logs above.\n", failed)
+				if !skipErrors && hookErr != nil {
+					return hookErr
+				}
+				if *failOnError {
+					return errors.Errorf("failed to upgrade %d of %d plugin(s): %s", len(failed), len(pluginNames), strings.Join(failed, ", "))
+				}
 			}
 			return nil
 		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if *noUpdateIndex {
 				klog.V(4).Infof("--no-update-index specified, skipping updating local copy of plugin index")
-				return nil
+				return ensureIndex(cmd, args)
+			}
+			if err := ensureIndexUpdated(cmd, args); err != nil {
+				if !*reportOnlyJSON {
+					return err
+				}
+				// the report is still useful from the local copy of the index
+				klog.Warningf("Failed to update the local copy of plugin index, reporting from the current copy: %v", err)
 			}
-			return ensureIndexUpdated(cmd, args)
+			return ensureIndex(cmd, args)
 		},
 	}
 
 	noUpdateIndex = upgradeCmd.Flags().Bool("no-update-index", false, "(Experimental) do not update local copy of plugin index before upgrading")
+	toVersion = upgradeCmd.Flags().String("to", "", "upgrade the plugin to the specified version from the index history, \"latest\" or a range such as \">=v1.2.0 <v2.0.0\"")
+	exclude = upgradeCmd.Flags().StringArray("exclude", nil, "skip upgrading the specified plugin (can be repeated)")
+	reportOnlyJSON = upgradeCmd.Flags().Bool("report-only-json", false, "print the upgrade status of installed plugins as JSON without upgrading")
+	check = upgradeCmd.Flags().Bool("check", false, "list the plugins that have upgrades available without upgrading, and exit with a nonzero status if there are any")
+	verifyRun = upgradeCmd.Flags().Bool("verify-run", false, "run the upgraded plugins with --help and warn if they fail to run on this platform")
+	requireRun = upgradeCmd.Flags().Bool("require-run", false, "like --verify-run, but fail the upgrade if a plugin fails to run")
+	checksumChange = upgradeCmd.Flags().Bool("reinstall-on-checksum-change", false, "also reinstall plugins on the same version if the checksum of their download changed")
+	downgradeOK = upgradeCmd.Flags().Bool("downgrade-ok", false, "install the version in the index whenever it differs from the installed version, even if it is older")
+	failOnError = upgradeCmd.Flags().Bool("fail-on-error", false, "when upgrading all plugins, exit with a nonzero status if any plugin failed to upgrade")
+	manifestAfter = upgradeCmd.Flags().String("print-manifest-after", "", "after upgrading, write the manifests of the upgraded plugins for the installed platform to the specified file (\"-\" for stdout)")
 	rootCmd.AddCommand(upgradeCmd)
 }
+
+// requiresNewerKrew reports whether err is caused by a plugin manifest that
+// uses a newer apiVersion than this version of krew supports.
+func requiresNewerKrew(err error) bool {
+	apiErr, ok := errors.Cause(err).(validation.APIVersionError)
+	return ok && apiErr.IsNewer()
+}
+
+// excludePlugins returns the names that are not excluded. Excluded names that
+// are not among the names are reported with a warning.
+func excludePlugins(names, exclude []string) []string {
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[name] = true
+	}
+	matched := make(map[string]bool, len(exclude))
+	var out []string
+	for _, name := range names {
+		if skip[name] {
+			klog.V(1).Infof("Excluding plugin %s from the upgrade", name)
+			matched[name] = true
+			continue
+		}
+		out = append(out, name)
+	}
+	for _, name := range exclude {
+		if !matched[name] {
+			klog.Warningf("--exclude %q does not match any plugin to upgrade", name)
+			matched[name] = true // warn once
+		}
+	}
+	return out
+}
+
+// upgradePlugin upgrades the installed plugin. Krew itself is upgraded with
+// installation.UpgradeKrew, which does not replace the running krew until the
+// new version is verified to run.
+func upgradePlugin(plugin index.Plugin, opts installation.InstallOpts) error {
+	if plugin.Name == constants.KrewPluginName {
+		return installation.UpgradeKrew(paths, plugin, opts)
+	}
+	return installation.Upgrade(paths, plugin, opts)
+}
+
+// upgradeToVersion upgrades the plugin to the manifest that declares the given
+// version in the index history.
+func upgradeToVersion(name string, spec semver.Spec, opts installation.InstallOpts) error {
+	var current *index.Plugin
+	if plugin, err := loadChannelPlugin(paths, name, installedChannel(paths, name)); err == nil {
+		current = &plugin
+	} else if !os.IsNotExist(err) {
+		return withPlugin(name, errors.Wrapf(err, "failed to load the plugin manifest for plugin %s", name))
+	}
+	plugin, err := loadPluginVersion(name, current, spec)
+	if err != nil {
+		return withPlugin(name, err)
+	}
+	version := plugin.Spec.Version
+
+	fmt.Fprintf(os.Stderr, "Upgrading plugin: %s to %s\n", name, version)
+	progress := newProgressStream()
+	progress.emit(progressStart, name, version, "")
+	opts.Progress = progress.forPlugin(name, version)
+	err = upgradePlugin(plugin, opts)
+	if err != nil {
+		progress.emitError(name, version, err)
+	}
+	if err == installation.ErrIsAlreadyUpgraded && opts.DowngradeOK {
+		return withPlugin(name, withExitCode(exitUpToDate, errors.Errorf("plugin %q is already installed at %s", name, version)))
+	} else if err == installation.ErrIsAlreadyUpgraded {
+		return withPlugin(name, withExitCode(exitUpToDate, errors.Errorf("plugin %q is already installed at %s or a newer version, use --downgrade-ok to downgrade it", name, version)))
+	} else if err == installation.ErrIsDevLinked {
+		return withPlugin(name, errors.Errorf("plugin %q is linked to a development build, uninstall it first", name))
+	} else if err != nil {
+		return withPlugin(name, errors.Wrapf(err, "failed to upgrade plugin %q", name))
+	}
+	fmt.Fprintf(os.Stderr, "Upgraded plugin: %s\n", name)
+	internal.PrintSecurityNotice(plugin.Name)
+	if err := runHook(hookUpgrade, plugin.Name, plugin.Spec.Version); err != nil {
+		progress.emitError(name, version, err)
+		return err
+	}
+	progress.emit(progressDone, name, version, "")
+	return nil
+}
+
+// upgradeStatus describes whether an installed plugin is behind the version
+// available in the index.
+type upgradeStatus struct {
+	Plugin    string `json:"plugin"`
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+	Behind    bool   `json:"behind"`
+}
+
+// reportUpgrades writes the upgrade status of the installed plugins (or only
+// the named ones) as a JSON array to out. Plugins that are not in the index are
+// left out of the report.
+func reportUpgrades(out io.Writer, names []string) error {
+	report, err := upgradeStatuses(names)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(report), "failed to write upgrade report")
+}
+
+// checkUpgrades prints the installed plugins (or only the named ones) that
+// are behind the index and not excluded to out, and returns an error with the
+// exitUpgradesAvailable exit code if there are any.
+func checkUpgrades(out io.Writer, names, exclude []string) error {
+	statuses, err := upgradeStatuses(names)
+	if err != nil {
+		return err
+	}
+	var checked []string
+	for _, s := range statuses {
+		checked = append(checked, s.Plugin)
+	}
+	include := make(map[string]bool, len(checked))
+	for _, name := range excludePlugins(checked, exclude) {
+		include[name] = true
+	}
+
+	var rows [][]string
+	for _, s := range statuses {
+		if s.Behind && include[s.Plugin] {
+			rows = append(rows, []string{s.Plugin, s.Installed, s.Latest})
+		}
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(os.Stderr, "All plugins are on the newest version, no upgrades available.")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "Upgrades available (nothing was upgraded):")
+	if err := printTable(out, []string{"PLUGIN", "INSTALLED", "AVAILABLE"}, rows); err != nil {
+		return err
+	}
+	return withExitCode(exitUpgradesAvailable, errors.Errorf("%d plugin(s) have upgrades available", len(rows)))
+}
+
+// upgradeStatuses returns the upgrade status of the installed plugins (or only
+// the named ones). Plugins that are not in the index or are linked to a
+// development build are left out.
+func upgradeStatuses(names []string) ([]upgradeStatus, error) {
+	receipts, err := installation.ListInstalledPlugins(paths.InstallReceiptsPath())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find all installed versions")
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	report := []upgradeStatus{}
+	for _, r := range receipts {
+		if len(wanted) > 0 && !wanted[r.Name] {
+			continue
+		}
+		if r.Status.DevLink != "" {
+			klog.Warningf("plugin %q is linked to a development build, leaving it out of the report", r.Name)
+			continue
+		}
+		plugin, err := loadChannelPlugin(paths, r.Name, r.Status.Source.Channel)
+		if os.IsNotExist(err) {
+			klog.Warningf("plugin %q does not exist in the plugin index, leaving it out of the report", r.Name)
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to load the plugin manifest for plugin %s", r.Name)
+		}
+		behind, err := installation.NeedsUpgrade(r, plugin)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compare versions of plugin %s", r.Name)
+		}
+		report = append(report, upgradeStatus{
+			Plugin:    r.Name,
+			Installed: r.Spec.Version,
+			Latest:    plugin.Spec.Version,
+			Behind:    behind,
+		})
+	}
+	return report, nil
+}
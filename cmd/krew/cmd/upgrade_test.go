@@ -0,0 +1,85 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/index/validation"
+)
+
+func Test_requiresNewerKrew(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"newer apiVersion", errors.Wrap(validation.APIVersionError{APIVersion: "krew.googlecontainertools.github.com/v1"}, "load"), true},
+		{"older apiVersion", errors.Wrap(validation.APIVersionError{APIVersion: "krew.googlecontainertools.github.com/v1alpha1"}, "load"), false},
+		{"foreign apiVersion", validation.APIVersionError{APIVersion: "example.com/v9"}, false},
+		{"other error", os.ErrNotExist, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requiresNewerKrew(tt.err); got != tt.expected {
+				t.Errorf("requiresNewerKrew() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_excludePlugins(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		exclude  []string
+		expected []string
+	}{
+		{
+			name:     "no excludes",
+			names:    []string{"a", "b"},
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "excludes are removed",
+			names:    []string{"a", "b", "c"},
+			exclude:  []string{"c", "a"},
+			expected: []string{"b"},
+		},
+		{
+			name:     "unknown excludes are ignored",
+			names:    []string{"a", "b"},
+			exclude:  []string{"unknown", "b"},
+			expected: []string{"a"},
+		},
+		{
+			name:    "everything excluded",
+			names:   []string{"a"},
+			exclude: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := excludePlugins(tt.names, tt.exclude)
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("excludePlugins() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
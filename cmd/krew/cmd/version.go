@@ -15,12 +15,21 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"runtime"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"sigs.k8s.io/krew/internal/version"
-	"sigs.k8s.io/krew/pkg/constants"
+)
+
+var (
+	versionShort  *bool
+	versionOutput *string
 )
 
 // versionCmd represents the version command
@@ -36,13 +45,37 @@ Remarks:
   - BasePath is the root directory for krew installation.
   - IndexPath is the directory that stores the local copy of the index git repository.
   - InstallPath is the directory for plugin installations.
-  - BinPath is the directory for the symbolic links to the installed plugin executables.`,
+  - BinPath is the directory for the symbolic links to the installed plugin executables.
+
+  Use --short to only print the version, or -o json to print the version
+  information as JSON for scripts.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if *versionShort && *versionOutput != "" {
+			return errors.New("--short cannot be used with --output")
+		}
+		if *versionShort {
+			fmt.Fprintln(os.Stdout, version.GitTag())
+			return nil
+		}
+		switch *versionOutput {
+		case "":
+		case "json":
+			return printVersionJSON(os.Stdout)
+		default:
+			return errors.Errorf("unsupported output format %q, only \"json\" is supported", *versionOutput)
+		}
+
+		indexURI, err := defaultIndexURI()
+		if err != nil {
+			return err
+		}
+
 		conf := [][]string{
 			{"GitTag", version.GitTag()},
 			{"GitCommit", version.GitCommit()},
-			{"IndexURI", constants.IndexURI},
+			{"IndexURI", indexURI},
 			{"BasePath", paths.BasePath()},
+			{"CachePath", paths.CachePath()},
 			{"IndexPath", paths.IndexPath()},
 			{"InstallPath", paths.InstallPath()},
 			{"BinPath", paths.BinPath()},
@@ -51,6 +84,25 @@ Remarks:
 	},
 }
 
+// versionInfo is the version information of krew in the JSON output.
+type versionInfo struct {
+	GitVersion string `json:"gitVersion"`
+	GitCommit  string `json:"gitCommit"`
+	GoVersion  string `json:"goVersion"`
+}
+
+func printVersionJSON(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(versionInfo{
+		GitVersion: version.GitTag(),
+		GitCommit:  version.GitCommit(),
+		GoVersion:  runtime.Version(),
+	}), "failed to write version information")
+}
+
 func init() {
+	versionShort = versionCmd.Flags().Bool("short", false, "print only the version of krew")
+	versionOutput = versionCmd.Flags().StringP("output", "o", "", "output format, one of: json")
 	rootCmd.AddCommand(versionCmd)
 }
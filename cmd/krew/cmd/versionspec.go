@@ -0,0 +1,69 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// splitNameSpec splits a NAME@SPEC argument into the plugin name and the
+// version specification, which is empty if the argument has none.
+func splitNameSpec(arg string) (string, string) {
+	parts := strings.SplitN(arg, "@", 2)
+	if len(parts) == 1 {
+		return arg, ""
+	}
+	return parts[0], parts[1]
+}
+
+// loadPluginVersion returns the manifest of the highest version of the plugin
+// that matches spec, out of the versions in the index history and the version
+// of current, the manifest the plugin has in the index now. current is nil if
+// the plugin is no longer in the index. Latest resolves to current.
+func loadPluginVersion(name string, current *index.Plugin, spec semver.Spec) (index.Plugin, error) {
+	notFound := withExitCode(exitNotFound, errors.Errorf("no version of plugin %q matching %q is available in the plugin index", name, spec))
+	if spec.IsLatest() {
+		if current == nil {
+			return index.Plugin{}, notFound
+		}
+		return *current, nil
+	}
+	versions, err := indexscanner.PluginVersions(paths.IndexPath(), name)
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to list the versions of plugin %q in the index history", name)
+	}
+	if current != nil {
+		versions = append(versions, current.Spec.Version)
+	}
+	version, err := semver.Resolve(spec, versions)
+	if err == semver.ErrNoMatchingVersion {
+		return index.Plugin{}, notFound
+	} else if err != nil {
+		return index.Plugin{}, err
+	}
+	klog.V(1).Infof("Resolved %q to version %s of plugin %s", spec, version, name)
+	if current != nil && version == current.Spec.Version {
+		return *current, nil
+	}
+	plugin, err := indexscanner.LoadPluginAtVersion(paths.IndexPath(), name, version)
+	return plugin, errors.Wrapf(err, "failed to load version %s of plugin %q", version, name)
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func Test_loadPluginVersion(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer func(orig environment.Paths) { paths = orig }(paths)
+	paths = environment.NewPaths(tmpDir.Root())
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = paths.IndexPath()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	tmpDir.Write("index/README", nil)
+	git("init")
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v2.0.0"} {
+		b, err := yaml.Marshal(testutil.NewPlugin().WithName("foo").WithVersion(v).V())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir.Write("index/plugins/foo"+constants.ManifestExtension, b)
+		git("add", "-A")
+		git("commit", "-m", v)
+	}
+	current := testutil.NewPlugin().WithName("foo").WithVersion("v2.1.0-beta.1").V()
+
+	tests := []struct {
+		spec      string
+		current   bool
+		want      string
+		shouldErr bool
+	}{
+		{spec: "latest", current: true, want: "v2.1.0-beta.1"},
+		{spec: "latest", shouldErr: true},
+		{spec: "v1.0.0", current: true, want: "v1.0.0"},
+		{spec: "v1.0.0", want: "v1.0.0"},
+		{spec: "<v2.0.0", current: true, want: "v1.1.0"},
+		{spec: ">=v2.1.0-beta.1", current: true, want: "v2.1.0-beta.1"},
+		{spec: "^v3.0.0", current: true, shouldErr: true},
+	}
+	for _, tt := range tests {
+		spec, err := semver.ParseSpec(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cur := &current
+		if !tt.current {
+			cur = nil
+		}
+		got, err := loadPluginVersion("foo", cur, spec)
+		if (err != nil) != tt.shouldErr {
+			t.Errorf("loadPluginVersion(%q, current=%v) error = %v, shouldErr %v", tt.spec, tt.current, err, tt.shouldErr)
+			continue
+		}
+		if err == nil && got.Spec.Version != tt.want {
+			t.Errorf("loadPluginVersion(%q, current=%v) = %s, expected %s", tt.spec, tt.current, got.Spec.Version, tt.want)
+		}
+	}
+}
+
+func Test_splitNameSpec(t *testing.T) {
+	tests := []struct {
+		arg, name, spec string
+	}{
+		{"foo", "foo", ""},
+		{"foo@v1.2.0", "foo", "v1.2.0"},
+		{"foo@>=v1.0.0 <v2.0.0", "foo", ">=v1.0.0 <v2.0.0"},
+		{"foo@", "foo", ""},
+	}
+	for _, tt := range tests {
+		name, spec := splitNameSpec(tt.arg)
+		if name != tt.name || spec != tt.spec {
+			t.Errorf("splitNameSpec(%q) = %q, %q, want %q, %q", tt.arg, name, spec, tt.name, tt.spec)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+const (
+	// maxShortDescriptionLength is the length after which the short
+	// description is truncated in "kubectl krew search".
+	maxShortDescriptionLength = 50
+
+	// maxCaveatsLength is the length after which caveats are too long to be
+	// read after installing a plugin.
+	maxCaveatsLength = 1000
+)
+
+// lintWarning is a quality issue of a plugin manifest that doesn't make the
+// manifest invalid.
+type lintWarning struct {
+	// line is the line number in the manifest the warning refers to, or 0 if
+	// it refers to the whole manifest.
+	line int
+	msg  string
+}
+
+func (w lintWarning) String() string {
+	if w.line == 0 {
+		return w.msg
+	}
+	return fmt.Sprintf("line %d: %s", w.line, w.msg)
+}
+
+// lintPlugin checks the plugin manifest for common mistakes of plugin authors.
+// raw is the manifest file content, and is used to find the line numbers of
+// the reported issues.
+func lintPlugin(p index.Plugin, raw []byte) []lintWarning {
+	var warnings []lintWarning
+	warn := func(needle, format string, args ...interface{}) {
+		warnings = append(warnings, lintWarning{line: lineOf(raw, needle), msg: fmt.Sprintf(format, args...)})
+	}
+
+	if len(p.Spec.ShortDescription) > maxShortDescriptionLength {
+		warn("shortDescription:", "shortDescription is longer than %d characters and will be truncated in search results", maxShortDescriptionLength)
+	}
+	if len(p.Spec.Caveats) > maxCaveatsLength {
+		warn("caveats:", "caveats are longer than %d characters", maxCaveatsLength)
+	}
+
+	for i, pl := range p.Spec.Platforms {
+		if strings.HasPrefix(pl.URI, "http://") {
+			warn(pl.URI, "spec.platforms[%d].uri should use https", i)
+		}
+		if !binMatchesFiles(pl.Bin, pl.Files) {
+			warn("bin: "+pl.Bin, "spec.platforms[%d].bin %q is not a destination of any spec.platforms[%d].files operation", i, pl.Bin, i)
+		}
+	}
+
+	// invalid selectors are reported by the validation
+	if matches, err := installation.PlatformsByOSArch(p.Spec.Platforms, allPlatforms()); err == nil {
+		for _, gap := range archGaps(matches) {
+			warn("platforms:", "%s", gap)
+		}
+	}
+	return warnings
+}
+
+// binMatchesFiles checks whether the bin path could be the destination of one
+// of the file operations, the file names are matched with the pattern in from.
+func binMatchesFiles(bin string, files []index.FileOperation) bool {
+	if len(files) == 0 {
+		return true // defaults to copying everything
+	}
+	bin = path.Clean(bin)
+	for _, op := range files {
+		to := path.Clean(op.To)
+		var rel string
+		switch {
+		case bin == to:
+			return true
+		case to == ".":
+			rel = bin
+		case strings.HasPrefix(bin, to+"/"):
+			rel = strings.TrimPrefix(bin, to+"/")
+		default:
+			continue
+		}
+		if ok, _ := path.Match(path.Base(op.From), path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOf returns the 1-based number of the first line containing needle, or 0
+// if it's not found.
+func lineOf(raw []byte, needle string) int {
+	if needle == "" {
+		return 0
+	}
+	i := bytes.Index(raw, []byte(needle))
+	if i < 0 {
+		return 0
+	}
+	return bytes.Count(raw[:i], []byte("\n")) + 1
+}
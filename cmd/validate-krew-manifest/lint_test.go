@@ -0,0 +1,114 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func Test_lintPlugin(t *testing.T) {
+	var platforms []index.Platform
+	for _, env := range allPlatforms() {
+		platforms = append(platforms, testutil.NewPlatform().
+			WithOSArch(env.OS, env.Arch).
+			WithURI("https://example.com/foo.tar.gz").
+			WithFiles([]index.FileOperation{{From: "foo-*/kubectl-foo", To: "."}}).
+			WithBin("kubectl-foo").V())
+	}
+	good := testutil.NewPlugin().WithName("foo").WithShortDescription("Does foo").WithPlatforms(platforms...).V()
+	if warnings := lintPlugin(good, nil); len(warnings) > 0 {
+		t.Fatalf("expected no warnings, got: %v", warnings)
+	}
+
+	bad := testutil.NewPlugin().WithName("foo").
+		WithShortDescription(strings.Repeat("x", maxShortDescriptionLength+1)).
+		WithPlatforms(testutil.NewPlatform().WithOSArch("linux", "amd64").
+			WithURI("http://example.com/foo.tar.gz").
+			WithFiles([]index.FileOperation{{From: "*.sh", To: "."}}).
+			WithBin("kubectl-foo").V()).V()
+	bad.Spec.Caveats = strings.Repeat("x", maxCaveatsLength+1)
+	raw, err := yaml.Marshal(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, w := range lintPlugin(bad, raw) {
+		got = append(got, w.String())
+	}
+	all := strings.Join(got, "\n")
+	for _, want := range []string{
+		"shortDescription is longer than",
+		"caveats are longer than",
+		"uri should use https",
+		`bin "kubectl-foo" is not a destination`,
+		"linux/arm64 is not covered, but other linux platforms are",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("expected a warning containing %q, got:\n%s", want, all)
+		}
+	}
+	for _, unwanted := range []string{"linux/386", "linux/arm ", "darwin", "windows"} {
+		if strings.Contains(all, unwanted) {
+			t.Errorf("expected no warning about %q, optional architectures and unsupported operating systems are not gaps, got:\n%s", unwanted, all)
+		}
+	}
+	if !strings.Contains(all, "line ") {
+		t.Errorf("expected warnings with line context, got:\n%s", all)
+	}
+}
+
+func Test_binMatchesFiles(t *testing.T) {
+	tests := []struct {
+		bin   string
+		files []index.FileOperation
+		want  bool
+	}{
+		{"kubectl-foo", nil, true},
+		{"kubectl-foo", []index.FileOperation{{From: "foo-*/kubectl-foo", To: "."}}, true},
+		{"kubectl-foo", []index.FileOperation{{From: "*", To: "."}}, true},
+		{"bin/kubectl-foo", []index.FileOperation{{From: "build/*", To: "bin/"}}, true},
+		{"bin/kubectl-foo", []index.FileOperation{{From: "build/kubectl-foo", To: "bin/kubectl-foo"}}, true},
+		{"kubectl-foo", []index.FileOperation{{From: "*.sh", To: "."}}, false},
+		{"kubectl-foo", []index.FileOperation{{From: "kubectl-foo", To: "bin"}}, false},
+	}
+	for _, tt := range tests {
+		if got := binMatchesFiles(tt.bin, tt.files); got != tt.want {
+			t.Errorf("binMatchesFiles(%q, %+v) = %v, want %v", tt.bin, tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestValidateManifestFile_strict(t *testing.T) {
+	tmp, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	content, err := yaml.Marshal(testutil.NewPlugin().WithName("test").V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Write("test.yaml", content)
+
+	err = validateManifestFile(tmp.Path("test.yaml"), true)
+	if err == nil || !strings.Contains(err.Error(), "strict mode") {
+		t.Fatalf("expected warnings to fail in strict mode, got: %v", err)
+	}
+}
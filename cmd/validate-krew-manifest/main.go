@@ -18,6 +18,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -37,10 +38,18 @@ import (
 	"sigs.k8s.io/krew/pkg/index"
 )
 
-var flManifest string
+var (
+	flManifest  string
+	flStrict    bool
+	flPlatforms bool
+	flOutput    string
+)
 
 func init() {
 	flag.StringVar(&flManifest, "manifest", "", "path to plugin manifest file")
+	flag.BoolVar(&flStrict, "strict", false, "fail on warnings about common manifest mistakes")
+	flag.BoolVar(&flPlatforms, "platforms", false, "only print which os/arch pairs the manifest covers and common gaps, without validating it")
+	flag.StringVar(&flOutput, "o", "", "output format of -platforms, one of: json")
 }
 
 func main() {
@@ -57,12 +66,24 @@ func main() {
 		klog.Fatal("-manifest must be specified")
 	}
 
-	if err := validateManifestFile(flManifest); err != nil {
+	if flOutput != "" && flOutput != "json" {
+		klog.Fatalf("unsupported output format %q, must be: json", flOutput)
+	}
+	if flPlatforms {
+		if err := printManifestPlatforms(os.Stdout, flManifest, flOutput); err != nil {
+			klog.Fatalf("%v", err)
+		}
+		return
+	} else if flOutput != "" {
+		klog.Fatal("-o can only be used with -platforms")
+	}
+
+	if err := validateManifestFile(flManifest, flStrict); err != nil {
 		klog.Fatalf("%v", err) // with stack trace
 	}
 }
 
-func validateManifestFile(path string) error {
+func validateManifestFile(path string, strict bool) error {
 	klog.V(4).Infof("reading file %s", path)
 	p, err := indexscanner.ReadPluginFromFile(path)
 	if err != nil {
@@ -90,12 +111,34 @@ func validateManifestFile(path string) error {
 	}
 	klog.Infof("all spec.platform[] items are used")
 
+	// index manifests must be self-contained
+	for i, p := range p.Spec.Platforms {
+		if _, err := installation.ExpandURI(p.URI, false); err != nil {
+			return errors.Wrapf(err, "spec.platform[%d] has an invalid uri", i)
+		}
+	}
+
 	// validate no supported <os,arch> is matching multiple platform specs
 	if err := isOverlappingPlatformSelectors(p.Spec.Platforms); err != nil {
 		return errors.Wrap(err, "overlapping platform selectors found")
 	}
 	klog.Infof("no overlapping spec.platform[].selector")
 
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read plugin file")
+	}
+	if warnings := lintPlugin(p, raw); len(warnings) > 0 {
+		for _, w := range warnings {
+			klog.Warningf("%s: %s", path, w)
+		}
+		if strict {
+			return errors.Errorf("found %d warning(s) in strict mode", len(warnings))
+		}
+	} else {
+		klog.Infof("no warnings")
+	}
+
 	// exercise "install" for all platforms
 	for i, p := range p.Spec.Platforms {
 		klog.Infof("installing spec.platform[%d]", i)
@@ -108,6 +151,20 @@ func validateManifestFile(path string) error {
 	return nil
 }
 
+// printManifestPlatforms writes the platform coverage of the manifest file to
+// out. The gaps it reports are advisory and do not fail it.
+func printManifestPlatforms(out io.Writer, path, output string) error {
+	p, err := indexscanner.ReadPluginFromFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read plugin file")
+	}
+	report, err := reportPlatforms(p)
+	if err != nil {
+		return err
+	}
+	return printPlatformReport(out, report, output)
+}
+
 // isOverlappingPlatformSelectors validates if multiple platforms have selectors
 // that match to a supported <os,arch> pair.
 func isOverlappingPlatformSelectors(platforms []index.Platform) error {
@@ -199,5 +256,6 @@ func allPlatforms() []installation.OSArchPair {
 		{OS: "linux", Arch: "arm64"},
 		{OS: "darwin", Arch: "386"},
 		{OS: "darwin", Arch: "amd64"},
+		{OS: "darwin", Arch: "arm64"},
 	}
 }
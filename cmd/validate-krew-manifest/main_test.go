@@ -93,7 +93,7 @@ func TestValidateManifestFile(t *testing.T) {
 				tmp.Write(test.manifestFile, content)
 			}
 
-			err := validateManifestFile(tmp.Path(test.manifestFile))
+			err := validateManifestFile(tmp.Path(test.manifestFile), false)
 			if test.shouldErr {
 				if err == nil {
 					t.Errorf("Expected an error '%s' but found none", test.errMsg)
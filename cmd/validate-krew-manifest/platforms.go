@@ -0,0 +1,177 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/installation"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// commonArchs are the architectures a plugin is expected to support on every
+// OS it supports at all, if krew supports them there.
+var commonArchs = []string{"amd64", "arm64"}
+
+// platformCoverage tells which spec.platforms[] entry is installed on an
+// os/arch pair.
+type platformCoverage struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Platform is the index of the entry in spec.platforms, nil if none
+	// matches.
+	Platform *int `json:"platform"`
+}
+
+// platformReport is the platform coverage of a manifest for all supported
+// os/arch pairs, with advisory notes about common gaps.
+type platformReport struct {
+	Platforms []platformCoverage `json:"platforms"`
+	Gaps      []string           `json:"gaps"`
+}
+
+// reportPlatforms returns the platform coverage of the plugin.
+func reportPlatforms(p index.Plugin) (platformReport, error) {
+	envs := allPlatforms()
+	matches, err := installation.PlatformsByOSArch(p.Spec.Platforms, envs)
+	if err != nil {
+		return platformReport{}, errors.Wrap(err, "failed to match spec.platforms")
+	}
+	report := platformReport{Platforms: make([]platformCoverage, 0, len(envs)), Gaps: []string{}}
+	coveredOS := make(map[string]bool)
+	for _, env := range envs {
+		c := platformCoverage{OS: env.OS, Arch: env.Arch}
+		if i, ok := matches[env]; ok {
+			i := i
+			c.Platform = &i
+			coveredOS[env.OS] = true
+		}
+		report.Platforms = append(report.Platforms, c)
+	}
+	if len(coveredOS) == 0 {
+		return report, nil
+	}
+
+	for _, goos := range platformOSes(envs) {
+		if !coveredOS[goos] {
+			report.Gaps = append(report.Gaps, fmt.Sprintf("no spec.platforms[] entry for %s", goos))
+		}
+	}
+	report.Gaps = append(report.Gaps, archGaps(matches)...)
+	return report, nil
+}
+
+// archGaps returns a note for each of the commonArchs that is not covered on
+// an OS that has other platforms covered, such as darwin/arm64 missing next to
+// darwin/amd64. Less common architectures and operating systems without any
+// platform are not reported.
+func archGaps(matches map[installation.OSArchPair]int) []string {
+	covered := make(map[string]bool)
+	for env := range matches {
+		covered[env.OS] = true
+	}
+	var gaps []string
+	for _, goos := range platformOSes(allPlatforms()) {
+		if !covered[goos] {
+			continue
+		}
+		for _, arch := range commonArchs {
+			env := installation.OSArchPair{OS: goos, Arch: arch}
+			if _, ok := matches[env]; !ok && isSupportedPlatform(env) {
+				gaps = append(gaps, fmt.Sprintf("%s is not covered, but other %s platforms are", env, goos))
+			}
+		}
+	}
+	return gaps
+}
+
+// platformOSes returns the distinct operating systems of envs in order.
+func platformOSes(envs []installation.OSArchPair) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, env := range envs {
+		if !seen[env.OS] {
+			seen[env.OS] = true
+			out = append(out, env.OS)
+		}
+	}
+	return out
+}
+
+func isSupportedPlatform(env installation.OSArchPair) bool {
+	for _, p := range allPlatforms() {
+		if p == env {
+			return true
+		}
+	}
+	return false
+}
+
+// printPlatformReport writes the report to out as an OS by architecture
+// matrix of spec.platforms[] indexes, or as JSON if output is "json".
+func printPlatformReport(out io.Writer, report platformReport, output string) error {
+	if output == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(report), "failed to write the platform report")
+	}
+
+	var oses, archs []string
+	seen := make(map[string]bool)
+	cells := make(map[installation.OSArchPair]string, len(report.Platforms))
+	for _, c := range report.Platforms {
+		if !seen["os:"+c.OS] {
+			seen["os:"+c.OS] = true
+			oses = append(oses, c.OS)
+		}
+		if !seen["arch:"+c.Arch] {
+			seen["arch:"+c.Arch] = true
+			archs = append(archs, c.Arch)
+		}
+		env := installation.OSArchPair{OS: c.OS, Arch: c.Arch}
+		cells[env] = "-"
+		if c.Platform != nil {
+			cells[env] = strconv.Itoa(*c.Platform)
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "OS\t%s\n", strings.Join(archs, "\t"))
+	for _, goos := range oses {
+		row := []string{goos}
+		for _, arch := range archs {
+			row = append(row, cells[installation.OSArchPair{OS: goos, Arch: arch}])
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Cells are the index of the spec.platforms[] entry installed on each os/arch, \"-\" if none matches (blank if krew does not support it).")
+	if len(report.Gaps) > 0 {
+		fmt.Fprintln(out, "Possible gaps:")
+		for _, g := range report.Gaps {
+			fmt.Fprintf(out, "  %s\n", g)
+		}
+	}
+	return nil
+}
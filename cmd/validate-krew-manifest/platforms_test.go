@@ -0,0 +1,86 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func Test_reportPlatforms(t *testing.T) {
+	p := testutil.NewPlugin().WithPlatforms(
+		testutil.NewPlatform().WithOSArch("darwin", "amd64").V(),
+		testutil.NewPlatform().WithOS("linux").V(),
+	).V()
+	report, err := reportPlatforms(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	covered := make(map[string]int)
+	for _, c := range report.Platforms {
+		if c.Platform != nil {
+			covered[c.OS+"/"+c.Arch] = *c.Platform
+		}
+	}
+	wantCovered := map[string]int{"darwin/amd64": 0, "linux/386": 1, "linux/amd64": 1, "linux/arm": 1, "linux/arm64": 1}
+	if diff := cmp.Diff(wantCovered, covered); diff != "" {
+		t.Errorf("reportPlatforms() coverage mismatch:\n%s", diff)
+	}
+	wantGaps := []string{"no spec.platforms[] entry for windows", "darwin/arm64 is not covered, but other darwin platforms are"}
+	if diff := cmp.Diff(wantGaps, report.Gaps); diff != "" {
+		t.Errorf("reportPlatforms() gaps mismatch:\n%s", diff)
+	}
+}
+
+func Test_printPlatformReport(t *testing.T) {
+	p := testutil.NewPlugin().WithPlatforms(testutil.NewPlatform().WithOS("linux").V()).V()
+	report, err := reportPlatforms(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := printPlatformReport(&buf, report, ""); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if got := strings.Fields(lines[0]); !cmp.Equal(got, []string{"OS", "386", "amd64", "arm", "arm64"}) {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	for _, want := range []string{"linux    0    0      0    0", "no spec.platforms[] entry for darwin"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := printPlatformReport(&buf, report, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got platformReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if diff := cmp.Diff(report, got); diff != "" {
+		t.Errorf("JSON output mismatch:\n%s", diff)
+	}
+}
@@ -14,7 +14,12 @@
 
 package integrationtest
 
-import "testing"
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/pkg/errors"
+)
 
 func TestUnknownCommand(t *testing.T) {
 	skipShort(t)
@@ -26,3 +31,32 @@ func TestUnknownCommand(t *testing.T) {
 		t.Errorf("Expected `krew foobar` to fail")
 	}
 }
+
+func TestExitCodes(t *testing.T) {
+	skipShort(t)
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+	test = test.WithIndex()
+
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{args: []string{"foobar"}, expected: 1},
+		{args: []string{"install", "does-not-exist"}, expected: 3},
+		{args: []string{"uninstall", "not-installed"}, expected: 3},
+		{args: []string{"info", "does-not-exist"}, expected: 3},
+	}
+	for _, tt := range tests {
+		err := test.Krew(tt.args...).Run()
+		exitErr, ok := errors.Cause(err).(*exec.ExitError)
+		if !ok {
+			t.Errorf("krew %v: expected exit error, got %v", tt.args, err)
+			continue
+		}
+		if code := exitErr.ExitCode(); code != tt.expected {
+			t.Errorf("krew %v exited with %d, expected %d", tt.args, code, tt.expected)
+		}
+	}
+}
@@ -15,9 +15,12 @@
 package integrationtest
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/pkg/constants"
 )
 
 func TestKrewList(t *testing.T) {
@@ -42,3 +45,26 @@ func TestKrewList(t *testing.T) {
 	}
 	// TODO(ahmetb): install multiple plugins and see if the output is sorted
 }
+
+func TestKrewList_IndexFilter(t *testing.T) {
+	skipShort(t)
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+
+	test.WithIndex().Krew("install", validPlugin).RunOrFail()
+	test.Krew("install",
+		"--manifest", filepath.Join("testdata", fooPlugin+constants.ManifestExtension),
+		"--archive", filepath.Join("testdata", fooPlugin+".tar.gz")).
+		RunOrFail()
+
+	indexList := test.Krew("list", "--index", constants.DefaultIndexName).RunOrFailOutput()
+	if diff := cmp.Diff(indexList, []byte(validPlugin+"\n")); diff != "" {
+		t.Fatalf("'list --index' output doesn't match:\n%s", diff)
+	}
+
+	manifestList := test.Krew("list", "--index", "(manifest)").RunOrFailOutput()
+	if diff := cmp.Diff(manifestList, []byte(fooPlugin+"\n")); diff != "" {
+		t.Fatalf("'list --index (manifest)' output doesn't match:\n%s", diff)
+	}
+}
@@ -15,6 +15,8 @@
 package integrationtest
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -46,3 +48,28 @@ func TestKrewSearchOne(t *testing.T) {
 		t.Errorf("The first match should be krew")
 	}
 }
+
+func TestKrewSearch_readOnlyLocks(t *testing.T) {
+	skipShort(t)
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+	test.WithIndex()
+
+	locks := filepath.Join(test.Root(), "locks")
+	if err := os.MkdirAll(locks, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locks, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locks, 0755)
+
+	plugins := lines(test.Krew("search", "krew").RunOrFailOutput())
+	if len(plugins) < 2 {
+		t.Errorf("Expected search to work without a lock file")
+	}
+}
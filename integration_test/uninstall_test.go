@@ -46,6 +46,33 @@ func TestKrewUninstall(t *testing.T) {
 	}
 }
 
+func TestKrewUninstall_ContinuesOnError(t *testing.T) {
+	skipShort(t)
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+
+	test = test.WithIndex()
+	test.Krew("install", validPlugin).RunOrFailOutput()
+
+	if err := test.Krew("uninstall", "not-installed", validPlugin).Run(); err == nil {
+		t.Fatal("expected failure when one of the plugins is not installed")
+	}
+	test.AssertExecutableNotInPATH("kubectl-" + validPlugin)
+}
+
+func TestKrewUninstall_IgnoreNotFound(t *testing.T) {
+	skipShort(t)
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+
+	test = test.WithIndex()
+	test.Krew("install", validPlugin).RunOrFailOutput()
+	test.Krew("uninstall", "--ignore-not-found", "not-installed", validPlugin).RunOrFailOutput()
+	test.AssertExecutableNotInPATH("kubectl-" + validPlugin)
+}
+
 func TestKrewRemove_AliasSupported(t *testing.T) {
 	skipShort(t)
 
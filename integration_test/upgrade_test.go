@@ -16,10 +16,13 @@ package integrationtest
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
+
 	"sigs.k8s.io/krew/pkg/constants"
 )
 
@@ -50,6 +53,30 @@ func TestKrewUpgrade(t *testing.T) {
 	}
 }
 
+func TestKrewUpgrade_check(t *testing.T) {
+	skipShort(t)
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+
+	test.WithIndex().
+		Krew("install", "--manifest", filepath.Join("testdata", validPlugin+constants.ManifestExtension)).
+		RunOrFail()
+	initialLocation := resolvePluginSymlink(test, validPlugin)
+
+	err := test.Krew("upgrade", "--check").Run()
+	exitErr, ok := errors.Cause(err).(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 6 {
+		t.Fatalf("expected upgrade --check to exit with 6 when upgrades are available, got %v", err)
+	}
+	if location := resolvePluginSymlink(test, validPlugin); location != initialLocation {
+		t.Errorf("upgrade --check changed the installed plugin from %q to %q", initialLocation, location)
+	}
+
+	test.Krew("upgrade").RunOrFail()
+	test.Krew("upgrade", "--check", "--no-update-index").RunOrFail()
+}
+
 func TestKrewUpgradeWhenPlatformNoLongerMatches(t *testing.T) {
 	skipShort(t)
 
@@ -67,6 +94,9 @@ func TestKrewUpgradeWhenPlatformNoLongerMatches(t *testing.T) {
 	if !strings.Contains(out, "WARNING: Some plugins failed to upgrade") {
 		t.Fatalf("upgrade all plugins output doesn't contain warnings about failed plugins:\n%s", out)
 	}
+	if err := test.Krew("upgrade", "--no-update-index", "--fail-on-error").Run(); err == nil {
+		t.Fatal("expected upgrade --fail-on-error to fail when a plugin failed to upgrade")
+	}
 
 	// if upgrading a specific plugin, it must fail, because no longer matching to a platform
 	err := test.Krew("upgrade", validPlugin, "--no-update-index").Run()
@@ -44,3 +44,15 @@ func TestKrewVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestKrewVersion_Short(t *testing.T) {
+	skipShort(t)
+
+	test, cleanup := NewTest(t)
+	defer cleanup()
+
+	output := test.Krew("version", "--short").RunOrFailOutput()
+	if lines := regexp.MustCompile(`\n`).FindAllIndex(output, -1); len(lines) != 1 {
+		t.Errorf("expected a single line from `krew version --short`, got: %q", output)
+	}
+}
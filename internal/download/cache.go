@@ -0,0 +1,284 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// Cache is a content-addressed cache of downloads, stored as files named by
+// their sha256 checksum in a directory. Downloads are only added to the cache
+// once their content matches the checksum. Files can also be put there
+// manually to pre-seed the cache, for example on machines without network
+// access. Cached files are checked against their checksum when they are
+// used, and are downloaded again if they don't match.
+//
+// When the cache grows larger than its maximum size, the least recently used
+// files are removed.
+type Cache struct {
+	dir     string
+	maxSize int64 // in bytes, 0 for no limit
+}
+
+// NewCache returns the cache in dir, which is created when a download is first
+// added. maxSize is the size in bytes the cache is trimmed to, 0 for no limit.
+func NewCache(dir string, maxSize int64) *Cache {
+	return &Cache{dir: dir, maxSize: maxSize}
+}
+
+// path returns the path of the cached file with the checksum.
+func (c *Cache) path(sha256sum string) string {
+	return filepath.Join(c.dir, strings.ToLower(sha256sum))
+}
+
+// Has reports whether the download with the checksum is cached.
+func (c *Cache) Has(sha256sum string) bool {
+	if !isSha256(sha256sum) {
+		return false
+	}
+	fi, err := os.Stat(c.path(sha256sum))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// Fetcher returns a Fetcher that reads the download with the checksum from
+// the cache, or gets it with f and adds it to the cache once it was read
+// completely and matches the checksum. The content read from the cache is not
+// trusted, it is verified like any other download.
+func (c *Cache) Fetcher(f Fetcher, sha256sum string) Fetcher {
+	if !isSha256(sha256sum) {
+		return f
+	}
+	return cachingFetcher{cache: c, fetcher: f, sum: strings.ToLower(sha256sum)}
+}
+
+// Prune removes all files of the cache and returns how many files and bytes
+// were removed.
+func (c *Cache) Prune() (int, int64, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, 0, err
+	}
+	var n int
+	var size int64
+	for _, fi := range files {
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+			return n, size, errors.Wrapf(err, "failed to remove cached download %q", fi.Name())
+		}
+		n++
+		size += fi.Size()
+	}
+	return n, size, nil
+}
+
+// Remove removes the download with the checksum from the cache and reports
+// whether it was cached.
+func (c *Cache) Remove(sha256sum string) (bool, error) {
+	if !isSha256(sha256sum) {
+		return false, nil
+	}
+	err := os.Remove(c.path(sha256sum))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to remove cached download %q", strings.ToLower(sha256sum))
+	}
+	return true, nil
+}
+
+// files returns the files in the cache directory, which doesn't have to exist.
+func (c *Cache) files() ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read the download cache")
+	}
+	files := entries[:0]
+	for _, fi := range entries {
+		// skip the temporary files of downloads in progress
+		if fi.Mode().IsRegular() && !strings.HasPrefix(fi.Name(), ".") {
+			files = append(files, fi)
+		}
+	}
+	return files, nil
+}
+
+// trim removes the least recently used files until the cache is not larger
+// than its maximum size.
+func (c *Cache) trim() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, fi := range files {
+		size += fi.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, fi := range files {
+		if size <= c.maxSize {
+			break
+		}
+		klog.V(2).Infof("Evicting %q from the download cache", fi.Name())
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to evict %q from the download cache", fi.Name())
+		}
+		size -= fi.Size()
+	}
+	return nil
+}
+
+var _ Fetcher = cachingFetcher{}
+
+type cachingFetcher struct {
+	cache   *Cache
+	fetcher Fetcher
+	sum     string
+}
+
+func (f cachingFetcher) Get(uri string) (io.ReadCloser, error) {
+	path := f.cache.path(f.sum)
+	if file, err := os.Open(path); err == nil {
+		if err := verifyCached(file, f.sum); err != nil {
+			file.Close()
+			klog.Warningf("Removing the cached download %q and downloading it again: %v", path, err)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				klog.V(1).Infof("Failed to remove the cached download %q: %v", path, err)
+			}
+		} else {
+			klog.V(1).Infof("Using the cached download %q for %s", path, uri)
+			now := time.Now()
+			if err := os.Chtimes(path, now, now); err != nil {
+				klog.V(2).Infof("Failed to mark %q as recently used: %v", path, err)
+			}
+			return file, nil
+		}
+	}
+
+	body, err := f.fetcher.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(f.cache.dir, 0755); err != nil {
+		klog.V(1).Infof("Not caching the download, failed to create the cache directory: %v", err)
+		return body, nil
+	}
+	tmp, err := ioutil.TempFile(f.cache.dir, ".download-")
+	if err != nil {
+		klog.V(1).Infof("Not caching the download, failed to create a file in the cache: %v", err)
+		return body, nil
+	}
+	w := &cacheWriter{cache: f.cache, sum: f.sum, file: tmp, hash: sha256.New()}
+	return &teeReadCloser{Reader: io.TeeReader(body, w), body: body, w: w}, nil
+}
+
+// verifyCached checks that the content of the cached file matches the checksum,
+// and rewinds the file to its start.
+func verifyCached(file *os.File, sum string) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return errors.Wrap(err, "failed to read the cached download")
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return errors.Errorf("its checksum is %s, expected %s", got, sum)
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	return errors.Wrap(err, "failed to read the cached download")
+}
+
+// cacheWriter writes a download to a temporary file in the cache, which is
+// moved in place if the download matches the checksum.
+type cacheWriter struct {
+	cache *Cache
+	sum   string
+	file  *os.File
+	hash  hash.Hash
+	err   error
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		if _, err := w.file.Write(p); err != nil {
+			w.err = err
+		}
+		w.hash.Write(p)
+	}
+	return len(p), nil // failing to cache doesn't fail the download
+}
+
+// commit adds the written file to the cache if it is complete and matches the
+// checksum, or removes it.
+func (w *cacheWriter) commit(complete bool) {
+	tmp := w.file.Name()
+	if err := w.file.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if !complete || w.err != nil || hex.EncodeToString(w.hash.Sum(nil)) != w.sum {
+		klog.V(2).Infof("Not caching the download (complete=%v, err=%v)", complete, w.err)
+		os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, w.cache.path(w.sum)); err != nil {
+		klog.V(1).Infof("Failed to add the download to the cache: %v", err)
+		os.Remove(tmp)
+		return
+	}
+	klog.V(2).Infof("Added the download to the cache as %q", w.cache.path(w.sum))
+	if err := w.cache.trim(); err != nil {
+		klog.Warningf("Failed to trim the download cache: %v", err)
+	}
+}
+
+// teeReadCloser copies the body it reads to the cacheWriter, and commits the
+// cached file when it is closed.
+type teeReadCloser struct {
+	io.Reader
+	body     io.ReadCloser
+	w        *cacheWriter
+	complete bool
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.Reader.Read(p)
+	if err == io.EOF {
+		t.complete = true
+	}
+	return n, err
+}
+
+func (t *teeReadCloser) Close() error {
+	t.w.commit(t.complete)
+	return t.body.Close()
+}
+
+func isSha256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+// countingFetcher serves content and counts how often it was fetched.
+type countingFetcher struct {
+	content []byte
+	n       *int
+}
+
+func (f countingFetcher) Get(_ string) (io.ReadCloser, error) {
+	*f.n++
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func sum(b []byte) string { return fmt.Sprintf("%x", sha256.Sum256(b)) }
+
+func fetchAll(t *testing.T, f Fetcher) []byte {
+	t.Helper()
+	body, err := f.Get("https://example.com/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCache_Fetcher(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	cache := NewCache(tmpDir.Path("cache"), 0)
+	content := []byte("archive")
+
+	var n int
+	f := cache.Fetcher(countingFetcher{content: content, n: &n}, sum(content))
+	for i := 0; i < 2; i++ {
+		if got := fetchAll(t, f); !bytes.Equal(got, content) {
+			t.Fatalf("fetch %d returned %q, expected %q", i, got, content)
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected the download to be fetched once, got %d", n)
+	}
+	if !cache.Has(sum(content)) {
+		t.Error("expected the download to be cached")
+	}
+}
+
+func TestCache_Fetcher_notCached(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	cache := NewCache(tmpDir.Path("cache"), 0)
+	content := []byte("archive")
+
+	// the content does not match the checksum
+	var n int
+	wrongSum := sum([]byte("other"))
+	fetchAll(t, cache.Fetcher(countingFetcher{content: content, n: &n}, wrongSum))
+	if cache.Has(wrongSum) {
+		t.Error("expected a download that does not match its checksum not to be cached")
+	}
+
+	// the download is not read completely
+	body, err := cache.Fetcher(countingFetcher{content: content, n: &n}, sum(content)).Get("https://example.com/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := body.Read(make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+	if cache.Has(sum(content)) {
+		t.Error("expected a partial download not to be cached")
+	}
+
+	entries, err := ioutil.ReadDir(tmpDir.Path("cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files left in the cache, got %d", len(entries))
+	}
+}
+
+func TestCache_Fetcher_corrupted(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	cache := NewCache(tmpDir.Path("cache"), 0)
+	content := []byte("archive")
+	tmpDir.Write(filepath.Join("cache", sum(content)), []byte("corrupted"))
+
+	var n int
+	f := cache.Fetcher(countingFetcher{content: content, n: &n}, sum(content))
+	if got := fetchAll(t, f); !bytes.Equal(got, content) {
+		t.Fatalf("fetch returned %q, expected %q", got, content)
+	}
+	if n != 1 {
+		t.Errorf("expected the corrupted download to be fetched again, got %d fetches", n)
+	}
+	b, err := ioutil.ReadFile(tmpDir.Path(filepath.Join("cache", sum(content))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Errorf("expected the corrupted cache entry to be replaced, got %q", b)
+	}
+}
+
+func TestCache_trim(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	old, recent := []byte("old download"), []byte("recent download")
+	tmpDir.Write("cache/"+sum(old), old)
+	tmpDir.Write("cache/"+sum(recent), recent)
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(tmpDir.Path("cache/"+sum(old)), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCache(tmpDir.Path("cache"), int64(len(recent)))
+	if err := cache.trim(); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Has(sum(old)) {
+		t.Error("expected the least recently used download to be evicted")
+	}
+	if !cache.Has(sum(recent)) {
+		t.Error("expected the recently used download to be kept")
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	cache := NewCache(tmpDir.Path("cache"), 0)
+	if n, _, err := cache.Prune(); err != nil || n != 0 {
+		t.Fatalf("Prune() of missing cache = %d, %v", n, err)
+	}
+
+	content := []byte("archive")
+	tmpDir.Write("cache/"+sum(content), content)
+	n, size, err := cache.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || size != int64(len(content)) {
+		t.Errorf("Prune() = %d files, %d bytes, expected 1 file, %d bytes", n, size, len(content))
+	}
+	if cache.Has(sum(content)) {
+		t.Error("expected the cache to be empty")
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	cache := NewCache(tmpDir.Path("cache"), 0)
+
+	content := []byte("archive")
+	tmpDir.Write("cache/"+sum(content), content)
+	if removed, err := cache.Remove(strings.ToUpper(sum(content))); err != nil || !removed {
+		t.Fatalf("Remove() = %v, %v, expected the cached download to be removed", removed, err)
+	}
+	if cache.Has(sum(content)) {
+		t.Error("expected the download to be removed from the cache")
+	}
+	if removed, err := cache.Remove(sum(content)); err != nil || removed {
+		t.Errorf("Remove() of download that is not cached = %v, %v", removed, err)
+	}
+	if removed, err := cache.Remove("../foo"); err != nil || removed {
+		t.Errorf("Remove() of invalid checksum = %v, %v", removed, err)
+	}
+}
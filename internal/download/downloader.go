@@ -203,7 +203,7 @@ func extractArchive(dst string, at io.ReaderAt, size int64) error {
 	klog.V(4).Infof("detected %q file type", t)
 	exf, ok := defaultExtractors[t]
 	if !ok {
-		return errors.Errorf("mime type %q for archive file is not a supported archive format", t)
+		return errors.Errorf("mime type %q for archive file is not a supported archive format (if the file is the plugin executable itself, the manifest should set rawBinary)", t)
 	}
 	return errors.Wrap(exf(dst, at, size), "failed to extract file")
 
@@ -232,3 +232,97 @@ func (d Downloader) Get(uri, dst string) error {
 	}
 	return extractArchive(dst, body, size)
 }
+
+// GetBinary pulls the uri of an executable that is not packaged in an archive
+// and verifies it. On success, the file is written to dst with executable
+// permissions.
+func (d Downloader) GetBinary(uri, dst string) error {
+	body, size, err := download(uri, d.verifier, d.fetcher)
+	if err != nil {
+		return err
+	}
+	t, err := detectMIMEType(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine content type")
+	}
+	if _, ok := defaultExtractors[t]; ok {
+		return errors.Errorf("downloaded file was declared as a raw binary, but it is an archive of mime type %q", t)
+	}
+	return writeBinary(dst, body, size)
+}
+
+// GetFile pulls the uri and verifies it. On success, the download is written
+// to dst as is, without extracting it.
+func (d Downloader) GetFile(uri, dst string) error {
+	body, size, err := download(uri, d.verifier, d.fetcher)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrap(err, "failed to create directory for the download")
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %q", dst)
+	}
+	defer f.Close()
+	_, err = io.Copy(f, io.NewSectionReader(body, 0, size))
+	return errors.Wrapf(err, "failed to write download to %q", dst)
+}
+
+// GetArchiveOrBinary pulls the uri and verifies it. If the download is an
+// archive, it gets extracted into dst. If it is an executable (ELF, Mach-O or
+// PE) instead, it is written to binPath with executable permissions.
+func (d Downloader) GetArchiveOrBinary(uri, dst, binPath string) error {
+	body, size, err := download(uri, d.verifier, d.fetcher)
+	if err != nil {
+		return err
+	}
+	t, err := detectMIMEType(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine content type")
+	}
+	if _, ok := defaultExtractors[t]; !ok && isExecutable(body) {
+		klog.V(2).Infof("Downloaded file is an executable, not an archive")
+		return writeBinary(binPath, body, size)
+	}
+	return extractArchive(dst, body, size)
+}
+
+// executableMagics are the leading bytes of ELF, Mach-O (32/64-bit in both
+// byte orders, and universal) and PE executables.
+var executableMagics = [][]byte{
+	{0x7f, 'E', 'L', 'F'},
+	{0xfe, 0xed, 0xfa, 0xce},
+	{0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe},
+	{0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+	{'M', 'Z'},
+}
+
+// isExecutable reports whether the content starts like an executable binary.
+func isExecutable(at io.ReaderAt) bool {
+	buf := make([]byte, 4)
+	n, _ := at.ReadAt(buf, 0)
+	for _, magic := range executableMagics {
+		if bytes.HasPrefix(buf[:n], magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBinary writes the content to dst with executable permissions.
+func writeBinary(dst string, body io.ReaderAt, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrap(err, "failed to create directory for the binary")
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %q", dst)
+	}
+	defer f.Close()
+	_, err = io.Copy(f, io.NewSectionReader(body, 0, size))
+	return errors.Wrapf(err, "failed to write binary to %q", dst)
+}
@@ -203,6 +203,114 @@ func TestDownloader_Get(t *testing.T) {
 	}
 }
 
+func TestDownloader_GetBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "raw executable", file: "bash-utf8-file"},
+		{name: "raw ELF executable", file: "raw-elf"},
+		{name: "raw Mach-O executable", file: "raw-macho"},
+		{name: "raw PE executable", file: "raw-pe"},
+		{name: "archive declared as binary", file: "test-with-directory.zip", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			dst := tmpDir.Path("bin/kubectl-foo")
+			d := NewDownloader(newTrueVerifier(), NewFileFetcher(filepath.Join(testdataPath(), tt.file)))
+			err := d.GetBinary("foo/bar/"+tt.file, dst)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Downloader.GetBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			fi, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm()&0111 == 0 {
+				t.Errorf("binary is not executable, mode=%s", fi.Mode())
+			}
+		})
+	}
+}
+
+func TestDownloader_GetFile(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	src := filepath.Join(testdataPath(), "test-with-directory.zip")
+	want, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := tmpDir.Path("linux-amd64/test-with-directory.zip")
+	if err := NewDownloader(newTrueVerifier(), NewFileFetcher(src)).GetFile("foo/bar/test-with-directory.zip", dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("expected the download to be written as is")
+	}
+
+	if err := NewDownloader(newFalseVerifier(), NewFileFetcher(src)).GetFile("foo/bar/test-with-directory.zip", tmpDir.Path("other.zip")); err == nil {
+		t.Error("expected an error for a download that fails verification")
+	}
+}
+
+func TestDownloader_GetArchiveOrBinary(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantBinary bool
+		wantErr    bool
+	}{
+		{name: "zip archive", file: "test-with-directory.zip"},
+		{name: "tar.gz archive", file: "test-without-directory.tar.gz"},
+		{name: "ELF executable", file: "raw-elf", wantBinary: true},
+		{name: "Mach-O executable", file: "raw-macho", wantBinary: true},
+		{name: "PE executable", file: "raw-pe", wantBinary: true},
+		{name: "neither archive nor executable", file: "bash-utf8-file", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			binPath := tmpDir.Path("bin/kubectl-foo")
+			d := NewDownloader(newTrueVerifier(), NewFileFetcher(filepath.Join(testdataPath(), tt.file)))
+			err := d.GetArchiveOrBinary("foo/bar/"+tt.file, tmpDir.Root(), binPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Downloader.GetArchiveOrBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			fi, err := os.Stat(binPath)
+			if !tt.wantBinary {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected archive to be extracted, not written as binary (err=%v)", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm()&0111 == 0 {
+				t.Errorf("binary is not executable, mode=%s", fi.Mode())
+			}
+		})
+	}
+}
+
 func Test_download(t *testing.T) {
 	filePath := filepath.Join(testdataPath(), "test-with-directory.zip")
 	downloadOriginal, err := ioutil.ReadFile(filePath)
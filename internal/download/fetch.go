@@ -17,12 +17,33 @@ package download
 import (
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/version"
 )
 
+// githubTokenEnv is the environment variable with a GitHub token used to
+// authenticate downloads from GitHub, which have higher rate limits.
+const githubTokenEnv = "GITHUB_TOKEN"
+
+// maxRedirects is the number of redirects followed for a request, same as
+// the default of net/http.
+const maxRedirects = 10
+
+// ociScheme is the scheme of uris of plugin archives distributed as OCI
+// artifacts in a container registry.
+const ociScheme = "oci://"
+
+// IsOCIArtifact reports whether the uri references an OCI artifact.
+func IsOCIArtifact(uri string) bool { return strings.HasPrefix(uri, ociScheme) }
+
 // Fetcher is used to get files from a URI.
 type Fetcher interface {
 	// Get gets the file and returns an stream to read the file.
@@ -31,19 +52,117 @@ type Fetcher interface {
 
 var _ Fetcher = HTTPFetcher{}
 
-// HTTPFetcher is used to get a file from a http:// or https:// schema path.
-type HTTPFetcher struct{}
+// HTTPFetcher is used to get a file from a http:// or https:// schema path, or
+// to pull an OCI artifact with a single layer from an oci://registry/repository
+// uri, with the registry credentials stored by "docker login". The zero value
+// is ready to use.
+type HTTPFetcher struct {
+	// UserAgent is sent with every request, "krew/<version>" is used if empty.
+	UserAgent string
+
+	// Header contains extra headers sent with every request. They are not
+	// sent anymore when a request is redirected to another host.
+	Header http.Header
+
+	// MaxRate limits each download to the given number of bytes per second.
+	// Concurrent downloads are limited separately. Zero means unlimited.
+	MaxRate int64
+}
 
 // Get gets the file and returns an stream to read the file.
-func (HTTPFetcher) Get(uri string) (io.ReadCloser, error) {
+func (f HTTPFetcher) Get(uri string) (io.ReadCloser, error) {
+	body, err := f.get(uri)
+	if err != nil || f.MaxRate <= 0 {
+		return body, err
+	}
+	klog.V(3).Infof("Limiting the download of %q to %d bytes per second", uri, f.MaxRate)
+	return newRateLimitedReader(body, f.MaxRate), nil
+}
+
+func (f HTTPFetcher) get(uri string) (io.ReadCloser, error) {
+	if IsOCIArtifact(uri) {
+		return f.getOCI(uri)
+	}
 	klog.V(2).Infof("Fetching %q", uri)
-	resp, err := http.Get(uri)
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %q", uri)
+	}
+	if token := os.Getenv(githubTokenEnv); token != "" && isGitHubHost(req.URL) {
+		klog.V(3).Infof("Using %s to authenticate to %s", githubTokenEnv, req.URL.Host)
+		req.Header.Set("Authorization", "token "+token)
+	}
+	resp, err := f.Do(req)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to download %q", uri)
 	}
+	if err := checkRateLimit(resp); err != nil {
+		resp.Body.Close()
+		return nil, errors.Wrapf(err, "failed to download %q", uri)
+	}
 	return resp.Body, nil
 }
 
+// Do sends the request with the User-Agent and the extra headers of the
+// fetcher.
+func (f HTTPFetcher) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", "krew/"+version.GitTag())
+	for name, values := range f.Header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	client := &http.Client{CheckRedirect: f.checkRedirect}
+	return client.Do(req)
+}
+
+// checkRedirect drops the credentials and the extra headers from requests
+// redirected to another host, so that they are not leaked to it.
+func (f HTTPFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Host == via[0].URL.Host {
+		return nil
+	}
+	klog.V(3).Infof("Redirected to %s, not sending extra headers", req.URL.Host)
+	req.Header.Del("Authorization")
+	for name := range f.Header {
+		if http.CanonicalHeaderKey(name) != "User-Agent" {
+			req.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// isGitHubHost reports whether the url points to GitHub, where the GitHub
+// token can be sent to.
+func isGitHubHost(u *url.URL) bool {
+	host := u.Hostname()
+	return host == "github.com" || strings.HasSuffix(host, ".github.com") ||
+		strings.HasSuffix(host, ".githubusercontent.com")
+}
+
+// checkRateLimit returns an error if the response indicates that the GitHub
+// rate limit is exceeded.
+func checkRateLimit(resp *http.Response) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return nil
+	}
+	msg := "GitHub rate limit exceeded"
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		msg += ", retry after " + time.Unix(reset, 0).Format(time.RFC3339)
+	}
+	if os.Getenv(githubTokenEnv) == "" {
+		msg += " (set " + githubTokenEnv + " to use the higher rate limit of authenticated requests)"
+	}
+	return errors.New(msg)
+}
+
 var _ Fetcher = fileFetcher{}
 
 type fileFetcher struct{ f string }
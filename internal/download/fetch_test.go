@@ -0,0 +1,146 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHTTPFetcher_Get_rateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1572000000")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := HTTPFetcher{}.Get(server.URL + "/foo.tar.gz")
+	if err == nil {
+		t.Fatal("expected error for rate limited response")
+	}
+	for _, want := range []string{"GitHub rate limit exceeded", "retry after", githubTokenEnv} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestHTTPFetcher_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("token should not be sent to non-GitHub hosts")
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	os.Setenv(githubTokenEnv, "secret")
+	defer os.Unsetenv(githubTokenEnv)
+
+	body, err := HTTPFetcher{}.Get(server.URL + "/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "content" {
+		t.Errorf("got unexpected body %q", b)
+	}
+}
+
+func TestHTTPFetcher_Get_headers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "custom-agent/1.0" {
+			t.Errorf("User-Agent = %q, expected custom-agent/1.0", got)
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "secret" {
+			t.Errorf("X-Api-Key = %q, expected secret", got)
+		}
+	}))
+	defer server.Close()
+
+	f := HTTPFetcher{UserAgent: "custom-agent/1.0", Header: http.Header{"X-Api-Key": {"secret"}}}
+	body, err := f.Get(server.URL + "/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+}
+
+func TestHTTPFetcher_Get_defaultUserAgent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); !strings.HasPrefix(got, "krew/") {
+			t.Errorf("User-Agent = %q, expected krew/<version>", got)
+		}
+	}))
+	defer server.Close()
+
+	body, err := HTTPFetcher{}.Get(server.URL + "/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+}
+
+func TestHTTPFetcher_Get_redirectToOtherHost(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Api-Key"); got != "" {
+			t.Errorf("X-Api-Key = %q sent to the redirected host", got)
+		}
+		if got := r.Header.Get("User-Agent"); got != "custom-agent/1.0" {
+			t.Errorf("User-Agent = %q, expected to be kept across redirects", got)
+		}
+	}))
+	defer other.Close()
+	// 127.0.0.1 and localhost are different hosts for the same server
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.RedirectHandler(otherURL+"/foo.tar.gz", http.StatusFound))
+	defer server.Close()
+
+	f := HTTPFetcher{UserAgent: "custom-agent/1.0", Header: http.Header{"X-Api-Key": {"secret"}}}
+	body, err := f.Get(server.URL + "/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+}
+
+func Test_isGitHubHost(t *testing.T) {
+	tests := map[string]bool{
+		"https://github.com/foo/bar/releases/download/v1/a.tar.gz": true,
+		"https://api.github.com/repos/foo/bar":                     true,
+		"https://objects.githubusercontent.com/foo":                true,
+		"https://github.com.example.com/foo":                       false,
+		"https://notgithub.com/foo":                                false,
+	}
+	for uri, want := range tests {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isGitHubHost(u); got != want {
+			t.Errorf("isGitHubHost(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nooci
+// +build !nooci
+
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// dockerConfigEnv is the environment variable with the directory of the
+// docker config file that has the registry credentials, ~/.docker if unset.
+const dockerConfigEnv = "DOCKER_CONFIG"
+
+// media types of the manifests that OCI artifacts can be pulled with
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociIndexMediaType       = "application/vnd.oci.image.index.v1+json"
+	dockerListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var ociRepositoryRegexp = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// ociReference is a parsed oci://registry/repository[:tag|@digest] uri.
+type ociReference struct {
+	registry   string
+	repository string
+	reference  string // a tag or a digest
+}
+
+func parseOCIReference(uri string) (ociReference, error) {
+	s := strings.TrimPrefix(uri, ociScheme)
+	slash := strings.Index(s, "/")
+	if slash <= 0 {
+		return ociReference{}, errors.Errorf("invalid OCI artifact %q, expected oci://registry/repository[:tag|@digest]", uri)
+	}
+	ref := ociReference{registry: s[:slash], repository: s[slash+1:], reference: "latest"}
+	if i := strings.Index(ref.repository, "@"); i >= 0 {
+		ref.repository, ref.reference = ref.repository[:i], ref.repository[i+1:]
+		if !strings.HasPrefix(ref.reference, "sha256:") {
+			return ociReference{}, errors.Errorf("invalid OCI artifact %q, only sha256 digests are supported", uri)
+		}
+	} else if i := strings.LastIndex(ref.repository, ":"); i >= 0 {
+		ref.repository, ref.reference = ref.repository[:i], ref.repository[i+1:]
+	}
+	if !ociRepositoryRegexp.MatchString(ref.repository) || ref.reference == "" {
+		return ociReference{}, errors.Errorf("invalid OCI artifact %q, expected oci://registry/repository[:tag|@digest]", uri)
+	}
+	return ref, nil
+}
+
+// url returns the url of the registry API endpoint of the repository. Like
+// docker, registries on the local host are accessed over plain HTTP.
+func (r ociReference) url(path string) string {
+	host := r.registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	scheme := "https"
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		scheme = "http"
+	}
+	return scheme + "://" + r.registry + "/v2/" + r.repository + "/" + path
+}
+
+// ociDescriptor describes the content of a manifest or blob.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// getOCI pulls the only layer of the OCI artifact at uri, and verifies its
+// digest while it is read.
+func (f HTTPFetcher) getOCI(uri string) (io.ReadCloser, error) {
+	ref, err := parseOCIReference(uri)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("Pulling OCI artifact %s/%s (%s)", ref.registry, ref.repository, ref.reference)
+	c := &ociClient{fetcher: f, ref: ref}
+
+	resp, err := c.get(ref.url("manifests/"+ref.reference), ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pull the manifest of %q", uri)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the manifest of %q", uri)
+	}
+	if strings.HasPrefix(ref.reference, "sha256:") {
+		if sum := sha256.Sum256(b); "sha256:"+hex.EncodeToString(sum[:]) != ref.reference {
+			return nil, errors.Errorf("the manifest of %q does not match its digest", uri)
+		}
+	}
+	var m ociManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the manifest of %q", uri)
+	}
+	if m.MediaType == ociIndexMediaType || m.MediaType == dockerListMediaType {
+		return nil, errors.Errorf("%q is an image index, which is not supported, reference a single artifact instead", uri)
+	}
+	if len(m.Layers) != 1 {
+		return nil, errors.Errorf("OCI artifact %q has %d layers, expected exactly one with the plugin archive", uri, len(m.Layers))
+	}
+	layer := m.Layers[0]
+	if !strings.HasPrefix(layer.Digest, "sha256:") {
+		return nil, errors.Errorf("OCI artifact %q has a layer with unsupported digest %q", uri, layer.Digest)
+	}
+
+	resp, err = c.get(ref.url("blobs/"+layer.Digest), "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pull the layer of %q", uri)
+	}
+	return &digestReader{body: resp.Body, hash: sha256.New(), digest: layer.Digest}, nil
+}
+
+// ociClient sends requests to a registry, authenticating like docker.
+type ociClient struct {
+	fetcher HTTPFetcher
+	ref     ociReference
+	auth    string // Authorization header for the registry, once known
+}
+
+// get requests the url and returns the response if it is successful. Requests
+// that the registry rejects as unauthorized are retried with the credentials
+// it asks for.
+func (c *ociClient) get(u, accept string) (*http.Response, error) {
+	resp, err := c.do(u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.auth == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if c.auth, err = c.authenticate(challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(u, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, errors.Errorf("unexpected status code (http %d) from %s", resp.StatusCode, u)
+	}
+	return resp, nil
+}
+
+func (c *ociClient) do(u, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %q", u)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+	return c.fetcher.Do(req)
+}
+
+// authenticate returns the Authorization header that answers the
+// WWW-Authenticate challenge of the registry.
+func (c *ociClient) authenticate(challenge string) (string, error) {
+	scheme, params := parseAuthChallenge(challenge)
+	user, password, hasCredentials := dockerCredentials(c.ref.registry)
+	switch scheme {
+	case "basic":
+		if !hasCredentials {
+			return "", errors.Errorf("registry %s requires credentials, log in with \"docker login %s\"", c.ref.registry, c.ref.registry)
+		}
+		return "Basic " + basicAuth(user, password), nil
+	case "bearer":
+		return c.bearerToken(params, user, password, hasCredentials)
+	}
+	return "", errors.Errorf("registry %s asks for unsupported authentication %q", c.ref.registry, challenge)
+}
+
+// bearerToken gets a token for pulling from the repository from the token
+// service of the registry.
+func (c *ociClient) bearerToken(params map[string]string, user, password string, hasCredentials bool) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", errors.Errorf("registry %s has an invalid token service %q", c.ref.registry, params["realm"])
+	}
+	q := realm.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.repository + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the token request")
+	}
+	if hasCredentials {
+		req.Header.Set("Authorization", "Basic "+basicAuth(user, password))
+	}
+	klog.V(3).Infof("Requesting a token for %s from %s", scope, realm.Host)
+	resp, err := c.fetcher.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get a token from %s", realm.Host)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", errors.Errorf("registry %s denied access to %s, log in with \"docker login %s\"", c.ref.registry, c.ref.repository, c.ref.registry)
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", errors.Errorf("unexpected status code (http %d) from the token service %s", resp.StatusCode, realm.Host)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrapf(err, "failed to decode the token from %s", realm.Host)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", errors.Errorf("the token service %s returned no token", realm.Host)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseAuthChallenge returns the lower-case scheme and the parameters of a
+// WWW-Authenticate header with a single challenge, such as:
+//
+//	Bearer realm="https://auth.example.com/token",service="registry.example.com"
+func parseAuthChallenge(header string) (string, map[string]string) {
+	header = strings.TrimSpace(header)
+	scheme, rest := header, ""
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		scheme, rest = header[:i], header[i+1:]
+	}
+	params := make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, ", ") {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.IndexByte(rest, ','); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+	}
+	return strings.ToLower(scheme), params
+}
+
+// dockerCredentials returns the credentials for the registry that "docker
+// login" stored in the docker config file. Credentials stored with credential
+// helpers are not supported.
+func dockerCredentials(registry string) (string, string, bool) {
+	dir := os.Getenv(dockerConfigEnv)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			klog.V(3).Infof("Cannot find the docker config file: %v", err)
+			return "", "", false
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		klog.V(3).Infof("Not using registry credentials, failed to read the docker config file: %v", err)
+		return "", "", false
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		klog.Warningf("Not using registry credentials, failed to decode the docker config file: %v", err)
+		return "", "", false
+	}
+	for key, auth := range config.Auths {
+		if registryHost(key) != registry {
+			continue
+		}
+		if auth.Username != "" {
+			return auth.Username, auth.Password, true
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			klog.Warningf("Not using the credentials for %s in the docker config file, they are invalid: %v", registry, err)
+			return "", "", false
+		}
+		if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+			return parts[0], parts[1], true
+		}
+	}
+	if _, ok := config.CredHelpers[registry]; ok || config.CredsStore != "" {
+		klog.V(1).Infof("Credential helpers of the docker config file are not supported, not using credentials for %s", registry)
+	}
+	return "", "", false
+}
+
+// registryHost returns the registry host of a key of the auths in the docker
+// config file, which can be a host or a url.
+func registryHost(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	return strings.SplitN(key, "/", 2)[0]
+}
+
+func basicAuth(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
+
+// digestReader reads a blob and returns an error at its end if its contents
+// don't match the digest.
+type digestReader struct {
+	body   io.ReadCloser
+	hash   hash.Hash
+	digest string
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if got := "sha256:" + hex.EncodeToString(r.hash.Sum(nil)); got != r.digest {
+			return n, errors.Errorf("the pulled layer has digest %s, expected %s", got, r.digest)
+		}
+	}
+	return n, err
+}
+
+func (r *digestReader) Close() error { return r.body.Close() }
@@ -0,0 +1,28 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build nooci
+// +build nooci
+
+package download
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+func (f HTTPFetcher) getOCI(uri string) (io.ReadCloser, error) {
+	return nil, errors.Errorf("cannot download %q, this build of krew does not support OCI artifacts (it was built with the nooci tag)", uri)
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nooci
+// +build !nooci
+
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func Test_parseOCIReference(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    ociReference
+		wantErr bool
+	}{
+		{uri: "oci://ghcr.io/org/plugins/foo:v1.0.0", want: ociReference{registry: "ghcr.io", repository: "org/plugins/foo", reference: "v1.0.0"}},
+		{uri: "oci://localhost:5000/foo", want: ociReference{registry: "localhost:5000", repository: "foo", reference: "latest"}},
+		{uri: "oci://registry.internal/foo@sha256:abcd", want: ociReference{registry: "registry.internal", repository: "foo", reference: "sha256:abcd"}},
+		{uri: "oci://registry.internal/foo@md5:abcd", wantErr: true},
+		{uri: "oci://registry.internal", wantErr: true},
+		{uri: "oci://registry.internal/Foo:v1", wantErr: true},
+		{uri: "oci://registry.internal/foo:", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOCIReference(tt.uri)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseOCIReference(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(ociReference{})); diff != "" {
+			t.Errorf("parseOCIReference(%q) mismatch (-want +got):\n%s", tt.uri, diff)
+		}
+	}
+}
+
+func Test_parseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:foo:pull,push"`)
+	if scheme != "bearer" {
+		t.Errorf("scheme = %q, want bearer", scheme)
+	}
+	want := map[string]string{"realm": "https://auth.example.com/token", "service": "registry.example.com", "scope": "repository:foo:pull,push"}
+	if diff := cmp.Diff(want, params); diff != "" {
+		t.Errorf("parseAuthChallenge() params mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// newTestRegistry serves the blob as the only layer of the artifact
+// plugins/foo:v1.0.0, to clients that got a token with the credentials
+// user:secret. The layer is served with the given contents instead of the
+// blob if they are not empty.
+func newTestRegistry(t *testing.T, blob, served string) *httptest.Server {
+	sum := sha256.Sum256([]byte(blob))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if served == "" {
+		served = blob
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if scope := r.URL.Query().Get("scope"); !strings.HasPrefix(scope, "repository:plugins/") || !strings.HasSuffix(scope, ":pull") {
+				t.Errorf("unexpected scope %q", scope)
+			}
+			fmt.Fprint(w, `{"token": "pull-token"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/plugins/foo/manifests/v1.0.0":
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			fmt.Fprintf(w, `{"schemaVersion": 2, "mediaType": %q, "layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": %q, "size": %d}]}`,
+				ociManifestMediaType, digest, len(blob))
+		case "/v2/plugins/foo/blobs/" + digest:
+			fmt.Fprint(w, served)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestHTTPFetcher_Get_oci(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer os.Unsetenv(dockerConfigEnv)
+	os.Setenv(dockerConfigEnv, tmpDir.Root())
+
+	server := newTestRegistry(t, "archive", "")
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+	// the auth is "user:secret"
+	tmpDir.Write("config.json", []byte(fmt.Sprintf(`{"auths": {"https://%s": {"auth": "dXNlcjpzZWNyZXQ="}}}`, registry)))
+
+	body, err := HTTPFetcher{}.Get("oci://" + registry + "/plugins/foo:v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "archive" {
+		t.Errorf("got unexpected layer %q", b)
+	}
+
+	if _, err := (HTTPFetcher{}).Get("oci://" + registry + "/plugins/bar:v1.0.0"); err == nil {
+		t.Error("expected an error for a missing artifact")
+	}
+}
+
+func TestHTTPFetcher_Get_ociDigestMismatch(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer os.Unsetenv(dockerConfigEnv)
+	os.Setenv(dockerConfigEnv, tmpDir.Root())
+
+	server := newTestRegistry(t, "archive", "tampered")
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+	tmpDir.Write("config.json", []byte(fmt.Sprintf(`{"auths": {"%s": {"username": "user", "password": "secret"}}}`, registry)))
+
+	body, err := HTTPFetcher{}.Get("oci://" + registry + "/plugins/foo:v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if _, err := ioutil.ReadAll(body); err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Errorf("expected a digest mismatch error, got %v", err)
+	}
+}
+
+func TestHTTPFetcher_Get_ociNoCredentials(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	defer os.Unsetenv(dockerConfigEnv)
+	os.Setenv(dockerConfigEnv, tmpDir.Root())
+
+	server := newTestRegistry(t, "archive", "")
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	_, err := HTTPFetcher{}.Get("oci://" + registry + "/plugins/foo:v1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "docker login") {
+		t.Errorf("expected an error suggesting to log in, got %v", err)
+	}
+}
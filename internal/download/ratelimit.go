@@ -0,0 +1,95 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// rateUnits are the units of download rates, longest suffix first so that
+// "KiB" is not matched as "B".
+var rateUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9},
+	{"B", 1},
+}
+
+// ParseRate parses a download rate such as "5MB/s", "512KiB/s" or "1.5M" to
+// bytes per second. The "/s" suffix is optional, a number without unit is in
+// bytes per second, and "0" means unlimited.
+func ParseRate(s string) (int64, error) {
+	v := strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	multiplier := 1.0
+	for _, u := range rateUnits {
+		if strings.HasSuffix(strings.ToUpper(v), strings.ToUpper(u.suffix)) {
+			v, multiplier = strings.TrimSpace(v[:len(v)-len(u.suffix)]), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, errors.Errorf("invalid download rate %q, expected a size per second such as 5MB/s", s)
+	}
+	rate := int64(n * multiplier)
+	if rate == 0 && n > 0 {
+		return 0, errors.Errorf("download rate %q is less than 1 byte per second", s)
+	}
+	return rate, nil
+}
+
+// these are replaced in tests
+var (
+	now   = time.Now
+	sleep = time.Sleep
+)
+
+// rateLimitedReader reads from a stream no faster than the given number of
+// bytes per second on average.
+type rateLimitedReader struct {
+	io.ReadCloser
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func newRateLimitedReader(r io.ReadCloser, rate int64) io.ReadCloser {
+	return &rateLimitedReader{ReadCloser: r, rate: rate, start: now()}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// small reads keep the rate smooth instead of sleeping after large bursts
+	if chunk := r.rate / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	} else if chunk == 0 && len(p) > 1 {
+		p = p[:1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	due := r.start.Add(time.Duration(float64(r.read) / float64(r.rate) * float64(time.Second)))
+	if wait := due.Sub(now()); wait > 0 {
+		sleep(wait)
+	}
+	return n, err
+}
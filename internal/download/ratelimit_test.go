@@ -0,0 +1,87 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1000", want: 1000},
+		{in: "5MB/s", want: 5000000},
+		{in: "5mb/s", want: 5000000},
+		{in: "512KiB/s", want: 512 << 10},
+		{in: "1.5M", want: 1500000},
+		{in: "2 GiB/s", want: 2 << 30},
+		{in: "100B/s", want: 100},
+		{in: "fast", wantErr: true},
+		{in: "-1MB/s", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "0.1B/s", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_Get_maxRate(t *testing.T) {
+	defer func(origNow func() time.Time, origSleep func(time.Duration)) { now, sleep = origNow, origSleep }(now, sleep)
+	clock := time.Unix(0, 0)
+	var slept time.Duration
+	now = func() time.Time { return clock }
+	sleep = func(d time.Duration) { slept += d; clock = clock.Add(d) }
+
+	content := bytes.Repeat([]byte("a"), 3000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	body, err := HTTPFetcher{MaxRate: 1000}.Get(server.URL + "/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Errorf("got %d bytes, want %d", len(b), len(content))
+	}
+	if slept != 3*time.Second {
+		t.Errorf("reading 3000 bytes at 1000 bytes per second waited %s, want 3s", slept)
+	}
+}
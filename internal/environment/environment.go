@@ -17,6 +17,8 @@ package environment
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 
 	"github.com/pkg/errors"
 	"k8s.io/client-go/util/homedir"
@@ -25,20 +27,33 @@ import (
 	"sigs.k8s.io/krew/pkg/constants"
 )
 
+// xdgEnv opts in to the XDG base directory layout on Linux if set to a true
+// value and KREW_ROOT is not set.
+const xdgEnv = "KREW_XDG"
+
 // Paths contains all important environment paths
 type Paths struct {
-	base string
-	tmp  string
+	base   string
+	cache  string
+	config string
+	tmp    string
 }
 
 // MustGetKrewPaths returns the inferred paths for krew. By default, it assumes
 // $HOME/.krew as the base path, but can be overridden via KREW_ROOT environment
-// variable.
+// variable. Without KREW_ROOT, the XDG base directories are used on Linux if
+// KREW_XDG is set.
 func MustGetKrewPaths() Paths {
 	base := filepath.Join(homedir.HomeDir(), ".krew")
 	if fromEnv := os.Getenv("KREW_ROOT"); fromEnv != "" {
 		base = fromEnv
 		klog.V(4).Infof("using environment override KREW_ROOT=%s", fromEnv)
+	} else if useXDG() {
+		klog.V(4).Infof("using the XDG base directories (%s is set)", xdgEnv)
+		return NewXDGPaths(
+			filepath.Join(xdgDir("XDG_DATA_HOME", ".local", "share"), "krew"),
+			filepath.Join(xdgDir("XDG_CACHE_HOME", ".cache"), "krew"),
+			filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "krew"))
 	}
 	base, err := filepath.Abs(base)
 	if err != nil {
@@ -48,21 +63,65 @@ func MustGetKrewPaths() Paths {
 }
 
 func NewPaths(base string) Paths {
-	return Paths{base: base, tmp: os.TempDir()}
+	return Paths{base: base, cache: base, config: base, tmp: os.TempDir()}
+}
+
+// NewXDGPaths returns the paths with the installed plugins in data, the data
+// krew can recreate (such as the index) in cache and the config file in
+// config.
+func NewXDGPaths(data, cache, config string) Paths {
+	return Paths{base: data, cache: cache, config: config, tmp: os.TempDir()}
+}
+
+// useXDG reports whether the XDG base directory layout is enabled.
+func useXDG() bool {
+	v, _ := strconv.ParseBool(os.Getenv(xdgEnv))
+	return v && runtime.GOOS == "linux"
+}
+
+// xdgDir returns the XDG base directory set in env, or the given default
+// under the home directory. Relative paths in env are ignored, as required by
+// the XDG base directory specification.
+func xdgDir(env string, defaultElem ...string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(append([]string{homedir.HomeDir()}, defaultElem...)...)
 }
 
 // BasePath returns krew base directory.
 func (p Paths) BasePath() string { return p.base }
 
+// CachePath returns the base directory of the data that krew can recreate,
+// such as the index. It is the BasePath, except with the XDG layout.
+//
+// e.g. {BasePath}
+func (p Paths) CachePath() string { return p.cache }
+
 // IndexPath returns the base directory where plugin index repository is cloned.
 //
-// e.g. {BasePath}/index/
-func (p Paths) IndexPath() string { return filepath.Join(p.base, "index") }
+// e.g. {CachePath}/index/
+func (p Paths) IndexPath() string { return filepath.Join(p.cache, "index") }
 
 // IndexPluginsPath returns the plugins directory of the index repository.
 //
-// e.g. {BasePath}/index/plugins/
-func (p Paths) IndexPluginsPath() string { return filepath.Join(p.base, "index", "plugins") }
+// e.g. {CachePath}/index/plugins/
+func (p Paths) IndexPluginsPath() string { return filepath.Join(p.IndexPath(), "plugins") }
+
+// IndexChannelPath returns the directory of the index with the plugin
+// manifests of the channel, which are newer than the ones in the plugins
+// directory.
+//
+// e.g. {CachePath}/index/channels/{channel}
+func (p Paths) IndexChannelPath(channel string) string {
+	return filepath.Join(p.IndexPath(), "channels", channel)
+}
+
+// DownloadCachePath returns the directory of the downloads cached by their
+// sha256 checksum.
+//
+// e.g. {CachePath}/downloads
+func (p Paths) DownloadCachePath() string { return filepath.Join(p.cache, "downloads") }
 
 // InstallReceiptsPath returns the base directory where plugin receipts are stored.
 //
@@ -80,6 +139,34 @@ func (p Paths) BinPath() string { return filepath.Join(p.base, "bin") }
 // e.g. {BasePath}/store
 func (p Paths) InstallPath() string { return filepath.Join(p.base, "store") }
 
+// ConfigPath returns the path of the default krew config file. It is in the
+// BasePath, except with the XDG layout.
+//
+// e.g. {BasePath}/config.yaml
+func (p Paths) ConfigPath() string {
+	return filepath.Join(p.config, "config"+constants.ManifestExtension)
+}
+
+// LocksPath returns the base directory of the lock files used to coordinate
+// concurrent krew processes.
+//
+// e.g. {BasePath}/locks
+func (p Paths) LocksPath() string { return filepath.Join(p.base, "locks") }
+
+// IndexLockPath returns the path of the lock file for the local copy of the
+// plugin index.
+//
+// e.g. {LocksPath}/index.lock
+func (p Paths) IndexLockPath() string { return filepath.Join(p.LocksPath(), "index.lock") }
+
+// PluginLockPath returns the path of the lock file for the installation of
+// the plugin.
+//
+// e.g. {LocksPath}/plugins/{plugin}.lock
+func (p Paths) PluginLockPath(plugin string) string {
+	return filepath.Join(p.LocksPath(), "plugins", plugin+".lock")
+}
+
 // PluginInstallPath returns the path to install the plugin.
 //
 // e.g. {InstallPath}/{version}/{..files..}
@@ -17,12 +17,14 @@ package environment
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"k8s.io/client-go/util/homedir"
 
 	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
 )
 
 func TestMustGetKrewPaths_resolvesToHomeDir(t *testing.T) {
@@ -45,6 +47,56 @@ func TestMustGetKrewPaths_envOverride(t *testing.T) {
 	}
 }
 
+func TestMustGetKrewPaths_xdg(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the XDG layout is only used on Linux")
+	}
+	os.Setenv(xdgEnv, "1")
+	defer os.Unsetenv(xdgEnv)
+	os.Setenv("XDG_CACHE_HOME", filepath.FromSlash("/xdg/cache"))
+	defer os.Unsetenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CONFIG_HOME", "relative/config") // ignored
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	p := MustGetKrewPaths()
+	home := homedir.HomeDir()
+	if got, expected := p.BasePath(), filepath.Join(home, ".local", "share", "krew"); got != expected {
+		t.Errorf("BasePath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexPath(), filepath.FromSlash("/xdg/cache/krew/index"); got != expected {
+		t.Errorf("IndexPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.ConfigPath(), filepath.Join(home, ".config", "krew", "config"+constants.ManifestExtension); got != expected {
+		t.Errorf("ConfigPath()=%s; expected=%s", got, expected)
+	}
+
+	// KREW_ROOT takes precedence
+	os.Setenv("KREW_ROOT", filepath.FromSlash("/custom/krew/path"))
+	defer os.Unsetenv("KREW_ROOT")
+	if got, expected := MustGetKrewPaths().IndexPath(), filepath.FromSlash("/custom/krew/path/index"); got != expected {
+		t.Errorf("IndexPath()=%s with KREW_ROOT; expected=%s", got, expected)
+	}
+}
+
+func TestNewXDGPaths(t *testing.T) {
+	p := NewXDGPaths(filepath.FromSlash("/data"), filepath.FromSlash("/cache"), filepath.FromSlash("/config"))
+	if got, expected := p.BinPath(), filepath.FromSlash("/data/bin"); got != expected {
+		t.Fatalf("BinPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.PluginInstallReceiptPath("my-plugin"), filepath.FromSlash("/data/receipts/my-plugin"+constants.ManifestExtension); got != expected {
+		t.Fatalf("PluginInstallReceiptPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexPluginsPath(), filepath.FromSlash("/cache/index/plugins"); got != expected {
+		t.Fatalf("IndexPluginsPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.ConfigPath(), filepath.FromSlash("/config/config"+constants.ManifestExtension); got != expected {
+		t.Fatalf("ConfigPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexLockPath(), filepath.FromSlash("/data/locks/index.lock"); got != expected {
+		t.Fatalf("IndexLockPath()=%s; expected=%s", got, expected)
+	}
+}
+
 func TestPaths(t *testing.T) {
 	base := filepath.FromSlash("/foo")
 	p := NewPaths(base)
@@ -54,6 +106,9 @@ func TestPaths(t *testing.T) {
 	if got, expected := p.BinPath(), filepath.FromSlash("/foo/bin"); got != expected {
 		t.Fatalf("BinPath()=%s; expected=%s", got, expected)
 	}
+	if got := p.CachePath(); got != base {
+		t.Fatalf("CachePath()=%s; expected=%s", got, base)
+	}
 	if got, expected := p.IndexPath(), filepath.FromSlash("/foo/index"); got != expected {
 		t.Fatalf("IndexPath()=%s; expected=%s", got, expected)
 	}
@@ -69,6 +124,15 @@ func TestPaths(t *testing.T) {
 	if got, expected := p.PluginVersionInstallPath("my-plugin", "v1"), filepath.FromSlash("/foo/store/my-plugin/v1"); got != expected {
 		t.Fatalf("PluginVersionInstallPath()=%s; expected=%s", got, expected)
 	}
+	if got, expected := p.ConfigPath(), filepath.FromSlash("/foo/config"+constants.ManifestExtension); got != expected {
+		t.Fatalf("ConfigPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexLockPath(), filepath.FromSlash("/foo/locks/index.lock"); got != expected {
+		t.Fatalf("IndexLockPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.PluginLockPath("my-plugin"), filepath.FromSlash("/foo/locks/plugins/my-plugin.lock"); got != expected {
+		t.Fatalf("PluginLockPath()=%s; expected=%s", got, expected)
+	}
 	if got := p.InstallReceiptsPath(); !strings.HasSuffix(got, filepath.FromSlash("receipts")) {
 		t.Fatalf("InstallReceiptsPath()=%s; expected suffix 'receipts'", got)
 	}
@@ -0,0 +1,75 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filelock provides advisory locks on files to coordinate krew
+// processes that run at the same time.
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// Lock is a lock held on a file until it is released with Unlock. The zero
+// Lock holds no file, and its Unlock does nothing.
+type Lock struct {
+	f *os.File
+}
+
+// Shared acquires a shared lock on the file at path, which can be held by
+// several processes at the same time, but not while an exclusive lock is held.
+// It blocks until the lock is acquired. The file and its parent directory are
+// created if they do not exist.
+func Shared(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+// Exclusive acquires an exclusive lock on the file at path, which can only be
+// held by one process at a time. It blocks until the lock is acquired. The
+// file and its parent directory are created if they do not exist.
+func Exclusive(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+func acquire(path string, exclusive bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create the directory of lock file %q", path)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open lock file %q", path)
+	}
+	klog.V(4).Infof("Acquiring lock %q (exclusive=%v)", path, exclusive)
+	if err := lock(f, exclusive); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to lock %q", path)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	klog.V(4).Infof("Releasing lock %q", l.f.Name())
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return errors.Wrapf(err, "failed to unlock %q", l.f.Name())
+}
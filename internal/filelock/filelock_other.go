@@ -0,0 +1,33 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package filelock
+
+import (
+	"os"
+
+	"k8s.io/klog"
+)
+
+// lock does not lock the file on systems without flock(2), concurrent krew
+// processes are not coordinated there.
+func lock(f *os.File, exclusive bool) error {
+	klog.V(4).Infof("File locks are not supported on this system, not locking %q", f.Name())
+	return nil
+}
+
+func unlock(f *os.File) error { return nil }
@@ -0,0 +1,100 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filelock
+
+import (
+	"testing"
+	"time"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+// lockAsync acquires the lock in the background and sends it to the returned
+// channel once acquired.
+func lockAsync(t *testing.T, acquire func(string) (*Lock, error), path string) <-chan *Lock {
+	done := make(chan *Lock, 1)
+	go func() {
+		l, err := acquire(path)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- l
+	}()
+	return done
+}
+
+// expectBlocked fails the test if the lock is acquired within a short time.
+func expectBlocked(t *testing.T, done <-chan *Lock, msg string) {
+	t.Helper()
+	select {
+	case <-done:
+		t.Fatal(msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// expectAcquired waits for the lock and releases it.
+func expectAcquired(t *testing.T, done <-chan *Lock, msg string) {
+	t.Helper()
+	select {
+	case l := <-done:
+		if err := l.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(msg)
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	path := tmpDir.Path("locks/test.lock")
+
+	l, err := Exclusive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := lockAsync(t, Exclusive, path)
+	expectBlocked(t, done, "acquired the exclusive lock twice")
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	expectAcquired(t, done, "exclusive lock was not acquired after it was released")
+}
+
+func TestShared(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	path := tmpDir.Path("test.lock")
+
+	l, err := Shared(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAcquired(t, lockAsync(t, Shared, path), "shared lock was not acquired while another shared lock is held")
+	done := lockAsync(t, Exclusive, path)
+	expectBlocked(t, done, "acquired the exclusive lock while a shared lock is held")
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	expectAcquired(t, done, "exclusive lock was not acquired after the shared lock was released")
+}
+
+func TestLock_zeroUnlock(t *testing.T) {
+	if err := (&Lock{}).Unlock(); err != nil {
+		t.Errorf("Unlock() of the zero Lock = %v, expected no error", err)
+	}
+}
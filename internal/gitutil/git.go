@@ -17,23 +17,66 @@ package gitutil
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
 	osexec "os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 )
 
+// clone clones the repository at uri into dir.
+var clone = func(uri, dir string) error { return exec("", "clone", "-v", uri, dir) }
+
 // EnsureCloned will clone into the destination path, otherwise will return no error.
+// The repository is cloned into a temporary directory next to the destination
+// path and only moved into place once the clone succeeded, so that a failed
+// clone leaves nothing behind.
 func EnsureCloned(uri, destinationPath string) error {
 	if ok, err := IsGitCloned(destinationPath); err != nil {
 		return err
-	} else if !ok {
-		return exec("", "clone", "-v", uri, destinationPath)
+	} else if ok {
+		return nil
 	}
-	return nil
+	if err := removeIfEmpty(destinationPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the parent directory of %q", destinationPath)
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(destinationPath), "."+filepath.Base(destinationPath)+"-clone-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create a temporary directory to clone into")
+	}
+	defer os.RemoveAll(tmp)
+	if err := clone(uri, tmp); err != nil {
+		return err
+	}
+	// TempDir is only accessible by its owner, unlike a directory git creates
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return errors.Wrapf(err, "failed to set the permissions of %q", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, destinationPath), "failed to move the clone to %q", destinationPath)
+}
+
+// removeIfEmpty removes the directory if it exists and is empty, so that a
+// clone can be moved in its place.
+func removeIfEmpty(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to read %q", dir)
+	}
+	if len(entries) > 0 {
+		return errors.Errorf("cannot clone into %q, it is not empty and not a git repository", dir)
+	}
+	return errors.Wrapf(os.Remove(dir), "failed to remove %q", dir)
 }
 
 // IsGitCloned will test if the path is a git dir.
@@ -62,11 +105,256 @@ func updateAndCleanUntracked(destinationPath string) error {
 }
 
 // EnsureUpdated will ensure the destination path exists and is up to date.
+// If the existing clone fetches from another uri, it is switched to uri.
+// Submodules and Git LFS files of the repository are also updated.
 func EnsureUpdated(uri, destinationPath string) error {
 	if err := EnsureCloned(uri, destinationPath); err != nil {
 		return err
 	}
-	return updateAndCleanUntracked(destinationPath)
+	if err := ensureRemoteURL(destinationPath, uri); err != nil {
+		return err
+	}
+	if err := updateAndCleanUntracked(destinationPath); err != nil {
+		return err
+	}
+	if err := updateSubmodules(destinationPath); err != nil {
+		return err
+	}
+	return pullLFS(destinationPath)
+}
+
+// updateSubmodules checks out the submodules of the repository, if it has
+// any, at the commits recorded in it.
+func updateSubmodules(repoPath string) error {
+	if _, err := os.Stat(filepath.Join(repoPath, ".gitmodules")); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to check for submodules")
+	}
+	klog.V(2).Infof("Updating the submodules of %q", repoPath)
+	if err := exec(repoPath, "submodule", "sync", "--recursive"); err != nil {
+		return errors.Wrapf(err, "sync submodules of index at %q failed", repoPath)
+	}
+	if err := exec(repoPath, "submodule", "update", "--init", "--recursive", "--force"); err != nil {
+		return errors.Wrapf(err, "update submodules of index at %q failed", repoPath)
+	}
+	err := exec(repoPath, "submodule", "foreach", "--recursive", "git", "clean", "-xfd")
+	return errors.Wrapf(err, "clean submodules of index at %q failed", repoPath)
+}
+
+// pullLFS fetches and checks out the Git LFS files of the repository, if
+// its .gitattributes stores any files with Git LFS.
+func pullLFS(repoPath string) error {
+	if ok, err := usesLFS(repoPath); err != nil || !ok {
+		return err
+	}
+	klog.V(2).Infof("Pulling the Git LFS files of %q", repoPath)
+	if err := exec(repoPath, "lfs", "version"); err != nil {
+		return errors.Errorf("index at %q stores files with Git LFS, but git-lfs is not installed", repoPath)
+	}
+	err := exec(repoPath, "lfs", "pull")
+	return errors.Wrapf(err, "pull Git LFS files of index at %q failed", repoPath)
+}
+
+// usesLFS reports whether the .gitattributes file at the root of the
+// repository assigns the Git LFS filter to any files.
+func usesLFS(repoPath string) (bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(repoPath, ".gitattributes"))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "failed to check for Git LFS files")
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, attr := range strings.Fields(line) {
+			if attr == "filter=lfs" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ensureRemoteURL sets the url of the origin remote of the repository to uri.
+func ensureRemoteURL(repoPath, uri string) error {
+	cur, err := capture(repoPath, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the remote of %q", repoPath)
+	}
+	if strings.TrimSpace(cur) == uri {
+		return nil
+	}
+	klog.V(1).Infof("Switching the remote of %q from %q to %q", repoPath, strings.TrimSpace(cur), uri)
+	return errors.Wrapf(exec(repoPath, "remote", "set-url", "origin", uri), "failed to set the remote of %q", repoPath)
+}
+
+// scpLikeURLRegexp matches the scp-like syntax of git ssh urls, for example
+// "git@example.com:org/repo.git".
+var scpLikeURLRegexp = regexp.MustCompile(`^([A-Za-z0-9._-]+@)?[A-Za-z0-9.-]+:.+$`)
+
+// ValidateURL returns an error if uri is not a repository url that git can
+// clone from: a http(s)://, ssh://, git:// or file:// url, the scp-like
+// [user@]host:path syntax or an absolute path.
+func ValidateURL(uri string) error {
+	if filepath.IsAbs(uri) {
+		return nil
+	}
+	if !strings.Contains(uri, "://") {
+		if scpLikeURLRegexp.MatchString(uri) {
+			return nil
+		}
+		return errors.Errorf("invalid git url %q", uri)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return errors.Wrapf(err, "invalid git url %q", uri)
+	}
+	switch u.Scheme {
+	case "http", "https", "ssh", "git":
+		if u.Host == "" {
+			return errors.Errorf("git url %q has no host", uri)
+		}
+	case "file":
+		if u.Path == "" {
+			return errors.Errorf("git url %q has no path", uri)
+		}
+	default:
+		return errors.Errorf("git url %q must use the http, https, ssh, git or file scheme", uri)
+	}
+	return nil
+}
+
+// FileRevisions returns the commits that changed the file at the given path
+// (relative to the repository root), ordered from newest to oldest.
+func FileRevisions(repoPath, file string) ([]string, error) {
+	out, err := capture(repoPath, "log", "--format=%H", "--", filepath.ToSlash(file))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list revisions of %q", file)
+	}
+	return strings.Fields(out), nil
+}
+
+// CommitAt returns the commit that HEAD of the repository was at, as of the
+// given time. It returns an empty string if the history does not go back that
+// far.
+func CommitAt(repoPath string, t time.Time) (string, error) {
+	out, err := capture(repoPath, "rev-list", "-1", "--first-parent", "--before="+t.Format(time.RFC3339), "HEAD")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the commit as of %s", t.Format(time.RFC3339))
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ShowFile returns the contents of the file at the given path (relative to the
+// repository root) as of the specified commit.
+func ShowFile(repoPath, commit, file string) ([]byte, error) {
+	out, err := capture(repoPath, "show", commit+":"+filepath.ToSlash(file))
+	return []byte(out), errors.Wrapf(err, "failed to read %q at commit %s", file, commit)
+}
+
+// Head returns the commit checked out in the repository.
+func Head(repoPath string) (string, error) {
+	return ResolveRef(repoPath, "HEAD")
+}
+
+// ResolveRef returns the commit the ref points to.
+func ResolveRef(repoPath, ref string) (string, error) {
+	out, err := capture(repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %q", ref)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// UpdateRef points the ref to the commit, creating it if it doesn't exist.
+func UpdateRef(repoPath, ref, commit string) error {
+	return errors.Wrapf(exec(repoPath, "update-ref", ref, commit), "failed to update %q", ref)
+}
+
+// FileChange is a file that differs between two commits.
+type FileChange struct {
+	// Status is "A" for added, "D" for deleted and "M" for modified files.
+	Status string
+	// Path is relative to the repository root, with forward slashes.
+	Path string
+}
+
+// ChangedFiles returns the files under dir (relative to the repository root)
+// that differ between the commits from and to.
+func ChangedFiles(repoPath, from, to, dir string) ([]FileChange, error) {
+	out, err := capture(repoPath, "diff", "--name-status", "--no-renames", from, to, "--", filepath.ToSlash(dir))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to diff %s..%s", from, to)
+	}
+	var changes []FileChange
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		status := parts[0][:1]
+		if status != "A" && status != "D" {
+			status = "M"
+		}
+		changes = append(changes, FileChange{Status: status, Path: parts[1]})
+	}
+	return changes, nil
+}
+
+// settlePollInterval is the interval to check whether a git operation in the
+// repository has finished.
+var settlePollInterval = 200 * time.Millisecond
+
+// WaitUntilSettled waits until no git operation is in progress in the
+// repository, i.e. its lock files are released and HEAD doesn't change
+// anymore. It returns immediately if no operation is in progress, and fails if
+// the repository doesn't settle within the timeout.
+func WaitUntilSettled(repoPath string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	waited := false
+	for isLocked(repoPath) {
+		if time.Now().After(deadline) {
+			return errors.Errorf("timed out waiting for git operation in %q to finish", repoPath)
+		}
+		klog.V(2).Infof("Waiting for git operation in %q to finish", repoPath)
+		waited = true
+		time.Sleep(settlePollInterval)
+	}
+	if !waited {
+		return nil
+	}
+
+	head, err := capture(repoPath, "rev-parse", "HEAD")
+	if err != nil {
+		return errors.Wrap(err, "failed to read HEAD")
+	}
+	for {
+		time.Sleep(settlePollInterval)
+		cur, err := capture(repoPath, "rev-parse", "HEAD")
+		if err != nil {
+			return errors.Wrap(err, "failed to read HEAD")
+		}
+		if cur == head && !isLocked(repoPath) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timed out waiting for HEAD of %q to settle", repoPath)
+		}
+		head = cur
+	}
+}
+
+// isLocked reports whether a git operation holds a lock in the repository.
+func isLocked(repoPath string) bool {
+	for _, f := range []string{"index.lock", "HEAD.lock", "shallow.lock"} {
+		if _, err := os.Stat(filepath.Join(repoPath, ".git", f)); err == nil {
+			return true
+		}
+	}
+	return false
 }
 
 func exec(pwd string, args ...string) error {
@@ -84,3 +372,17 @@ func exec(pwd string, args ...string) error {
 	}
 	return nil
 }
+
+// capture runs git with the specified arguments and returns its standard
+// output.
+func capture(pwd string, args ...string) (string, error) {
+	klog.V(4).Infof("Going to run git %s", strings.Join(args, " "))
+	cmd := osexec.Command("git", args...)
+	cmd.Dir = pwd
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "command execution failure, output=%q", stderr.String())
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,312 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func initRepo(t *testing.T, tmpDir *testutil.TempDir) {
+	t.Helper()
+	tmpDir.Write("file", []byte("content"))
+	for _, args := range [][]string{
+		{"init"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "initial"},
+	} {
+		if err := exec(tmpDir.Root(), args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWaitUntilSettled(t *testing.T) {
+	defer func(orig time.Duration) { settlePollInterval = orig }(settlePollInterval)
+	settlePollInterval = 10 * time.Millisecond
+
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, tmpDir)
+
+	if err := WaitUntilSettled(tmpDir.Root(), time.Second); err != nil {
+		t.Fatalf("expected no wait without a git operation in progress: %v", err)
+	}
+
+	lock := tmpDir.Path(".git/index.lock")
+	tmpDir.Write(".git/index.lock", nil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.Remove(lock)
+	}()
+	if err := WaitUntilSettled(tmpDir.Root(), 5*time.Second); err != nil {
+		t.Fatalf("expected to wait until the lock is released: %v", err)
+	}
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Fatalf("returned before the lock was released")
+	}
+
+	tmpDir.Write(".git/index.lock", nil)
+	if err := WaitUntilSettled(tmpDir.Root(), 50*time.Millisecond); err == nil {
+		t.Fatal("expected timeout while the lock is held")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	valid := []string{
+		"https://github.com/kubernetes-sigs/krew-index.git",
+		"http://git.example.com/krew-index",
+		"ssh://git@git.example.com:2222/krew-index.git",
+		"git://git.example.com/krew-index.git",
+		"file:///srv/git/krew-index",
+		"git@github.com:kubernetes-sigs/krew-index.git",
+		"mirror.example.com:krew-index",
+		"/srv/git/krew-index",
+	}
+	for _, uri := range valid {
+		if err := ValidateURL(uri); err != nil {
+			t.Errorf("ValidateURL(%q) returned error: %v", uri, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"krew-index",
+		"ftp://example.com/krew-index",
+		"https:///krew-index",
+		"file://",
+		"://example.com",
+	}
+	for _, uri := range invalid {
+		if err := ValidateURL(uri); err == nil {
+			t.Errorf("ValidateURL(%q) expected error", uri)
+		}
+	}
+}
+
+func TestEnsureUpdated_switchesRemote(t *testing.T) {
+	upstream, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, upstream)
+	mirror, cleanup2 := testutil.NewTempDir(t)
+	defer cleanup2()
+	initRepo(t, mirror)
+	clone, cleanup3 := testutil.NewTempDir(t)
+	defer cleanup3()
+
+	dest := clone.Path("index")
+	if err := EnsureUpdated(upstream.Root(), dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureUpdated(mirror.Root(), dest); err != nil {
+		t.Fatal(err)
+	}
+	got, err := capture(dest, "config", "--get", "remote.origin.url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(got) != mirror.Root() {
+		t.Errorf("remote url = %q, want %q", strings.TrimSpace(got), mirror.Root())
+	}
+}
+
+func TestEnsureUpdated_removesDeletedManifests(t *testing.T) {
+	upstream, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	foo, bar := filepath.Join("plugins", "foo"+constants.ManifestExtension), filepath.Join("plugins", "bar"+constants.ManifestExtension)
+	upstream.Write(foo, []byte("kind: Plugin"))
+	upstream.Write(bar, []byte("kind: Plugin"))
+	initRepo(t, upstream)
+	clone, cleanup2 := testutil.NewTempDir(t)
+	defer cleanup2()
+
+	dest := clone.Path("index")
+	if err := EnsureUpdated(upstream.Root(), dest); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"rm", "-q", bar},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "remove bar"},
+	} {
+		if err := exec(upstream.Root(), args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a manifest that was never in the index
+	if err := ioutil.WriteFile(filepath.Join(dest, "plugins", "baz"+constants.ManifestExtension), []byte("kind: Plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureUpdated(upstream.Root(), dest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, foo)); err != nil {
+		t.Errorf("expected manifest %s to be kept: %v", foo, err)
+	}
+	for _, name := range []string{bar, filepath.Join("plugins", "baz"+constants.ManifestExtension)} {
+		if _, err := os.Stat(filepath.Join(dest, name)); !os.IsNotExist(err) {
+			t.Errorf("expected manifest %s to be removed, got err=%v", name, err)
+		}
+	}
+}
+
+func TestEnsureCloned_failureLeavesNoTrace(t *testing.T) {
+	upstream, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, upstream)
+	parent, cleanup2 := testutil.NewTempDir(t)
+	defer cleanup2()
+
+	defer func(orig func(string, string) error) { clone = orig }(clone)
+	clone = func(uri, dir string) error {
+		// fail after the repository was partially written
+		if err := exec("", "clone", uri, dir); err != nil {
+			t.Fatal(err)
+		}
+		return errors.New("connection reset")
+	}
+
+	dest := parent.Path("index")
+	if err := EnsureCloned(upstream.Root(), dest); err == nil {
+		t.Fatal("expected an error")
+	}
+	entries, err := ioutil.ReadDir(parent.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the failed clone to leave nothing behind, found %s", entries[0].Name())
+	}
+}
+
+func TestEnsureCloned_mode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on windows")
+	}
+	upstream, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, upstream)
+	tmpDir, cleanup2 := testutil.NewTempDir(t)
+	defer cleanup2()
+
+	dest := tmpDir.Path("index")
+	if err := EnsureCloned(upstream.Root(), dest); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0755 {
+		t.Errorf("clone has mode %o, expected it to be readable by others (755)", mode)
+	}
+}
+
+func TestEnsureCloned_nonEmptyDir(t *testing.T) {
+	upstream, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, upstream)
+	tmpDir, cleanup2 := testutil.NewTempDir(t)
+	defer cleanup2()
+
+	if err := os.MkdirAll(tmpDir.Path("empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureCloned(upstream.Root(), tmpDir.Path("empty")); err != nil {
+		t.Fatalf("expected an empty directory to be replaced: %v", err)
+	}
+	tmpDir.Write("other/file", []byte("content"))
+	if err := EnsureCloned(upstream.Root(), tmpDir.Path("other")); err == nil {
+		t.Error("expected an error for a non-empty directory")
+	}
+}
+
+func TestEnsureUpdated_submodules(t *testing.T) {
+	// allow the local submodule, which git refuses by default
+	for k, v := range map[string]string{"GIT_CONFIG_COUNT": "1", "GIT_CONFIG_KEY_0": "protocol.file.allow", "GIT_CONFIG_VALUE_0": "always"} {
+		defer os.Unsetenv(k)
+		os.Setenv(k, v)
+	}
+	sub, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, sub)
+	upstream, cleanup2 := testutil.NewTempDir(t)
+	defer cleanup2()
+	initRepo(t, upstream)
+	for _, args := range [][]string{
+		{"submodule", "add", sub.Root(), "plugins"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "add submodule"},
+	} {
+		if err := exec(upstream.Root(), args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clone, cleanup3 := testutil.NewTempDir(t)
+	defer cleanup3()
+
+	dest := clone.Path("index")
+	if err := EnsureUpdated(upstream.Root(), dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "plugins", "file")); err != nil {
+		t.Errorf("expected the submodule to be checked out: %v", err)
+	}
+}
+
+func Test_usesLFS(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	if ok, err := usesLFS(tmpDir.Root()); err != nil || ok {
+		t.Errorf("usesLFS() without .gitattributes = %v, %v", ok, err)
+	}
+	tmpDir.Write(".gitattributes", []byte("# *.bin filter=lfs\n*.sh text eol=lf\n"))
+	if ok, err := usesLFS(tmpDir.Root()); err != nil || ok {
+		t.Errorf("usesLFS() without LFS attributes = %v, %v", ok, err)
+	}
+	tmpDir.Write(".gitattributes", []byte("*.sh text eol=lf\nassets/** filter=lfs diff=lfs merge=lfs -text\n"))
+	if ok, err := usesLFS(tmpDir.Root()); err != nil || !ok {
+		t.Errorf("usesLFS() with LFS attributes = %v, %v", ok, err)
+	}
+}
+
+func TestUpdateRef(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	initRepo(t, tmpDir)
+
+	if _, err := ResolveRef(tmpDir.Root(), "refs/test/ref"); err == nil {
+		t.Fatal("expected error resolving a missing ref")
+	}
+	head, err := Head(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateRef(tmpDir.Root(), "refs/test/ref", head); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ResolveRef(tmpDir.Root(), "refs/test/ref"); err != nil || got != head {
+		t.Errorf("ResolveRef() = %q, %v, want %q", got, err, head)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/gitutil"
+	"sigs.k8s.io/krew/internal/index/validation"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// ErrVersionNotFound indicates that a plugin manifest with the requested
+// version does not exist in the index history.
+var ErrVersionNotFound = errors.New("version not found in the index history")
+
+// ErrNotFoundAtTime indicates that a plugin manifest did not exist in the
+// index at the requested time.
+var ErrNotFoundAtTime = errors.New("plugin not found in the index at the given time")
+
+// LoadPluginAtVersion searches the git history of the index repository at
+// indexDir for the most recent manifest of the plugin that declares the given
+// version. It returns ErrVersionNotFound if no such manifest exists.
+func LoadPluginAtVersion(indexDir, pluginName, version string) (index.Plugin, error) {
+	pluginName = currentPluginName(indexDir, pluginName)
+	var found *index.Plugin
+	var foundRev string
+	err := walkPluginHistory(indexDir, pluginName, func(rev string, p index.Plugin) bool {
+		if p.Spec.Version != version {
+			return true
+		}
+		found, foundRev = &p, rev
+		return false
+	})
+	if err != nil {
+		return index.Plugin{}, err
+	}
+	if found == nil {
+		return index.Plugin{}, ErrVersionNotFound
+	}
+	klog.V(2).Infof("Found %s@%s at index revision %s", pluginName, version, foundRev)
+	return *found, errors.Wrapf(validation.ValidatePlugin(pluginName, *found), "plugin manifest at revision %s is invalid", foundRev)
+}
+
+// PluginVersions returns the versions of the plugin found in the git history
+// of the index repository at indexDir, newest revision first, without
+// duplicates.
+func PluginVersions(indexDir, pluginName string) ([]string, error) {
+	pluginName = currentPluginName(indexDir, pluginName)
+	var versions []string
+	seen := make(map[string]bool)
+	err := walkPluginHistory(indexDir, pluginName, func(_ string, p index.Plugin) bool {
+		if !seen[p.Spec.Version] {
+			seen[p.Spec.Version] = true
+			versions = append(versions, p.Spec.Version)
+		}
+		return true
+	})
+	return versions, err
+}
+
+// currentPluginName resolves the plugin name like CanonicalPluginName against
+// the manifests in the index repository at indexDir as they are now.
+func currentPluginName(indexDir, pluginName string) string {
+	return CanonicalPluginName(filepath.Join(indexDir, "plugins"), pluginName)
+}
+
+// walkPluginHistory calls fn with the manifests of the plugin in the git
+// history of the index repository at indexDir, newest first, until fn returns
+// false. Revisions where the manifest was deleted or can't be decoded are
+// skipped.
+func walkPluginHistory(indexDir, pluginName string, fn func(rev string, p index.Plugin) bool) error {
+	if !validation.IsSafePluginName(pluginName) {
+		return errors.Errorf("plugin name %q not allowed", pluginName)
+	}
+
+	file := path.Join("plugins", pluginName+constants.ManifestExtension)
+	revs, err := gitutil.FileRevisions(indexDir, file)
+	if err != nil {
+		return err
+	}
+	klog.V(3).Infof("Found %d revisions of %q in the index history", len(revs), file)
+
+	for _, rev := range revs {
+		b, err := gitutil.ShowFile(indexDir, rev, file)
+		if err != nil {
+			// the commit deleted the manifest
+			klog.V(4).Infof("Skipping revision %s: %v", rev, err)
+			continue
+		}
+		p, err := DecodePluginFile(bytes.NewReader(b))
+		if err != nil {
+			klog.V(4).Infof("Skipping revision %s, manifest cannot be decoded: %v", rev, err)
+			continue
+		}
+		if !fn(rev, p) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// LoadPluginAtTime loads the manifest of the plugin as it was in the index
+// repository at indexDir at the given time. It returns ErrNotFoundAtTime if
+// the plugin was not in the index then.
+func LoadPluginAtTime(indexDir, pluginName string, t time.Time) (index.Plugin, error) {
+	pluginName = currentPluginName(indexDir, pluginName)
+	if !validation.IsSafePluginName(pluginName) {
+		return index.Plugin{}, errors.Errorf("plugin name %q not allowed", pluginName)
+	}
+
+	rev, err := gitutil.CommitAt(indexDir, t)
+	if err != nil {
+		return index.Plugin{}, err
+	}
+	if rev == "" {
+		klog.V(2).Infof("Index history does not go back to %s", t)
+		return index.Plugin{}, ErrNotFoundAtTime
+	}
+	klog.V(2).Infof("Index was at revision %s as of %s", rev, t)
+
+	file := path.Join("plugins", pluginName+constants.ManifestExtension)
+	b, err := gitutil.ShowFile(indexDir, rev, file)
+	if err != nil {
+		klog.V(4).Infof("Cannot read %q at revision %s: %v", file, rev, err)
+		return index.Plugin{}, ErrNotFoundAtTime
+	}
+	p, err := DecodePluginFile(bytes.NewReader(b))
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to decode the plugin manifest at revision %s", rev)
+	}
+	return p, errors.Wrapf(validation.ValidatePlugin(pluginName, p), "plugin manifest at revision %s is invalid", rev)
+}
+
+// kinds of changes of a plugin between two revisions of the index
+const (
+	PluginAdded   = "added"
+	PluginRemoved = "removed"
+	PluginChanged = "changed"
+)
+
+// PluginChange is a plugin whose manifest differs between two revisions of
+// the index.
+type PluginChange struct {
+	Name string
+	// Change is one of PluginAdded, PluginRemoved or PluginChanged.
+	Change string
+	// OldVersion is the version before the change, empty if the plugin was
+	// added or its manifest could not be decoded.
+	OldVersion string
+	// NewVersion is the version after the change, empty if the plugin was
+	// removed or its manifest could not be decoded.
+	NewVersion string
+}
+
+// DiffPlugins returns the plugins whose manifests differ between the commits
+// from and to of the index repository at indexDir, ordered by name.
+func DiffPlugins(indexDir, from, to string) ([]PluginChange, error) {
+	files, err := gitutil.ChangedFiles(indexDir, from, to, "plugins")
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []PluginChange
+	for _, f := range files {
+		if path.Dir(f.Path) != "plugins" || path.Ext(f.Path) != constants.ManifestExtension {
+			continue
+		}
+		c := PluginChange{Name: strings.TrimSuffix(path.Base(f.Path), constants.ManifestExtension)}
+		switch f.Status {
+		case "A":
+			c.Change = PluginAdded
+		case "D":
+			c.Change = PluginRemoved
+		default:
+			c.Change = PluginChanged
+		}
+		if c.Change != PluginAdded {
+			c.OldVersion = versionAt(indexDir, from, f.Path)
+		}
+		if c.Change != PluginRemoved {
+			c.NewVersion = versionAt(indexDir, to, f.Path)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// versionAt returns the plugin version in the manifest file at the commit, or
+// an empty string if the manifest cannot be read.
+func versionAt(indexDir, commit, file string) string {
+	b, err := gitutil.ShowFile(indexDir, commit, file)
+	if err != nil {
+		klog.V(2).Infof("Cannot read %q at revision %s: %v", file, commit, err)
+		return ""
+	}
+	p, err := DecodePluginFile(bytes.NewReader(b))
+	if err != nil {
+		klog.V(2).Infof("Cannot decode %q at revision %s: %v", file, commit, err)
+		return ""
+	}
+	return p.Spec.Version
+}
@@ -0,0 +1,228 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+// commitPlugin writes the plugin manifest into the git repository at the
+// temporary directory and commits it.
+func commitPlugin(t *testing.T, tmpDir *testutil.TempDir, name, version string) {
+	t.Helper()
+	b, err := yaml.Marshal(testutil.NewPlugin().WithName(name).WithVersion(version).V())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("plugins/"+name+".yaml", b)
+	runGit(t, tmpDir.Root(), "add", "-A")
+	runGit(t, tmpDir.Root(), "commit", "-m", name+" "+version)
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v, output=%s", args, err, out)
+	}
+}
+
+func TestLoadPluginAtVersion(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	runGit(t, tmpDir.Root(), "init")
+	commitPlugin(t, tmpDir, "foo", "v1.0.0")
+	commitPlugin(t, tmpDir, "foo", "v1.1.0")
+	commitPlugin(t, tmpDir, "foo", "v2.0.0")
+
+	tests := []struct {
+		name    string
+		plugin  string
+		version string
+		wantErr error
+	}{
+		{
+			name:    "current version",
+			plugin:  "foo",
+			version: "v2.0.0",
+		},
+		{
+			name:    "older version",
+			plugin:  "foo",
+			version: "v1.1.0",
+		},
+		{
+			name:    "unknown version",
+			plugin:  "foo",
+			version: "v1.5.0",
+			wantErr: ErrVersionNotFound,
+		},
+		{
+			name:    "unknown plugin",
+			plugin:  "bar",
+			version: "v1.0.0",
+			wantErr: ErrVersionNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPluginAtVersion(tmpDir.Root(), tt.plugin, tt.version)
+			if err != tt.wantErr {
+				t.Fatalf("LoadPluginAtVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tt.plugin || got.Spec.Version != tt.version {
+				t.Fatalf("LoadPluginAtVersion() = %s@%s, want %s@%s", got.Name, got.Spec.Version, tt.plugin, tt.version)
+			}
+		})
+	}
+
+	if got, err := LoadPluginAtVersion(tmpDir.Root(), "Foo", "v1.1.0"); err != nil || got.Name != "foo" {
+		t.Errorf("LoadPluginAtVersion() of name in other case = %s, %v, want foo", got.Name, err)
+	}
+}
+
+func TestPluginVersions(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	runGit(t, tmpDir.Root(), "init")
+	commitPlugin(t, tmpDir, "foo", "v1.0.0")
+	commitPlugin(t, tmpDir, "foo", "v1.1.0")
+	commitPlugin(t, tmpDir, "foo", "v1.0.0")
+
+	got, err := PluginVersions(tmpDir.Root(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"v1.0.0", "v1.1.0"}, got); diff != "" {
+		t.Errorf("PluginVersions() mismatch (-want +got):\n%s", diff)
+	}
+	if got, err := PluginVersions(tmpDir.Root(), "FOO"); err != nil || len(got) != 2 {
+		t.Errorf("PluginVersions() of name in other case = %v, %v", got, err)
+	}
+	if got, err := PluginVersions(tmpDir.Root(), "bar"); err != nil || len(got) != 0 {
+		t.Errorf("PluginVersions() of unknown plugin = %v, %v", got, err)
+	}
+}
+
+func revParse(t *testing.T, dir string) string {
+	t.Helper()
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestDiffPlugins(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	runGit(t, tmpDir.Root(), "init")
+	commitPlugin(t, tmpDir, "foo", "v1.0.0")
+	commitPlugin(t, tmpDir, "bar", "v1.0.0")
+	commitPlugin(t, tmpDir, "qux", "v0.1.0")
+	from := revParse(t, tmpDir.Root())
+
+	commitPlugin(t, tmpDir, "foo", "v1.1.0")
+	commitPlugin(t, tmpDir, "baz", "v0.2.0")
+	runGit(t, tmpDir.Root(), "rm", "-q", "plugins/bar.yaml")
+	tmpDir.Write("plugins/qux.yaml", []byte("not a manifest"))
+	tmpDir.Write("README.md", []byte("not a plugin"))
+	runGit(t, tmpDir.Root(), "add", "-A")
+	runGit(t, tmpDir.Root(), "commit", "-m", "remove bar, break qux")
+	to := revParse(t, tmpDir.Root())
+
+	got, err := DiffPlugins(tmpDir.Root(), from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PluginChange{
+		{Name: "bar", Change: PluginRemoved, OldVersion: "v1.0.0"},
+		{Name: "baz", Change: PluginAdded, NewVersion: "v0.2.0"},
+		{Name: "foo", Change: PluginChanged, OldVersion: "v1.0.0", NewVersion: "v1.1.0"},
+		{Name: "qux", Change: PluginChanged, OldVersion: "v0.1.0"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DiffPlugins() mismatch:\n%s", diff)
+	}
+
+	if got, err := DiffPlugins(tmpDir.Root(), to, to); err != nil || len(got) != 0 {
+		t.Errorf("DiffPlugins() of the same commit = %+v, %v, want no changes", got, err)
+	}
+}
+
+func TestLoadPluginAtTime(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	commitAt := func(date, name, version string) {
+		t.Helper()
+		defer os.Unsetenv("GIT_COMMITTER_DATE")
+		os.Setenv("GIT_COMMITTER_DATE", date)
+		commitPlugin(t, tmpDir, name, version)
+	}
+	runGit(t, tmpDir.Root(), "init")
+	commitAt("2020-01-01T00:00:00Z", "foo", "v1.0.0")
+	commitAt("2020-03-01T00:00:00Z", "bar", "v1.0.0")
+	commitAt("2020-06-01T00:00:00Z", "foo", "v2.0.0")
+
+	tests := []struct {
+		name    string
+		plugin  string
+		at      string
+		want    string
+		wantErr error
+	}{
+		{name: "first version", plugin: "foo", at: "2020-02-01T00:00:00Z", want: "v1.0.0"},
+		{name: "at the time of a commit", plugin: "foo", at: "2020-06-01T00:00:00Z", want: "v2.0.0"},
+		{name: "latest version", plugin: "foo", at: "2021-01-01T00:00:00Z", want: "v2.0.0"},
+		{name: "name in other case", plugin: "Foo", at: "2021-01-01T00:00:00Z", want: "v2.0.0"},
+		{name: "plugin added later", plugin: "bar", at: "2020-02-01T00:00:00Z", wantErr: ErrNotFoundAtTime},
+		{name: "before the history", plugin: "foo", at: "2019-01-01T00:00:00Z", wantErr: ErrNotFoundAtTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadPluginAtTime(tmpDir.Root(), tt.plugin, at)
+			if err != tt.wantErr {
+				t.Fatalf("LoadPluginAtTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Spec.Version != tt.want {
+				t.Errorf("LoadPluginAtTime() version = %s, want %s", got.Spec.Version, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+// maxSuggestionDistance is the highest edit distance between a plugin name
+// that is not in the index and a plugin name suggested instead.
+const maxSuggestionDistance = 2
+
+// normalizePluginName returns the form of a plugin name that names differing
+// only in case or in using "_" instead of "-" share.
+func normalizePluginName(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "-", -1))
+}
+
+// CanonicalPluginName returns the name of the plugin manifest in pluginsDir
+// that the plugin name refers to. If there is no manifest with exactly that
+// name, it is the only manifest whose name has the same normalized form.
+// Otherwise, the name is returned as is.
+func CanonicalPluginName(pluginsDir, name string) string {
+	if _, err := os.Stat(filepath.Join(pluginsDir, name+constants.ManifestExtension)); err == nil {
+		return name
+	}
+	files, err := findPluginManifestFiles(pluginsDir)
+	if err != nil {
+		klog.V(4).Infof("Failed to scan plugins to resolve plugin name %q: %v", name, err)
+		return name
+	}
+	var matches []string
+	for _, file := range files {
+		candidate := strings.TrimSuffix(file, filepath.Ext(file))
+		if normalizePluginName(candidate) == normalizePluginName(name) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) != 1 {
+		return name
+	}
+	klog.V(2).Infof("Resolved plugin name %q to %q", name, matches[0])
+	return matches[0]
+}
+
+// SimilarPluginName returns the name of the plugin in pluginsDir that is most
+// similar to a plugin name that is not in the index, to suggest it instead.
+// It returns false if no plugin name is similar enough or several are equally
+// similar.
+func SimilarPluginName(pluginsDir, name string) (string, bool) {
+	files, err := findPluginManifestFiles(pluginsDir)
+	if err != nil {
+		klog.V(4).Infof("Failed to scan plugins to suggest a plugin name: %v", err)
+		return "", false
+	}
+	var best string
+	bestDistance, ties := maxSuggestionDistance+1, 0
+	for _, file := range files {
+		candidate := strings.TrimSuffix(file, filepath.Ext(file))
+		d := editDistance(normalizePluginName(name), normalizePluginName(candidate))
+		switch {
+		case d < bestDistance:
+			best, bestDistance, ties = candidate, d, 0
+		case d == bestDistance:
+			ties++
+		}
+	}
+	if best == "" || ties > 0 {
+		return "", false
+	}
+	return best, true
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a int, rest ...int) int {
+	for _, b := range rest {
+		if b < a {
+			a = b
+		}
+	}
+	return a
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPluginByName_normalizesName(t *testing.T) {
+	pluginsDir := filepath.Join(testdataPath(t), "testindex", "plugins")
+	for _, name := range []string{"Foo", "FOO", "BAR"} {
+		p, err := LoadPluginByName(pluginsDir, name)
+		if err != nil {
+			t.Errorf("LoadPluginByName(%q) error = %v", name, err)
+			continue
+		}
+		if p.Name == name {
+			t.Errorf("LoadPluginByName(%q) returned the plugin with the name as typed, expected the canonical name", name)
+		}
+	}
+	if _, err := LoadPluginByName(pluginsDir, "fooo"); !os.IsNotExist(err) {
+		t.Errorf("expected IsNotExist error for a name that doesn't normalize to a plugin, got: %v", err)
+	}
+}
+
+func Test_CanonicalPluginName(t *testing.T) {
+	pluginsDir := filepath.Join(testdataPath(t), "testindex", "plugins")
+	tests := []struct {
+		name, want string
+	}{
+		{name: "foo", want: "foo"},
+		{name: "Foo", want: "foo"},
+		{name: "BadPlugin2", want: "badplugin2"},
+		{name: "not-found", want: "not-found"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalPluginName(pluginsDir, tt.name); got != tt.want {
+			t.Errorf("CanonicalPluginName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := CanonicalPluginName(filepath.Join(pluginsDir, "missing"), "Foo"); got != "Foo" {
+		t.Errorf("expected the name as is for a missing directory, got %q", got)
+	}
+}
+
+func TestSimilarPluginName(t *testing.T) {
+	pluginsDir := filepath.Join(testdataPath(t), "testindex", "plugins")
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "fo", want: "foo", wantOK: true},
+		{name: "fooo", want: "foo", wantOK: true},
+		{name: "wrong_name", want: "wrongname", wantOK: true},
+		{name: "badplugin3"}, // as close to badplugin as to badplugin2
+		{name: "something"},
+	}
+	for _, tt := range tests {
+		got, ok := SimilarPluginName(pluginsDir, tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("SimilarPluginName(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func Test_editDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"foo", "", 3},
+		{"foo", "foo", 0},
+		{"foo", "fop", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -26,6 +27,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/krew/internal/index/validation"
+	"sigs.k8s.io/krew/internal/installation/semver"
 	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
@@ -72,17 +74,101 @@ func LoadPluginListFromFS(indexDir string) ([]index.Plugin, error) {
 	return list, nil
 }
 
-// LoadPluginByName loads a plugins index file by its name. When plugin
-// file not found, it returns an error that can be checked with os.IsNotExist.
+// PluginNamesWithPrefix returns the names of the plugins in any of the plugins
+// directories that start with prefix, in lexical order and without
+// duplicates. Directories that do not exist are skipped.
+func PluginNamesWithPrefix(prefix string, pluginsDirs ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, dir := range pluginsDirs {
+		files, err := findPluginManifestFiles(dir)
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to scan plugins in index directory")
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(file, filepath.Ext(file))
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// LoadPluginByName loads a plugins index file by its name. A name that differs
+// from the name of only one plugin in case or in using "_" instead of "-"
+// loads that plugin, and the returned manifest has its canonical name. When
+// plugin file not found, it returns an error that can be checked with
+// os.IsNotExist.
 func LoadPluginByName(pluginsDir, pluginName string) (index.Plugin, error) {
 	if !validation.IsSafePluginName(pluginName) {
 		return index.Plugin{}, errors.Errorf("plugin name %q not allowed", pluginName)
 	}
+	pluginName = CanonicalPluginName(pluginsDir, pluginName)
 
 	klog.V(4).Infof("Reading plugin %q", pluginName)
 	return ReadPluginFromFile(filepath.Join(pluginsDir, pluginName+constants.ManifestExtension))
 }
 
+// LoadNewestPlugin loads the manifest of the plugin from each of the plugins
+// directories and returns the one with the highest version, or the first one
+// if versions can't be compared. Directories without a manifest for the plugin
+// are skipped. If none has one, it returns an error that can be checked with
+// os.IsNotExist.
+func LoadNewestPlugin(pluginName string, pluginsDirs ...string) (index.Plugin, error) {
+	var newest index.Plugin
+	found := false
+	for _, dir := range pluginsDirs {
+		p, err := LoadPluginByName(dir, pluginName)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return index.Plugin{}, err
+		}
+		if !found || isNewer(p, newest) {
+			newest, found = p, true
+		}
+	}
+	if !found {
+		return index.Plugin{}, &os.PathError{Op: "open", Path: pluginName + constants.ManifestExtension, Err: os.ErrNotExist}
+	}
+	return newest, nil
+}
+
+// isNewer reports whether the version of plugin a is higher than that of b.
+func isNewer(a, b index.Plugin) bool {
+	va, err := semver.Parse(a.Spec.Version)
+	if err != nil {
+		return false
+	}
+	vb, err := semver.Parse(b.Spec.Version)
+	if err != nil {
+		return false
+	}
+	return semver.Less(vb, va)
+}
+
+// ReadPluginFileByName returns the unparsed contents of the plugin manifest
+// file in the index with the given name, which is resolved like with
+// LoadPluginByName. When the file is not found, it returns an error that can
+// be checked with os.IsNotExist.
+func ReadPluginFileByName(pluginsDir, pluginName string) ([]byte, error) {
+	if !validation.IsSafePluginName(pluginName) {
+		return nil, errors.Errorf("plugin name %q not allowed", pluginName)
+	}
+	pluginName = CanonicalPluginName(pluginsDir, pluginName)
+
+	b, err := ioutil.ReadFile(filepath.Join(pluginsDir, pluginName+constants.ManifestExtension))
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+	return b, errors.Wrap(err, "failed to read index file")
+}
+
 // ReadPluginFromFile loads a file from the FS. When plugin file not found, it
 // returns an error that can be checked with os.IsNotExist.
 func ReadPluginFromFile(path string) (index.Plugin, error) {
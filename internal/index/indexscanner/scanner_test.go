@@ -15,12 +15,21 @@
 package indexscanner
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+
+	"sigs.k8s.io/krew/internal/index/validation"
+	"sigs.k8s.io/krew/pkg/constants"
 )
 
 func TestReadPluginFile(t *testing.T) {
@@ -100,6 +109,69 @@ func TestReadPluginFile_preservesNotFoundErr(t *testing.T) {
 	}
 }
 
+func TestReadPlugin_apiVersions(t *testing.T) {
+	const manifest = `apiVersion: %s
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  version: v1.0.0
+  shortDescription: foo
+  someFutureField: ignored
+  platforms:
+  - uri: https://example.com/foo.tar.gz
+    sha256: deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef
+    bin: kubectl-foo
+    someFuturePlatformField: ignored
+    selector:
+      matchLabels:
+        os: linux
+`
+	tests := []struct {
+		name       string
+		apiVersion string
+		wantErr    bool
+		wantNewer  bool
+		wantOlder  bool
+	}{
+		{
+			name:       "current version with unknown optional fields",
+			apiVersion: constants.CurrentAPIVersion,
+		},
+		{
+			name:       "too new",
+			apiVersion: "krew.googlecontainertools.github.com/v1beta1",
+			wantErr:    true,
+			wantNewer:  true,
+		},
+		{
+			name:       "too old",
+			apiVersion: "krew.googlecontainertools.github.com/v1alpha1",
+			wantErr:    true,
+			wantOlder:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := ioutil.NopCloser(strings.NewReader(fmt.Sprintf(manifest, tt.apiVersion)))
+			_, err := ReadPlugin(in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPlugin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			apiErr, ok := errors.Cause(err).(validation.APIVersionError)
+			if !ok {
+				t.Fatalf("ReadPlugin() error = %v, want validation.APIVersionError", err)
+			}
+			if apiErr.IsNewer() != tt.wantNewer || apiErr.IsOlder() != tt.wantOlder {
+				t.Fatalf("ReadPlugin() error = %v, want newer=%v older=%v", err, tt.wantNewer, tt.wantOlder)
+			}
+		})
+	}
+}
+
 func TestLoadIndexListFromFS(t *testing.T) {
 	type args struct {
 		indexDir string
@@ -184,6 +256,68 @@ func TestLoadIndexFileFromFS(t *testing.T) {
 	}
 }
 
+func TestReadPluginFileByName(t *testing.T) {
+	pluginsDir := filepath.Join(testdataPath(t), "testindex", "plugins")
+	want, err := ioutil.ReadFile(filepath.Join(pluginsDir, "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadPluginFileByName(pluginsDir, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ReadPluginFileByName() returned different contents than the file:\n%s", got)
+	}
+
+	if _, err := ReadPluginFileByName(pluginsDir, "not-found"); !os.IsNotExist(err) {
+		t.Errorf("expected IsNotExist error for missing plugin, got: %v", err)
+	}
+	if _, err := ReadPluginFileByName(pluginsDir, "../foo"); err == nil {
+		t.Errorf("expected error for unsafe plugin name")
+	}
+}
+
+func TestPluginNamesWithPrefix(t *testing.T) {
+	pluginsDir := filepath.Join(testdataPath(t), "testindex", "plugins")
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{prefix: "ba", expected: []string{"badplugin", "badplugin2", "bar"}},
+		{prefix: "fo", expected: []string{"foo"}},
+		{prefix: "foo", expected: []string{"foo"}},
+		{prefix: "notyaml"},
+		{prefix: "x"},
+	}
+	for _, tt := range tests {
+		got, err := PluginNamesWithPrefix(tt.prefix, pluginsDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.expected, got); diff != "" {
+			t.Errorf("PluginNamesWithPrefix(%q) mismatch (-want +got):\n%s", tt.prefix, diff)
+		}
+	}
+}
+
+func TestPluginNamesWithPrefix_multipleDirs(t *testing.T) {
+	indexDir := filepath.Join(testdataPath(t), "testindex")
+	dirs := []string{filepath.Join(indexDir, "plugins"), indexDir, filepath.Join(indexDir, "plugins"), filepath.Join(indexDir, "missing")}
+	for prefix, expected := range map[string][]string{
+		"d":   {"dontscan"},
+		"foo": {"foo"},
+	} {
+		got, err := PluginNamesWithPrefix(prefix, dirs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("PluginNamesWithPrefix(%q) mismatch (-want +got):\n%s", prefix, diff)
+		}
+	}
+}
+
 func testdataPath(t *testing.T) string {
 	pwd, err := filepath.Abs(".")
 	if err != nil {
@@ -0,0 +1,176 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// semverPattern matches the versions accepted by semver.Parse.
+const semverPattern = `^v(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(-(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(\.(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*)?` +
+	`(\+[0-9a-zA-Z-]+(\.[0-9a-zA-Z-]+)*)?$`
+
+// Schema is a JSON Schema (draft-07) document or subschema.
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Const                string             `json:"const,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+}
+
+// PluginSchema returns the JSON Schema of plugin manifests. Its structure is
+// generated from the index.Plugin type, and it has the constraints that
+// ValidatePlugin checks, except for those that a schema can't express: that
+// the plugin name matches the manifest file name and is not a reserved name
+// on Windows, that file paths stay within the plugin directory, and that
+// platforms with rawBinary don't have files.
+func PluginSchema() *Schema {
+	s := schemaFor(reflect.TypeOf(index.Plugin{}))
+	s.SchemaURI = "http://json-schema.org/draft-07/schema#"
+	s.Title = "krew plugin manifest"
+	s.Description = "Plugin manifest (" + constants.CurrentAPIVersion + ") of the krew plugin index."
+	s.Required = []string{"apiVersion", "kind", "metadata", "spec"}
+	property(s, "apiVersion").Const = constants.CurrentAPIVersion
+	property(s, "kind").Const = constants.PluginKind
+	name := property(s, "metadata", "name")
+	name.Pattern = safePluginRegexp.String()
+	property(s, "metadata").Required = []string{"name"}
+
+	spec := property(s, "spec")
+	spec.Required = []string{"version", "shortDescription", "platforms"}
+	property(spec, "version").Pattern = semverPattern
+	property(spec, "shortDescription").Pattern = `^[^\r\n]+$`
+	property(spec, "tags").Items.Pattern = `^\S+$`
+	property(spec, "platforms").MinItems = intPtr(1)
+
+	platform := property(spec, "platforms").Items
+	platform.Required = []string{"uri", "sha256", "bin", "selector"}
+	property(platform, "uri").MinLength = intPtr(1)
+	property(platform, "sha256").Pattern = sha256Pattern
+	property(platform, "bin").MinLength = intPtr(1)
+	property(platform, "files").MinItems = intPtr(1)
+	fileOp := property(platform, "files").Items
+	fileOp.Required = []string{"from", "to"}
+	property(fileOp, "from").MinLength = intPtr(1)
+	property(fileOp, "to").MinLength = intPtr(1)
+
+	// selectors can only select the os and arch labels
+	selectorKeys := []string{"os", "arch"}
+	selector := property(platform, "selector")
+	selector.MinProperties = intPtr(1)
+	matchLabels := property(selector, "matchLabels")
+	matchLabels.PropertyNames = &Schema{Enum: selectorKeys}
+	matchLabels.MinProperties = intPtr(1)
+	matchExpressions := property(selector, "matchExpressions")
+	matchExpressions.MinItems = intPtr(1)
+	property(matchExpressions.Items, "key").Enum = selectorKeys
+	matchExpressions.Items.Required = []string{"key", "operator"}
+	property(matchExpressions.Items, "operator").Enum = []string{
+		string(metav1.LabelSelectorOpIn), string(metav1.LabelSelectorOpNotIn),
+		string(metav1.LabelSelectorOpExists), string(metav1.LabelSelectorOpDoesNotExist),
+	}
+	return s
+}
+
+// property returns the subschema of the property at the path. It panics if
+// there is no such property, so that a constraint for a field that was renamed
+// doesn't go unnoticed.
+func property(s *Schema, path ...string) *Schema {
+	for i, p := range path {
+		next, ok := s.Properties[p]
+		if !ok {
+			panic(fmt.Sprintf("schema has no property %q", strings.Join(path[:i+1], ".")))
+		}
+		s = next
+	}
+	return s
+}
+
+// schemaFor generates the schema of values of type t as they are encoded to
+// JSON.
+func schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeOf(metav1.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(metav1.ObjectMeta{}):
+		// only the name of the plugin is used
+		return &Schema{Type: "object", Properties: map[string]*Schema{"name": {Type: "string"}}}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(s, t)
+		return s
+	}
+	panic(fmt.Sprintf("no JSON schema for type %s", t))
+}
+
+// addFields adds the JSON fields of struct type t to the properties of s,
+// including the fields of inlined structs.
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			addFields(s, f.Type)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = schemaFor(f.Type)
+	}
+}
+
+func intPtr(i int) *int { return &i }
@@ -0,0 +1,60 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func TestPluginSchema(t *testing.T) {
+	s := PluginSchema()
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := property(s, "apiVersion").Const; got != constants.CurrentAPIVersion {
+		t.Errorf("apiVersion const = %q, expected %q", got, constants.CurrentAPIVersion)
+	}
+	platform := property(s, "spec", "platforms").Items
+	for _, name := range []string{"uri", "sha256", "bin", "selector", "files", "rawBinary"} {
+		property(platform, name)
+	}
+	if got := property(platform, "rawBinary").Type; got != "boolean" {
+		t.Errorf("rawBinary has type %q, expected boolean", got)
+	}
+	if got := property(platform, "selector", "matchLabels").AdditionalProperties; got == nil || got.Type != "string" {
+		t.Errorf("expected matchLabels to have string values, got %+v", got)
+	}
+	if _, ok := s.Properties["status"]; ok {
+		t.Error("expected the plugin schema not to have the receipt status")
+	}
+}
+
+func Test_semverPattern(t *testing.T) {
+	re := regexp.MustCompile(semverPattern)
+	for _, v := range []string{
+		"v0.0.0", "v1.2.3", "v1.2.3-beta.2+foo.bar", "v1.0.0-alpha-1", "v1.0.0-0.3.7", "v1.0.0+20130313144700",
+		"", "1.0.0", "v1", "v1.2", "v01.2.3", "v1.02.3", "v1.2.03", "v1.0.1-", "v1.0.1+", "v1.0.0-01", "v-1.2.3",
+	} {
+		_, err := semver.Parse(v)
+		if matched := re.MatchString(v); matched != (err == nil) {
+			t.Errorf("pattern matches %q = %v, but semver.Parse() error = %v", v, matched, err)
+		}
+	}
+}
@@ -16,11 +16,17 @@
 package validation
 
 import (
+	"fmt"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiversion "k8s.io/apimachinery/pkg/version"
 
 	"sigs.k8s.io/krew/internal/installation/semver"
 	"sigs.k8s.io/krew/pkg/constants"
@@ -35,6 +41,9 @@ var (
 	safePluginRegexp = regexp.MustCompile(`^[\w-]+$`)
 	validSHA256      = regexp.MustCompile(sha256Pattern)
 
+	// currentAPI is the parsed form of constants.CurrentAPIVersion.
+	currentAPI, _ = schema.ParseGroupVersion(constants.CurrentAPIVersion)
+
 	// windowsForbidden is taken from  https://docs.microsoft.com/en-us/windows/desktop/FileIO/naming-a-file
 	windowsForbidden = []string{"CON", "PRN", "AUX", "NUL", "COM1", "COM2",
 		"COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9", "LPT1", "LPT2",
@@ -58,13 +67,73 @@ func isSupportedAPIVersion(apiVersion string) bool {
 	return apiVersion == constants.CurrentAPIVersion
 }
 
+// APIVersionError is returned when a plugin manifest declares an apiVersion
+// that is not supported by this version of krew.
+type APIVersionError struct {
+	APIVersion string
+}
+
+// IsNewer reports whether the unsupported apiVersion is a newer version of the
+// krew plugin API than the one supported by this version of krew.
+func (e APIVersionError) IsNewer() bool {
+	v, ok := krewAPIVersion(e.APIVersion)
+	return ok && apiversion.CompareKubeAwareVersionStrings(v, currentAPI.Version) > 0
+}
+
+// IsOlder reports whether the unsupported apiVersion is an older version of the
+// krew plugin API than the one supported by this version of krew.
+func (e APIVersionError) IsOlder() bool {
+	v, ok := krewAPIVersion(e.APIVersion)
+	return ok && apiversion.CompareKubeAwareVersionStrings(v, currentAPI.Version) < 0
+}
+
+func (e APIVersionError) Error() string {
+	switch {
+	case e.IsNewer():
+		return fmt.Sprintf("plugin manifest has apiVersion=%q, which requires a newer version of krew than this one (supports %q); upgrade krew with \"kubectl krew upgrade krew\"",
+			e.APIVersion, constants.CurrentAPIVersion)
+	case e.IsOlder():
+		return fmt.Sprintf("plugin manifest has apiVersion=%q, which is no longer supported by this version of krew (supports %q); try updating the plugin index with \"kubectl krew update\"",
+			e.APIVersion, constants.CurrentAPIVersion)
+	default:
+		return fmt.Sprintf("plugin manifest has apiVersion=%q, not supported in this version of krew (try updating plugin index or install a newer version of krew)", e.APIVersion)
+	}
+}
+
+// krewAPIVersion returns the version part of apiVersion if it belongs to the
+// krew plugin API group.
+func krewAPIVersion(apiVersion string) (string, bool) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil || gv.Group != currentAPI.Group || gv.Version == "" {
+		return "", false
+	}
+	return gv.Version, true
+}
+
 func isValidSHA256(s string) bool { return validSHA256.MatchString(s) }
 
+// InvalidError is returned for plugin manifests that fail validation.
+type InvalidError struct {
+	Err error
+}
+
+func (e InvalidError) Error() string { return e.Err.Error() }
+
+// Cause returns the validation failure.
+func (e InvalidError) Cause() error { return e.Err }
+
 // ValidatePlugin checks for structural validity of the Plugin object with given
-// name.
+// name. Validation failures are returned as InvalidError.
 func ValidatePlugin(name string, p index.Plugin) error {
+	if err := validatePlugin(name, p); err != nil {
+		return InvalidError{Err: err}
+	}
+	return nil
+}
+
+func validatePlugin(name string, p index.Plugin) error {
 	if !isSupportedAPIVersion(p.APIVersion) {
-		return errors.Errorf("plugin manifest has apiVersion=%q, not supported in this version of krew (try updating plugin index or install a newer version of krew)", p.APIVersion)
+		return APIVersionError{APIVersion: p.APIVersion}
 	}
 
 	if p.Kind != constants.PluginKind {
@@ -91,6 +160,9 @@ func ValidatePlugin(name string, p index.Plugin) error {
 	if _, err := semver.Parse(p.Spec.Version); err != nil {
 		return errors.Wrap(err, "failed to parse plugin version")
 	}
+	if err := validateTags(p.Spec.Tags); err != nil {
+		return errors.Wrap(err, "`tags` is invalid")
+	}
 	for _, pl := range p.Spec.Platforms {
 		if err := validatePlatform(pl); err != nil {
 			return errors.Wrapf(err, "platform (%+v) is badly constructed", pl)
@@ -116,12 +188,27 @@ func validatePlatform(p index.Platform) error {
 	if err := validateFiles(p.Files); err != nil {
 		return errors.Wrap(err, "`files` is invalid")
 	}
+	if p.RawBinary && p.Files != nil {
+		return errors.New("`files` cannot be specified with `rawBinary`, the downloaded executable is installed as `bin`")
+	}
 	if err := validateSelector(p.Selector); err != nil {
 		return errors.Wrap(err, "invalid platform selector")
 	}
 	return nil
 }
 
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if tag == "" {
+			return errors.New("tags cannot be empty")
+		}
+		if strings.IndexFunc(tag, unicode.IsSpace) >= 0 {
+			return errors.Errorf("tag %q should not contain whitespace", tag)
+		}
+	}
+	return nil
+}
+
 func validateFiles(fops []index.FileOperation) error {
 	if fops == nil {
 		return nil
@@ -135,6 +222,25 @@ func validateFiles(fops []index.FileOperation) error {
 		} else if op.To == "" {
 			return errors.New("`to` field has to be set")
 		}
+		if err := validateFilePath(op.From); err != nil {
+			return errors.Wrap(err, "invalid `from` field")
+		}
+		if err := validateFilePath(op.To); err != nil {
+			return errors.Wrap(err, "invalid `to` field")
+		}
+	}
+	return nil
+}
+
+// validateFilePath checks that the path of a file operation is relative and
+// stays within the extracted archive or the installation directory.
+func validateFilePath(p string) error {
+	s := strings.ReplaceAll(p, `\`, "/")
+	if path.IsAbs(s) || filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return errors.Errorf("path %q must be relative", p)
+	}
+	if c := path.Clean(s); c == ".." || strings.HasPrefix(c, "../") {
+		return errors.Errorf("path %q must not point outside of the plugin directory", p)
 	}
 	return nil
 }
@@ -17,6 +17,7 @@ package validation
 import (
 	"testing"
 
+	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/krew/internal/testutil"
@@ -83,6 +84,42 @@ func Test_isSupportedAPIVersion(t *testing.T) {
 	}
 }
 
+func TestValidatePlugin_unsupportedAPIVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantNewer bool
+		wantOlder bool
+	}{
+		{"wrong group", "networking.k8s.io/v1", false, false},
+		{"just api group", "krew.googlecontainertools.github.com", false, false},
+		{"old version", "krew.googlecontainertools.github.com/v1alpha1", false, true},
+		{"newer alpha", "krew.googlecontainertools.github.com/v1alpha3", true, false},
+		{"newer beta", "krew.googlecontainertools.github.com/v1beta1", true, false},
+		{"newer ga", "krew.googlecontainertools.github.com/v1", true, false},
+		{"newer major", "krew.googlecontainertools.github.com/v2alpha1", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPlugin().WithName("foo").WithTypeMeta(metav1.TypeMeta{
+				APIVersion: tt.in,
+				Kind:       constants.PluginKind,
+			}).V()
+			err := ValidatePlugin("foo", p)
+			apiErr, ok := errors.Cause(err).(APIVersionError)
+			if !ok {
+				t.Fatalf("ValidatePlugin() error = %v, want APIVersionError", err)
+			}
+			if got := apiErr.IsNewer(); got != tt.wantNewer {
+				t.Errorf("IsNewer() = %v, want %v", got, tt.wantNewer)
+			}
+			if got := apiErr.IsOlder(); got != tt.wantOlder {
+				t.Errorf("IsOlder() = %v, want %v", got, tt.wantOlder)
+			}
+		})
+	}
+}
+
 func TestValidatePlugin(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -176,6 +213,24 @@ func TestValidatePlugin(t *testing.T) {
 			plugin:     testutil.NewPlugin().WithShortDescription("just\r\nfoo").V(),
 			wantErr:    true,
 		},
+		{
+			name:       "with tags",
+			pluginName: "foo",
+			plugin:     testutil.NewPlugin().WithName("foo").WithTags("security", "networking").V(),
+			wantErr:    false,
+		},
+		{
+			name:       "empty tag",
+			pluginName: "foo",
+			plugin:     testutil.NewPlugin().WithName("foo").WithTags("security", "").V(),
+			wantErr:    true,
+		},
+		{
+			name:       "tag with whitespace",
+			pluginName: "foo",
+			plugin:     testutil.NewPlugin().WithName("foo").WithTags("service mesh").V(),
+			wantErr:    true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -223,6 +278,16 @@ func TestValidatePlatform(t *testing.T) {
 				MatchLabels: map[string]string{"unsupported-field": "orange"}}).V(),
 			wantErr: true,
 		},
+		{
+			name:     "raw binary",
+			platform: testutil.NewPlatform().WithRawBinary(true).WithFiles(nil).V(),
+			wantErr:  false,
+		},
+		{
+			name:     "raw binary with file operations",
+			platform: testutil.NewPlatform().WithRawBinary(true).V(),
+			wantErr:  true,
+		},
 		// TODO(ahmetb): add test case "bin field outside the plugin installation directory"
 		// by testing .WithBin("foo/../../../malicious-file").
 		// It appears like currently we're allowing this.
@@ -332,6 +397,30 @@ func Test_validateFiles(t *testing.T) {
 			files:   []index.FileOperation{{From: "", To: "present"}},
 			wantErr: true,
 		},
+		{
+			name:  "rename into a directory",
+			files: []index.FileOperation{{From: "foo-*/foo_linux_amd64", To: "bin/foo"}, {From: "LICENSE", To: "docs/"}},
+		},
+		{
+			name:    "absolute `to` path",
+			files:   []index.FileOperation{{From: "foo", To: "/usr/local/bin/foo"}},
+			wantErr: true,
+		},
+		{
+			name:    "`to` path outside of the installation directory",
+			files:   []index.FileOperation{{From: "foo", To: "bin/../../foo"}},
+			wantErr: true,
+		},
+		{
+			name:    "`to` path outside of the installation directory with backslashes",
+			files:   []index.FileOperation{{From: "foo", To: `..\foo`}},
+			wantErr: true,
+		},
+		{
+			name:    "`from` path outside of the archive",
+			files:   []index.FileOperation{{From: "../*", To: "."}},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -15,17 +15,23 @@
 package installation
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
 
 	"sigs.k8s.io/krew/internal/download"
 	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/filelock"
+	"sigs.k8s.io/krew/internal/index/validation"
 	"sigs.k8s.io/krew/internal/installation/receipt"
 	"sigs.k8s.io/krew/internal/pathutil"
 	"sigs.k8s.io/krew/pkg/constants"
@@ -35,14 +41,69 @@ import (
 // InstallOpts specifies options for plugin installation operation.
 type InstallOpts struct {
 	ArchiveFileOverride string
+
+	// BinName overrides the name the plugin is invoked with, it defaults to
+	// the plugin name.
+	BinName string
+
+	// DownloadHosts maps hosts in the download uri of the plugin to the hosts
+	// to download from instead (e.g. a mirror).
+	DownloadHosts map[string]string
+
+	// Offline fails the installation if it needs to download from the network.
+	Offline bool
+
+	// HTTPFetcher is used for downloads, unless ArchiveFileOverride is set.
+	HTTPFetcher download.HTTPFetcher
+
+	// VerifyRun runs the installed plugin with --help to check that it can be
+	// executed on this platform, and warns if it fails.
+	VerifyRun bool
+
+	// RequireRun is like VerifyRun, but fails the installation if the plugin
+	// doesn't run.
+	RequireRun bool
+
+	// Channel is the channel of the index the plugin was loaded from, which
+	// is recorded in the receipt. It is empty for the stable channel.
+	Channel string
+
+	// Progress, if set, is called with the progress events of the
+	// installation.
+	Progress ProgressFunc
+
+	// DownloadCache, if set, is checked for the download before fetching it,
+	// and verified downloads are added to it.
+	DownloadCache *download.Cache
+
+	// ReinstallOnChecksumChange makes Upgrade reinstall a plugin whose
+	// version did not change if the checksum of its download for the current
+	// platform changed, e.g. because the same version was rebuilt.
+	ReinstallOnChecksumChange bool
+
+	// DowngradeOK makes Upgrade install the version of the manifest whenever
+	// it differs from the installed version, even if it is older, e.g. after a
+	// release was pulled from the index.
+	DowngradeOK bool
 }
 
+// verifyRunTimeout is how long the installed plugin may run when it is
+// verified. A plugin still running after that has started fine.
+var verifyRunTimeout = 10 * time.Second
+
 type installOperation struct {
 	pluginName string
+	binName    string
 	platform   index.Platform
 
+	// customManifest is set if the plugin is not installed from an index.
+	customManifest bool
+
 	installDir string
 	binDir     string
+
+	// noLink leaves the link in binDir to the caller.
+	noLink bool
 }
 
 // Plugin lifecycle errors
@@ -50,51 +111,179 @@ var (
 	ErrIsAlreadyInstalled = errors.New("can't install, the newest version is already installed")
 	ErrIsNotInstalled     = errors.New("plugin is not installed")
 	ErrIsAlreadyUpgraded  = errors.New("can't upgrade, the newest version is already installed")
+	ErrNoKeptReceipt      = errors.New("no receipt was kept for the plugin")
 )
 
 // Install will download and install a plugin. The operation tries
 // to not get the plugin dir in a bad state if it fails during the process.
-func Install(p environment.Paths, plugin index.Plugin, opts InstallOpts) error {
-	klog.V(2).Infof("Looking for installed versions")
-	_, err := receipt.Load(p.PluginInstallReceiptPath(plugin.Name))
-	if err == nil {
-		return ErrIsAlreadyInstalled
-	} else if !os.IsNotExist(err) {
-		return errors.Wrap(err, "failed to look up plugin receipt")
+// indexName is recorded in the receipt as the source of the plugin, and is
+// empty for plugins installed from a custom manifest.
+func Install(p environment.Paths, plugin index.Plugin, indexName string, opts InstallOpts) error {
+	lock, err := lockPlugin(p, plugin.Name)
+	if err != nil {
+		return err
 	}
+	defer lock.Unlock()
 
-	// Find available installation candidate
-	candidate, ok, err := GetMatchingPlatform(plugin.Spec.Platforms)
+	target, err := prepareInstall(p, plugin, opts)
 	if err != nil {
-		return errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
-	}
-	if !ok {
-		return errors.Errorf("plugin %q does not offer installation for this platform", plugin.Name)
+		return err
 	}
+	binName, candidate := target.binName, target.platform
 
 	// The actual install should be the last action so that a failure during receipt
 	// saving does not result in an installed plugin without receipt.
 	klog.V(3).Infof("Install plugin %s at version=%s", plugin.Name, plugin.Spec.Version)
-	if err := install(installOperation{
-		pluginName: plugin.Name,
-		platform:   candidate,
+	uri, err := install(installOperation{
+		pluginName:     plugin.Name,
+		binName:        binName,
+		platform:       candidate,
+		customManifest: indexName == "",
 
 		binDir:     p.BinPath(),
 		installDir: p.PluginVersionInstallPath(plugin.Name, plugin.Spec.Version),
-	}, opts); err != nil {
+	}, opts)
+	if err != nil {
 		return errors.Wrap(err, "install failed")
 	}
 	klog.V(3).Infof("Storing install receipt for plugin %s", plugin.Name)
-	err = receipt.Store(plugin, p.PluginInstallReceiptPath(plugin.Name))
+	r := receipt.New(plugin, indexName)
+	recordFiles(&r.Status, p.PluginVersionInstallPath(plugin.Name, plugin.Spec.Version))
+	r.Status.SHA256 = candidate.Sha256
+	r.Status.URI = uri
+	if target.kept != nil {
+		r.Status.PreviousSHA256 = previousSHA256(*target.kept, candidate.Sha256)
+	}
+	if indexName != "" {
+		r.Status.Source.Channel = opts.Channel
+	}
+	r.Status.BinName = binName
+	now := metav1.Now()
+	r.Status.InstalledAt = &now
+	err = receipt.Store(r, p.PluginInstallReceiptPath(plugin.Name))
 	return errors.Wrap(err, "installation receipt could not be stored, uninstall may fail")
 }
 
-func install(op installOperation, opts InstallOpts) error {
+// installTarget is what Install installs for a plugin.
+type installTarget struct {
+	kept     *index.Receipt // the receipt kept when the plugin was uninstalled, if any
+	binName  string
+	platform index.Platform
+}
+
+// prepareInstall runs the checks of Install before it downloads anything, and
+// returns what it installs. It returns ErrIsAlreadyInstalled if the plugin is
+// installed.
+func prepareInstall(p environment.Paths, plugin index.Plugin, opts InstallOpts) (installTarget, error) {
+	klog.V(2).Infof("Looking for installed versions")
+	kept, err := receipt.Load(p.PluginInstallReceiptPath(plugin.Name))
+	if err != nil && !os.IsNotExist(err) {
+		return installTarget{}, errors.Wrap(err, "failed to look up plugin receipt")
+	} else if err == nil && !kept.Status.Removed {
+		return installTarget{}, ErrIsAlreadyInstalled
+	}
+	var target installTarget
+	if err == nil {
+		target.kept = &kept
+	}
+
+	target.binName = plugin.Name
+	if opts.BinName != "" {
+		if !validation.IsSafePluginName(opts.BinName) {
+			return installTarget{}, errors.Errorf("bin name %q is not valid", opts.BinName)
+		}
+		target.binName = opts.BinName
+	}
+	if err := ensureBinNameAvailable(p, plugin.Name, target.binName); err != nil {
+		return installTarget{}, err
+	}
+
+	// Find available installation candidate
+	candidate, ok, err := GetMatchingPlatform(plugin.Spec.Platforms)
+	if err != nil {
+		return installTarget{}, errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
+	}
+	if !ok {
+		return installTarget{}, errors.Errorf("plugin %q does not offer installation for this platform", plugin.Name)
+	}
+	target.platform = candidate
+	return target, nil
+}
+
+// recordFiles records the files installed in installDir and their checksums
+// in the receipt status. Nothing is recorded if they cannot be listed.
+func recordFiles(status *index.ReceiptStatus, installDir string) {
+	files, err := listFiles(installDir)
+	if err != nil {
+		klog.Warningf("Failed to record the installed files in the receipt: %v", err)
+		return
+	}
+	status.Files = files
+	status.Checksums = make(map[string]string, len(files))
+	for _, f := range files {
+		sum, err := fileSha256(filepath.Join(installDir, filepath.FromSlash(f)))
+		if err != nil {
+			klog.Warningf("Failed to record the checksums of the installed files in the receipt: %v", err)
+			status.Checksums = nil
+			return
+		}
+		status.Checksums[f] = sum
+	}
+}
+
+// lockPlugin acquires the lock for changing the installation of the plugin, so
+// that concurrent krew processes don't install or remove the same plugin at
+// the same time. Different plugins can be changed concurrently.
+func lockPlugin(p environment.Paths, name string) (*filelock.Lock, error) {
+	lock, err := filelock.Exclusive(p.PluginLockPath(name))
+	return lock, errors.Wrapf(err, "failed to lock plugin %q", name)
+}
+
+// ensureBinNameAvailable returns an error if the bin name is already used by
+// another installed plugin.
+func ensureBinNameAvailable(p environment.Paths, pluginName, binName string) error {
+	receipts, err := ListInstalledPlugins(p.InstallReceiptsPath())
+	if err != nil {
+		return errors.Wrap(err, "failed to find installed plugins")
+	}
+	want := pluginNameToBin(binName, IsWindows())
+	for _, r := range receipts {
+		if r.Name == pluginName {
+			continue
+		}
+		if pluginNameToBin(BinName(r), IsWindows()) == want {
+			return errors.Errorf("plugin %q is already installed as %q, install under a different bin name", r.Name, BinName(r))
+		}
+	}
+	return nil
+}
+
+// BinName returns the name the plugin of the receipt is invoked with.
+func BinName(r index.Receipt) string {
+	if r.Status.BinName != "" {
+		return r.Status.BinName
+	}
+	return r.Name
+}
+
+// checkOffline fails in offline mode if the download from uri with the sha256
+// checksum is neither overridden with a local archive nor cached.
+func checkOffline(uri, sha256 string, opts InstallOpts) error {
+	cached := opts.DownloadCache != nil && opts.DownloadCache.Has(sha256)
+	if opts.Offline && opts.ArchiveFileOverride == "" && !cached {
+		return errors.Errorf("downloading %q needs network access, which is blocked by offline mode", uri)
+	}
+	return nil
+}
+
+// install downloads and installs the plugin of the operation, and returns the
+// uri it was downloaded from.
+func install(op installOperation, opts InstallOpts) (string, error) {
 	// Download and extract
 	klog.V(3).Infof("Creating download staging directory")
 	downloadStagingDir, err := ioutil.TempDir("", "krew-downloads")
 	if err != nil {
-		return errors.Wrapf(err, "could not create staging dir %q", downloadStagingDir)
+		return "", errors.Wrapf(err, "could not create staging dir %q", downloadStagingDir)
 	}
 	klog.V(3).Infof("Successfully created download staging directory %q", downloadStagingDir)
 	defer func() {
@@ -103,29 +292,93 @@ func install(op installOperation, opts InstallOpts) error {
 			klog.Warningf("failed to clean up download staging directory: %s", err)
 		}
 	}()
-	if err := downloadAndExtract(downloadStagingDir, op.platform.URI, op.platform.Sha256, opts.ArchiveFileOverride); err != nil {
-		return errors.Wrap(err, "failed to unpack into staging dir")
+	uri, err := resolveDownloadURI(op.platform.URI, op.customManifest, opts.DownloadHosts)
+	if err != nil {
+		return "", err
+	}
+	if err := checkOffline(uri, op.platform.Sha256, opts); err != nil {
+		return "", err
+	}
+	// Platforms without file operations may provide the executable itself
+	// instead of an archive, which is detected from the download.
+	var rawBinaryPath, binaryFallbackPath string
+	if op.platform.RawBinary {
+		rawBinaryPath = op.platform.Bin
+	} else if op.platform.Files == nil {
+		binaryFallbackPath = op.platform.Bin
 	}
+	var fetcher download.Fetcher = opts.HTTPFetcher
+	if opts.ArchiveFileOverride != "" {
+		fetcher = download.NewFileFetcher(opts.ArchiveFileOverride)
+	} else if opts.DownloadCache != nil {
+		fetcher = opts.DownloadCache.Fetcher(fetcher, op.platform.Sha256)
+	}
+	if err := downloadAndExtract(downloadStagingDir, uri, op.platform.Sha256, fetcher, rawBinaryPath, binaryFallbackPath, opts.Progress); err != nil {
+		return "", errors.Wrap(err, "failed to unpack into staging dir")
+	}
+	opts.Progress.report(ProgressExtracted)
 
 	applyDefaults(&op.platform)
 	if err := moveToInstallDir(downloadStagingDir, op.installDir, op.platform.Files); err != nil {
-		return errors.Wrap(err, "failed while moving files to the installation directory")
+		return "", errors.Wrap(err, "failed while moving files to the installation directory")
 	}
 
 	subPathAbs, err := filepath.Abs(op.installDir)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get the absolute fullPath of %q", op.installDir)
+		return "", errors.Wrapf(err, "failed to get the absolute fullPath of %q", op.installDir)
 	}
 	fullPath := filepath.Join(op.installDir, filepath.FromSlash(op.platform.Bin))
 	pathAbs, err := filepath.Abs(fullPath)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get the absolute fullPath of %q", fullPath)
+		return "", errors.Wrapf(err, "failed to get the absolute fullPath of %q", fullPath)
 	}
 	if _, ok := pathutil.IsSubPath(subPathAbs, pathAbs); !ok {
-		return errors.Wrapf(err, "the fullPath %q does not extend the sub-fullPath %q", fullPath, op.installDir)
+		return "", errors.Wrapf(err, "the fullPath %q does not extend the sub-fullPath %q", fullPath, op.installDir)
+	}
+	if opts.VerifyRun || opts.RequireRun {
+		if err := verifyRun(pathAbs); err != nil {
+			if opts.RequireRun {
+				if rerr := os.RemoveAll(op.installDir); rerr != nil {
+					klog.Warningf("failed to clean up the installation directory: %v", rerr)
+				}
+				return "", errors.Wrapf(err, "installed plugin %s failed to run", op.pluginName)
+			}
+			klog.Warningf("Installed plugin %s failed to run, it may not work on this platform: %v", op.pluginName, err)
+		} else {
+			klog.Infof("Verified that plugin %s runs", op.pluginName)
+		}
 	}
-	err = createOrUpdateLink(op.binDir, fullPath, op.pluginName)
-	return errors.Wrap(err, "failed to link installed plugin")
+	if op.noLink {
+		return uri, nil
+	}
+	err = createOrUpdateLink(op.binDir, fullPath, op.binName)
+	return uri, errors.Wrap(err, "failed to link installed plugin")
+}
+
+// verifyRun runs the plugin executable at path with --help and returns an
+// error if it cannot be executed or exits with a failure.
+func verifyRun(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyRunTimeout)
+	defer cancel()
+	klog.V(2).Infof("Running %q to verify the installation", path)
+	out, err := exec.CommandContext(ctx, path, "--help").CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		klog.V(1).Infof("Plugin %q is still running after %s, assuming it runs", path, verifyRunTimeout)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "output=%q", lastLines(string(out), 5))
+	}
+	return nil
+}
+
+// lastLines returns up to n last lines of the output.
+func lastLines(out string, n int) string {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
 }
 
 func applyDefaults(platform *index.Platform) {
@@ -135,55 +388,273 @@ func applyDefaults(platform *index.Platform) {
 	}
 }
 
-// downloadAndExtract downloads the specified archive uri (or uses the provided overrideFile, if a non-empty value)
-// while validating its checksum with the provided sha256sum, and extracts its contents to extractDir that must be.
-// created.
-func downloadAndExtract(extractDir, uri, sha256sum, overrideFile string) error {
-	var fetcher download.Fetcher = download.HTTPFetcher{}
-	if overrideFile != "" {
-		fetcher = download.NewFileFetcher(overrideFile)
+// downloadAndExtract downloads the specified archive uri with the fetcher while validating its checksum with the
+// provided sha256sum, and extracts its contents to extractDir that must be created. If rawBinaryPath is non-empty,
+// the download is the plugin executable itself and it is placed at that path relative to extractDir instead. If
+// binaryFallbackPath is non-empty, the download is placed at that path only if it turns out to be an executable.
+// The download and its verification are reported to progress.
+func downloadAndExtract(extractDir, uri, sha256sum string, fetcher download.Fetcher, rawBinaryPath, binaryFallbackPath string, progress ProgressFunc) error {
+	verifier := progressVerifier{Verifier: download.NewSha256Verifier(sha256sum), progress: progress}
+	downloader := download.NewDownloader(verifier, fetcher)
+	if rawBinaryPath != "" {
+		err := downloader.GetBinary(uri, filepath.Join(extractDir, filepath.FromSlash(rawBinaryPath)))
+		return errors.Wrap(err, "failed to download the plugin binary")
 	}
-
-	verifier := download.NewSha256Verifier(sha256sum)
-	err := download.NewDownloader(verifier, fetcher).Get(uri, extractDir)
+	if binaryFallbackPath != "" {
+		err := downloader.GetArchiveOrBinary(uri, extractDir, filepath.Join(extractDir, filepath.FromSlash(binaryFallbackPath)))
+		return errors.Wrap(err, "failed to download the plugin")
+	}
+	err := downloader.Get(uri, extractDir)
 	return errors.Wrap(err, "failed to unpack the plugin archive")
 }
 
-// Uninstall will uninstall a plugin.
+// Uninstall will uninstall a plugin. A receipt kept by UninstallKeepReceipt
+// is removed.
 func Uninstall(p environment.Paths, name string) error {
+	_, err := uninstall(p, name, false, false)
+	return err
+}
+
+// UninstallKeepReceipt uninstalls a plugin like Uninstall, but keeps its
+// receipt marked as removed, so that Reinstall can install the same version
+// from the same source again.
+func UninstallKeepReceipt(p environment.Paths, name string) error {
+	_, err := uninstall(p, name, true, false)
+	return err
+}
+
+// UninstallPurge uninstalls a plugin like Uninstall, and also removes its
+// installation directory including the files the plugin created there, and the
+// downloads of the plugin and of its earlier versions from the cache. Cached
+// downloads that the receipts of other plugins refer to are kept. It returns
+// the number of cached downloads that were removed.
+func UninstallPurge(p environment.Paths, name string, cache *download.Cache) (int, error) {
+	installReceipt, err := uninstall(p, name, false, true)
+	if err != nil {
+		return 0, err
+	}
+	if cache == nil {
+		return 0, nil
+	}
+	return removeCachedDownloads(p, installReceipt, cache)
+}
+
+// removeCachedDownloads removes the downloads the receipt refers to from the
+// cache, except those that the receipts of other plugins refer to.
+func removeCachedDownloads(p environment.Paths, r index.Receipt, cache *download.Cache) (int, error) {
+	installed, err := ListInstalledPlugins(p.InstallReceiptsPath())
+	if err != nil {
+		return 0, err
+	}
+	kept, err := ListKeptReceipts(p.InstallReceiptsPath())
+	if err != nil {
+		return 0, err
+	}
+	shared := make(map[string]bool)
+	for _, other := range append(installed, kept...) {
+		if other.Name == r.Name {
+			continue
+		}
+		for sum := range receiptChecksums(other) {
+			shared[sum] = true
+		}
+	}
+
+	var n int
+	for sum := range receiptChecksums(r) {
+		if shared[sum] {
+			klog.V(2).Infof("Keeping cached download %s, other plugins use it", sum)
+			continue
+		}
+		removed, err := cache.Remove(sum)
+		if err != nil {
+			return n, err
+		}
+		if removed {
+			klog.V(3).Infof("Removed cached download %s of plugin %s", sum, r.Name)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// receiptChecksums returns the lower-case checksums of the downloads of all
+// platforms in the receipt, of the download it was installed from, and of the
+// downloads of earlier versions recorded in it.
+func receiptChecksums(r index.Receipt) map[string]bool {
+	sums := make(map[string]bool, len(r.Spec.Platforms)+1)
+	for _, platform := range r.Spec.Platforms {
+		if platform.Sha256 != "" {
+			sums[strings.ToLower(platform.Sha256)] = true
+		}
+	}
+	if r.Status.SHA256 != "" {
+		sums[strings.ToLower(r.Status.SHA256)] = true
+	}
+	for _, sum := range r.Status.PreviousSHA256 {
+		sums[strings.ToLower(sum)] = true
+	}
+	return sums
+}
+
+// findReceipt returns the receipt of the plugin installed as name, its plugin
+// name or the name it is invoked with.
+func findReceipt(p environment.Paths, name string) (index.Receipt, error) {
+	installReceipt, err := receipt.Load(p.PluginInstallReceiptPath(name))
+	if os.IsNotExist(err) {
+		installReceipt, err = receiptForBinName(p, name)
+		if err != nil {
+			return index.Receipt{}, err
+		}
+		klog.V(1).Infof("Plugin %s is installed as %s", installReceipt.Name, name)
+		return installReceipt, nil
+	}
+	return installReceipt, errors.Wrapf(err, "failed to look up install receipt for plugin %q", name)
+}
+
+// uninstall removes the installation of the plugin and returns its receipt.
+func uninstall(p environment.Paths, name string, keepReceipt, purge bool) (index.Receipt, error) {
 	if name == constants.KrewPluginName {
 		klog.Errorf("Removing krew through krew is not supported.")
 		if !IsWindows() { // assume POSIX-like
 			klog.Errorf("If you’d like to uninstall krew altogether, run:\n\trm -rf -- %q", p.BasePath())
 		}
-		return errors.New("self-uninstall not allowed")
+		return index.Receipt{}, errors.New("self-uninstall not allowed")
 	}
 	klog.V(3).Infof("Finding installed version to delete")
 
-	if _, err := receipt.Load(p.PluginInstallReceiptPath(name)); err != nil {
+	lock, err := lockPlugin(p, name)
+	if err != nil {
+		return index.Receipt{}, err
+	}
+	defer func() { lock.Unlock() }()
+	installReceipt, err := findReceipt(p, name)
+	if err != nil {
+		return index.Receipt{}, err
+	}
+	if installReceipt.Name != name {
+		// name is the bin name, the lock is held for the plugin name
+		lock.Unlock()
+		name = installReceipt.Name
+		if lock, err = lockPlugin(p, name); err != nil {
+			return index.Receipt{}, err
+		}
+		installReceipt, err = receipt.Load(p.PluginInstallReceiptPath(name))
 		if os.IsNotExist(err) {
-			return ErrIsNotInstalled
+			// uninstalled by another process meanwhile
+			return index.Receipt{}, ErrIsNotInstalled
+		} else if err != nil {
+			return index.Receipt{}, errors.Wrapf(err, "failed to look up install receipt for plugin %q", name)
 		}
-		return errors.Wrapf(err, "failed to look up install receipt for plugin %q", name)
+	}
+	if keepReceipt && installReceipt.Status.Removed {
+		return index.Receipt{}, ErrIsNotInstalled
+	}
+	if keepReceipt && installReceipt.Status.DevLink != "" {
+		return index.Receipt{}, ErrIsDevLinked
 	}
 
 	klog.V(1).Infof("Deleting plugin %s", name)
 
-	symlinkPath := filepath.Join(p.BinPath(), pluginNameToBin(name, IsWindows()))
+	symlinkPath := filepath.Join(p.BinPath(), pluginNameToBin(BinName(installReceipt), IsWindows()))
 	klog.V(3).Infof("Unlink %q", symlinkPath)
 	if err := removeLink(symlinkPath); err != nil {
-		return errors.Wrap(err, "could not uninstall symlink of plugin")
+		return index.Receipt{}, errors.Wrap(err, "could not uninstall symlink of plugin")
 	}
 
-	pluginInstallPath := p.PluginInstallPath(name)
-	klog.V(3).Infof("Deleting path %q", pluginInstallPath)
-	if err := os.RemoveAll(pluginInstallPath); err != nil {
-		return errors.Wrapf(err, "could not remove plugin directory %q", pluginInstallPath)
+	if err := removeInstalledFiles(p, installReceipt); err != nil {
+		return index.Receipt{}, err
+	}
+	if purge && installReceipt.Status.DevLink == "" {
+		pluginInstallPath := p.PluginInstallPath(name)
+		klog.V(3).Infof("Purging path %q", pluginInstallPath)
+		if err := os.RemoveAll(pluginInstallPath); err != nil {
+			return index.Receipt{}, errors.Wrapf(err, "could not remove plugin directory %q", pluginInstallPath)
+		}
 	}
 	pluginReceiptPath := p.PluginInstallReceiptPath(name)
+	if keepReceipt {
+		klog.V(3).Infof("Marking plugin receipt %q as removed", pluginReceiptPath)
+		installReceipt.Status.Removed = true
+		err = receipt.Store(installReceipt, pluginReceiptPath)
+		return installReceipt, errors.Wrapf(err, "could not update plugin receipt %q", pluginReceiptPath)
+	}
 	klog.V(3).Infof("Deleting plugin receipt %q", pluginReceiptPath)
-	err := os.Remove(pluginReceiptPath)
-	return errors.Wrapf(err, "could not remove plugin receipt %q", pluginReceiptPath)
+	err = os.Remove(pluginReceiptPath)
+	return installReceipt, errors.Wrapf(err, "could not remove plugin receipt %q", pluginReceiptPath)
+}
+
+// removeInstalledFiles removes the files recorded in the receipt and the
+// directories they leave empty. Other files in the installation directory are
+// kept. For receipts that don't record the files, the whole installation
+// directory of the plugin is removed. Dev links have no installed files.
+func removeInstalledFiles(p environment.Paths, r index.Receipt) error {
+	if r.Status.DevLink != "" {
+		return nil
+	}
+	if r.Status.Files == nil {
+		pluginInstallPath := p.PluginInstallPath(r.Name)
+		klog.V(3).Infof("Receipt does not record the installed files, deleting path %q", pluginInstallPath)
+		if err := os.RemoveAll(pluginInstallPath); err != nil {
+			return errors.Wrapf(err, "could not remove plugin directory %q", pluginInstallPath)
+		}
+		return errors.Wrap(removeEmptyParents(pluginInstallPath, p.InstallPath()), "could not clean up empty directories")
+	}
+
+	installDir := p.PluginVersionInstallPath(r.Name, r.Spec.Version)
+	for _, f := range r.Status.Files {
+		path := filepath.Join(installDir, filepath.FromSlash(f))
+		if rel, ok := pathutil.IsSubPath(installDir, path); !ok || rel == "." {
+			klog.Warningf("Not removing %q recorded in the receipt, it is not in %q", f, installDir)
+			continue
+		}
+		klog.V(3).Infof("Deleting file %q", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not remove plugin file %q", path)
+		}
+		if err := removeEmptyParents(path, p.InstallPath()); err != nil {
+			return errors.Wrap(err, "could not clean up empty directories")
+		}
+	}
+	return errors.Wrap(removeEmptyDirs(installDir, p.InstallPath()), "could not clean up empty directories")
+}
+
+// Reinstall installs a plugin uninstalled with UninstallKeepReceipt again, at
+// the version and from the source recorded in its kept receipt. It returns
+// ErrIsAlreadyInstalled if the plugin is installed, and ErrNoKeptReceipt if
+// there is no receipt for it.
+func Reinstall(p environment.Paths, name string, opts InstallOpts) error {
+	r, err := receipt.Load(p.PluginInstallReceiptPath(name))
+	if os.IsNotExist(err) {
+		return ErrNoKeptReceipt
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to look up install receipt for plugin %q", name)
+	}
+	if !r.Status.Removed {
+		return ErrIsAlreadyInstalled
+	}
+	opts.BinName = r.Status.BinName
+	opts.Channel = r.Status.Source.Channel
+	indexName := receipt.SourceIndexName(r)
+	if receipt.IsCustomManifest(r) {
+		indexName = ""
+	}
+	return Install(p, r.Plugin, indexName, opts)
+}
+
+// receiptForBinName returns the receipt of the plugin that is invoked as
+// binName, or ErrIsNotInstalled if there is none.
+func receiptForBinName(p environment.Paths, binName string) (index.Receipt, error) {
+	receipts, err := ListInstalledPlugins(p.InstallReceiptsPath())
+	if err != nil {
+		return index.Receipt{}, err
+	}
+	for _, r := range receipts {
+		if r.Status.BinName != "" && r.Status.BinName == binName {
+			return r, nil
+		}
+	}
+	return index.Receipt{}, ErrIsNotInstalled
 }
 
 func createOrUpdateLink(binDir, binary, plugin string) error {
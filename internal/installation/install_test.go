@@ -15,6 +15,8 @@
 package installation
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -23,12 +25,17 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	"sigs.k8s.io/krew/internal/download"
 	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
 	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
 
@@ -153,6 +160,476 @@ func TestUninstall_cantUninstallItself(t *testing.T) {
 	}
 }
 
+func Test_ensureBinNameAvailable(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	foo := receipt.New(testutil.NewPlugin().WithName("foo").V(), constants.DefaultIndexName)
+	bar := receipt.New(testutil.NewPlugin().WithName("bar").V(), constants.DefaultIndexName)
+	bar.Status.BinName = "bar-alt"
+	for _, r := range []index.Receipt{foo, bar} {
+		if err := receipt.Store(r, p.PluginInstallReceiptPath(r.Name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pluginName, binName string
+		wantErr             bool
+	}{
+		{pluginName: "baz", binName: "baz"},
+		{pluginName: "foo", binName: "foo"},
+		{pluginName: "baz", binName: "foo", wantErr: true},
+		{pluginName: "baz", binName: "bar-alt", wantErr: true},
+		{pluginName: "baz", binName: "bar_alt", wantErr: true},
+		{pluginName: "baz", binName: "bar"},
+	}
+	for _, tt := range tests {
+		err := ensureBinNameAvailable(p, tt.pluginName, tt.binName)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ensureBinNameAvailable(%q, %q) error = %v, wantErr %v", tt.pluginName, tt.binName, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBinName(t *testing.T) {
+	r := receipt.New(testutil.NewPlugin().WithName("foo").V(), constants.DefaultIndexName)
+	if got := BinName(r); got != "foo" {
+		t.Errorf("BinName() of receipt without bin name = %q, want %q", got, "foo")
+	}
+	r.Status.BinName = "foo2"
+	if got := BinName(r); got != "foo2" {
+		t.Errorf("BinName() = %q, want %q", got, "foo2")
+	}
+}
+
+func TestUninstall_keepsOtherPlugins(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		plugin := testutil.NewPlugin().WithName(name).WithVersion("v1.0.0").V()
+		if err := receipt.Store(receipt.New(plugin, constants.DefaultIndexName), p.PluginInstallReceiptPath(name)); err != nil {
+			t.Fatal(err)
+		}
+		tempDir.Write(filepath.Join("store", name, "v1.0.0", "kubectl-"+name), nil)
+	}
+
+	if err := Uninstall(p, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p.PluginInstallPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("expected install dir of uninstalled plugin to be removed, got err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(p.PluginVersionInstallPath("bar", "v1.0.0"), "kubectl-bar")); err != nil {
+		t.Errorf("expected files of other plugin to be kept: %v", err)
+	}
+	if _, err := os.Stat(p.InstallPath()); err != nil {
+		t.Errorf("expected install root to be kept: %v", err)
+	}
+}
+
+func TestUninstall_removesRecordedFiles(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := receipt.New(testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").V(), constants.DefaultIndexName)
+	r.Status.Files = []string{"kubectl-foo", "lib/foo.so", "../../bar/v1.0.0/kubectl-bar"}
+	if err := receipt.Store(r, p.PluginInstallReceiptPath("foo")); err != nil {
+		t.Fatal(err)
+	}
+	tempDir.Write(filepath.Join("store", "foo", "v1.0.0", "kubectl-foo"), nil)
+	tempDir.Write(filepath.Join("store", "foo", "v1.0.0", "lib", "foo.so"), nil)
+	tempDir.Write(filepath.Join("store", "foo", "v1.0.0", "data", "created-by-plugin"), nil)
+	tempDir.Write(filepath.Join("store", "bar", "v1.0.0", "kubectl-bar"), nil)
+
+	if err := Uninstall(p, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	installDir := p.PluginVersionInstallPath("foo", "v1.0.0")
+	for _, f := range []string{"kubectl-foo", "lib"} {
+		if _, err := os.Stat(filepath.Join(installDir, f)); !os.IsNotExist(err) {
+			t.Errorf("expected recorded %q to be removed, got err=%v", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "data", "created-by-plugin")); err != nil {
+		t.Errorf("expected file that is not recorded to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(p.PluginVersionInstallPath("bar", "v1.0.0"), "kubectl-bar")); err != nil {
+		t.Errorf("expected recorded file outside the install dir to be kept: %v", err)
+	}
+}
+
+func TestUninstall_prunesEmptyPluginDir(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"legacy":   nil,
+		"recorded": {"kubectl-foo", "lib/foo.so"},
+		"empty":    {},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := receipt.New(testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").V(), constants.DefaultIndexName)
+			r.Status.Files = files
+			if err := receipt.Store(r, p.PluginInstallReceiptPath("foo")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(p.PluginVersionInstallPath("foo", "v1.0.0"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				tempDir.Write(filepath.Join("store", "foo", "v1.0.0", filepath.FromSlash(f)), nil)
+			}
+			if files == nil {
+				// legacy installs may leave older versions behind
+				tempDir.Write(filepath.Join("store", "foo", "v0.9.0", "kubectl-foo"), nil)
+			}
+			tempDir.Write(filepath.Join("store", "bar", "v1.0.0", "kubectl-bar"), nil)
+
+			if err := Uninstall(p, "foo"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(p.PluginInstallPath("foo")); !os.IsNotExist(err) {
+				t.Errorf("expected the empty plugin directory to be removed, got err=%v", err)
+			}
+			if _, err := os.Stat(filepath.Join(p.PluginVersionInstallPath("bar", "v1.0.0"), "kubectl-bar")); err != nil {
+				t.Errorf("expected files of other plugin to be kept: %v", err)
+			}
+		})
+	}
+}
+
+func TestUninstall_byBinName(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := receipt.New(testutil.NewPlugin().WithName("ctx").WithVersion("v1.0.0").V(), constants.DefaultIndexName)
+	r.Status.BinName = "ictx"
+	if err := receipt.Store(r, p.PluginInstallReceiptPath("ctx")); err != nil {
+		t.Fatal(err)
+	}
+	tempDir.Write(filepath.Join("store", "ctx", "v1.0.0", "kubectl-ctx"), nil)
+
+	if err := Uninstall(p, "other"); err != ErrIsNotInstalled {
+		t.Fatalf("Uninstall() of unknown name = %v, want %v", err, ErrIsNotInstalled)
+	}
+	if err := Uninstall(p, "ictx"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p.PluginInstallReceiptPath("ctx")); !os.IsNotExist(err) {
+		t.Errorf("expected receipt of plugin uninstalled by bin name to be removed, got err=%v", err)
+	}
+}
+
+func TestUninstallPurge(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	own, shared, unrelated, earlier := strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64), strings.Repeat("d", 64)
+	foo := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(
+		testutil.NewPlatform().WithOS("linux").WithSHA256(own).V(),
+		testutil.NewPlatform().WithOS("darwin").WithSHA256(shared).V()).V()
+	r := receipt.New(foo, constants.DefaultIndexName)
+	r.Status.Files = []string{"kubectl-foo"}
+	r.Status.PreviousSHA256 = []string{earlier}
+	if err := receipt.Store(r, p.PluginInstallReceiptPath("foo")); err != nil {
+		t.Fatal(err)
+	}
+	bar := testutil.NewPlugin().WithName("bar").WithVersion("v1.0.0").WithPlatforms(
+		testutil.NewPlatform().WithSHA256(shared).V()).V()
+	if err := receipt.Store(receipt.New(bar, constants.DefaultIndexName), p.PluginInstallReceiptPath("bar")); err != nil {
+		t.Fatal(err)
+	}
+	tempDir.Write(filepath.Join("store", "foo", "v1.0.0", "kubectl-foo"), nil)
+	tempDir.Write(filepath.Join("store", "foo", "v1.0.0", "data", "created-by-plugin"), nil)
+	cache := download.NewCache(p.DownloadCachePath(), 0)
+	for _, sum := range []string{own, shared, unrelated, earlier} {
+		tempDir.Write(filepath.Join("downloads", sum), []byte(sum))
+	}
+
+	n, err := UninstallPurge(p, "foo", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("UninstallPurge() removed %d cached downloads, expected 2", n)
+	}
+	if cache.Has(own) || cache.Has(earlier) {
+		t.Error("expected the cached downloads of the plugin and its earlier versions to be removed")
+	}
+	if !cache.Has(shared) || !cache.Has(unrelated) {
+		t.Error("expected cached downloads that are not only used by the plugin to be kept")
+	}
+	if _, err := os.Stat(p.PluginInstallPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the whole install dir to be removed, got err=%v", err)
+	}
+	if _, err := os.Stat(p.PluginInstallReceiptPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the receipt to be removed, got err=%v", err)
+	}
+	if _, err := UninstallPurge(p, "foo", cache); err != ErrIsNotInstalled {
+		t.Errorf("UninstallPurge() of uninstalled plugin = %v, want %v", err, ErrIsNotInstalled)
+	}
+}
+
+func TestUninstallKeepReceipt_reinstall(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+			WithRawBinary(true).WithBin("kubectl-foo").WithFiles(nil).
+			WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()).V()
+	opts := InstallOpts{ArchiveFileOverride: testFile}
+	if err := Install(p, plugin, constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: testFile, BinName: "foo2"}); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(p.BinPath(), pluginNameToBin("foo2", IsWindows()))
+
+	if err := UninstallKeepReceipt(p, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("expected the link to be removed, got err=%v", err)
+	}
+	if _, err := os.Stat(p.PluginInstallPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the install dir to be removed, got err=%v", err)
+	}
+	if installed, err := ListInstalledPlugins(p.InstallReceiptsPath()); err != nil {
+		t.Fatal(err)
+	} else if len(installed) != 0 {
+		t.Errorf("expected no installed plugins, got %d", len(installed))
+	}
+	if kept, err := ListKeptReceipts(p.InstallReceiptsPath()); err != nil {
+		t.Fatal(err)
+	} else if len(kept) != 1 || kept[0].Name != "foo" {
+		t.Errorf("expected the kept receipt of foo, got %v", kept)
+	}
+	if err := UninstallKeepReceipt(p, "foo"); err != ErrIsNotInstalled {
+		t.Errorf("UninstallKeepReceipt() of removed plugin = %v, want %v", err, ErrIsNotInstalled)
+	}
+
+	if err := Reinstall(p, "other", opts); err != ErrNoKeptReceipt {
+		t.Errorf("Reinstall() of unknown plugin = %v, want %v", err, ErrNoKeptReceipt)
+	}
+	if err := Reinstall(p, "foo", opts); err != nil {
+		t.Fatal(err)
+	}
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status.Removed || BinName(r) != "foo2" || r.Spec.Version != "v1.0.0" {
+		t.Errorf("unexpected receipt after reinstall: removed=%v binName=%q version=%q", r.Status.Removed, BinName(r), r.Spec.Version)
+	}
+	if _, err := os.Lstat(link); err != nil {
+		t.Errorf("expected the link to be restored: %v", err)
+	}
+	if err := Reinstall(p, "foo", opts); err != ErrIsAlreadyInstalled {
+		t.Errorf("Reinstall() of installed plugin = %v, want %v", err, ErrIsAlreadyInstalled)
+	}
+}
+
+func TestInstall_offline(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := testutil.NewPlugin().WithName("foo").WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).V()).V()
+	err := Install(p, plugin, constants.DefaultIndexName, InstallOpts{Offline: true})
+	if err == nil || !strings.Contains(err.Error(), "offline mode") {
+		t.Fatalf("expected install to be blocked by offline mode, got: %v", err)
+	}
+	if _, err := os.Stat(p.PluginInstallReceiptPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("expected no receipt to be written, got err=%v", err)
+	}
+}
+
+func TestInstall_offlineFromCache(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checksum := "2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f"
+	b, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempDir.Write(filepath.Join("downloads", checksum), b)
+
+	plugin := testutil.NewPlugin().WithName("foo").WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+			WithRawBinary(true).WithBin("kubectl-foo").WithFiles(nil).WithSHA256(checksum).V()).V()
+	opts := InstallOpts{Offline: true, DownloadCache: download.NewCache(p.DownloadCachePath(), 0)}
+	if err := Install(p, plugin, constants.DefaultIndexName, opts); err != nil {
+		t.Fatalf("expected the install from the download cache to work offline: %v", err)
+	}
+}
+
+func TestInstall_recordsResolvedURI(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	plugin := testutil.NewPlugin().WithName("foo").WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+			WithURI("https://example.com/foo-${KREW_OS}.tar.gz").
+			WithRawBinary(true).WithBin("kubectl-foo").WithFiles(nil).
+			WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()).V()
+	opts := InstallOpts{
+		ArchiveFileOverride: testFile,
+		DownloadHosts:       map[string]string{"example.com": "mirror.example.com"},
+	}
+	if err := Install(p, plugin, constants.DefaultIndexName, opts); err != nil {
+		t.Fatal(err)
+	}
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://mirror.example.com/foo-" + runtime.GOOS + ".tar.gz"; r.Status.URI != want {
+		t.Errorf("receipt records uri %q, want %q", r.Status.URI, want)
+	}
+}
+
+func Test_verifyRun(t *testing.T) {
+	if IsWindows() {
+		t.Skip("uses shell scripts")
+	}
+	defer func(orig time.Duration) { verifyRunTimeout = orig }(verifyRunTimeout)
+	verifyRunTimeout = time.Second
+
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	scripts := map[string]string{
+		"ok":    "#!/bin/sh\necho usage\n",
+		"fails": "#!/bin/sh\necho line1\necho cannot run >&2\nexit 1\n",
+		"slow":  "#!/bin/sh\nexec sleep 5\n",
+	}
+	for name, content := range scripts {
+		tempDir.Write(name, []byte(content))
+		if err := os.Chmod(tempDir.Path(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tempDir.Write("notexecutable", []byte("garbage"))
+
+	if err := verifyRun(tempDir.Path("ok")); err != nil {
+		t.Errorf("expected no error for a plugin that runs, got: %v", err)
+	}
+	if err := verifyRun(tempDir.Path("slow")); err != nil {
+		t.Errorf("expected no error for a plugin that keeps running, got: %v", err)
+	}
+	err := verifyRun(tempDir.Path("fails"))
+	if err == nil || !strings.Contains(err.Error(), "cannot run") {
+		t.Errorf("expected error with the output of the failing plugin, got: %v", err)
+	}
+	if err := verifyRun(tempDir.Path("notexecutable")); err == nil {
+		t.Error("expected error for a file that cannot be executed")
+	}
+}
+
+func Test_lastLines(t *testing.T) {
+	if got := lastLines("a\nb\nc\n", 2); got != "b\nc" {
+		t.Errorf("lastLines() = %q, want %q", got, "b\nc")
+	}
+	if got := lastLines("a\n", 2); got != "a" {
+		t.Errorf("lastLines() = %q, want %q", got, "a")
+	}
+}
+
+func TestInstall_concurrent(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+		WithRawBinary(true).WithBin("kubectl-x").WithFiles(nil).
+		WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()
+	opts := InstallOpts{ArchiveFileOverride: testFile}
+
+	// two concurrent installs of each plugin
+	names := []string{"a", "b", "c", "a", "b", "c"}
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			plugin := testutil.NewPlugin().WithName(name).WithPlatforms(platform).V()
+			errs[i] = Install(p, plugin, constants.DefaultIndexName, opts)
+		}(i, name)
+	}
+	wg.Wait()
+
+	installed := make(map[string]int)
+	for i, err := range errs {
+		switch err {
+		case nil:
+			installed[names[i]]++
+		case ErrIsAlreadyInstalled:
+		default:
+			t.Errorf("installing plugin %s failed: %v", names[i], err)
+		}
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if installed[name] != 1 {
+			t.Errorf("expected plugin %s to be installed once, got %d", name, installed[name])
+		}
+		if _, err := receipt.Load(p.PluginInstallReceiptPath(name)); err != nil {
+			t.Errorf("expected a receipt for plugin %s: %v", name, err)
+		}
+	}
+}
+
 func Test_removeLink_linkExists(t *testing.T) {
 	tmpDir, cleanup := testutil.NewTempDir(t)
 	defer cleanup()
@@ -231,7 +708,7 @@ func Test_downloadAndExtract(t *testing.T) {
 	url := server.URL + "/test-without-directory.tar.gz"
 	checksum := "433b9e0b6cb9f064548f451150799daadcc70a3496953490c5148c8e550d2f4e"
 
-	if err := downloadAndExtract(tmpDir.Root(), url, checksum, ""); err != nil {
+	if err := downloadAndExtract(tmpDir.Root(), url, checksum, download.HTTPFetcher{}, "", "", nil); err != nil {
 		t.Fatal(err)
 	}
 	files, err := ioutil.ReadDir(tmpDir.Root())
@@ -250,7 +727,7 @@ func Test_downloadAndExtract_fileOverride(t *testing.T) {
 	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "test-without-directory.tar.gz")
 	checksum := "433b9e0b6cb9f064548f451150799daadcc70a3496953490c5148c8e550d2f4e"
 
-	if err := downloadAndExtract(tmpDir.Root(), "", checksum, testFile); err != nil {
+	if err := downloadAndExtract(tmpDir.Root(), "", checksum, download.NewFileFetcher(testFile), "", "", nil); err != nil {
 		t.Fatal(err)
 	}
 	files, err := ioutil.ReadDir(tmpDir.Root())
@@ -262,6 +739,73 @@ func Test_downloadAndExtract_fileOverride(t *testing.T) {
 	}
 }
 
+func Test_downloadAndExtract_progress(t *testing.T) {
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "test-without-directory.tar.gz")
+	tests := []struct {
+		name     string
+		checksum string
+		expected []string
+	}{
+		{
+			name:     "verified",
+			checksum: "433b9e0b6cb9f064548f451150799daadcc70a3496953490c5148c8e550d2f4e",
+			expected: []string{ProgressDownloaded, ProgressVerified},
+		},
+		{
+			name:     "checksum mismatch",
+			checksum: strings.Repeat("0", 64),
+			expected: []string{ProgressDownloaded},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			var events []string
+			progress := func(event string) { events = append(events, event) }
+			_ = downloadAndExtract(tmpDir.Root(), "", tt.checksum, download.NewFileFetcher(testFile), "", "", progress)
+			if diff := cmp.Diff(tt.expected, events); diff != "" {
+				t.Errorf("progress events mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_downloadAndExtract_rawBinary(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	checksum := "2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f"
+
+	if err := downloadAndExtract(tmpDir.Root(), "", checksum, download.NewFileFetcher(testFile), "bin/kubectl-foo", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("bin/kubectl-foo")); err != nil {
+		t.Fatalf("raw binary was not placed at the bin path: %v", err)
+	}
+}
+
+func Test_downloadAndExtract_detectsBinary(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "raw-elf")
+	b, err := ioutil.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("%x", sha256.Sum256(b))
+
+	if err := downloadAndExtract(tmpDir.Root(), "", checksum, download.NewFileFetcher(testFile), "", "bin/kubectl-foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("bin/kubectl-foo")); err != nil {
+		t.Fatalf("detected binary was not placed at the bin path: %v", err)
+	}
+}
+
 func Test_applyDefaults(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,80 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// Integrity statuses of an installed plugin.
+const (
+	IntegrityOK       = "ok"
+	IntegrityMismatch = "mismatch"
+	// IntegrityUnknown is the status of plugins whose receipt records no
+	// checksums, and of plugins linked to a development build.
+	IntegrityUnknown = "unknown"
+)
+
+// IntegrityResult is the outcome of checking the installed files of a plugin
+// against the checksums recorded in its receipt.
+type IntegrityResult struct {
+	Status string
+	// Problems describe the files that are missing or were modified, or why
+	// the status is unknown.
+	Problems []string
+}
+
+// CheckIntegrity checks that the files recorded in the receipt are installed
+// with the recorded checksums. Files that were added to the installation
+// directory after the plugin was installed are not reported.
+func CheckIntegrity(p environment.Paths, r index.Receipt) IntegrityResult {
+	if r.Status.DevLink != "" {
+		return IntegrityResult{Status: IntegrityUnknown, Problems: []string{"linked to a development build"}}
+	}
+	if len(r.Status.Checksums) == 0 {
+		return IntegrityResult{Status: IntegrityUnknown, Problems: []string{"no checksums recorded, reinstall the plugin to record them"}}
+	}
+
+	files := make([]string, 0, len(r.Status.Checksums))
+	for f := range r.Status.Checksums {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	installDir := p.PluginVersionInstallPath(r.Name, r.Spec.Version)
+	var problems []string
+	for _, f := range files {
+		sum, err := fileSha256(filepath.Join(installDir, filepath.FromSlash(f)))
+		switch {
+		case err != nil && os.IsNotExist(errors.Cause(err)):
+			problems = append(problems, fmt.Sprintf("file %q is missing", f))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("file %q cannot be read: %v", f, err))
+		case sum != r.Status.Checksums[f]:
+			problems = append(problems, fmt.Sprintf("file %q was modified", f))
+		}
+	}
+	if len(problems) > 0 {
+		return IntegrityResult{Status: IntegrityMismatch, Problems: problems}
+	}
+	return IntegrityResult{Status: IntegrityOK}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "kubectl-foo"), []byte("binary"))
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "doc", "README"), []byte("readme"))
+	r := receipt.New(testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").V(), constants.DefaultIndexName)
+	recordFiles(&r.Status, p.PluginVersionInstallPath("foo", "v1.0.0"))
+	if len(r.Status.Checksums) != 2 {
+		t.Fatalf("expected checksums of 2 files to be recorded, got %v", r.Status.Checksums)
+	}
+
+	if got := CheckIntegrity(p, r); got.Status != IntegrityOK {
+		t.Errorf("expected the unchanged installation to be ok, got %+v", got)
+	}
+
+	// files added after installing are not reported
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "config"), []byte("config"))
+	if got := CheckIntegrity(p, r); got.Status != IntegrityOK {
+		t.Errorf("expected an added file not to be reported, got %+v", got)
+	}
+
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "kubectl-foo"), []byte("modified"))
+	if err := os.Remove(tmpDir.Path(filepath.Join("store", "foo", "v1.0.0", "doc", "README"))); err != nil {
+		t.Fatal(err)
+	}
+	got := CheckIntegrity(p, r)
+	if got.Status != IntegrityMismatch || len(got.Problems) != 2 {
+		t.Errorf("expected a mismatch for the modified and the missing file, got %+v", got)
+	}
+
+	r.Status.Checksums = nil
+	if got := CheckIntegrity(p, r); got.Status != IntegrityUnknown {
+		t.Errorf("expected the status to be unknown without checksums, got %+v", got)
+	}
+	r.Status.DevLink = "/src/kubectl-foo"
+	if got := CheckIntegrity(p, r); got.Status != IntegrityUnknown {
+		t.Errorf("expected the status of a development build to be unknown, got %+v", got)
+	}
+}
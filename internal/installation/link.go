@@ -0,0 +1,143 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/index/validation"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// DevLinkVersion is the version recorded for plugins linked to a development
+// build.
+const DevLinkVersion = "v0.0.0-dev"
+
+// ErrIsDevLinked indicates that the plugin is linked to a development build
+// and is not managed by krew.
+var ErrIsDevLinked = errors.New("plugin is linked to a development build")
+
+// PluginNameFromBinary returns the plugin name for a plugin executable such as
+// "kubectl-foo_bar" or "kubectl-foo_bar.exe" ("foo-bar").
+func PluginNameFromBinary(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".exe")
+	name = strings.TrimPrefix(name, "kubectl-")
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+// LinkDev installs the plugin by linking its bin symlink directly to the
+// developer-provided binary, so that rebuilding the binary doesn't require a
+// reinstall. The receipt records the linked binary, such plugins are not
+// upgraded and uninstalling them only removes the symlink.
+func LinkDev(p environment.Paths, name, binary string) error {
+	if !validation.IsSafePluginName(name) {
+		return errors.Errorf("plugin name %q is not valid", name)
+	}
+	lock, err := lockPlugin(p, name)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	klog.V(2).Infof("Looking for installed versions")
+	if r, err := receipt.Load(p.PluginInstallReceiptPath(name)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to look up plugin receipt")
+	} else if err == nil && !r.Status.Removed {
+		return ErrIsAlreadyInstalled
+	}
+	if err := ensureBinNameAvailable(p, name, name); err != nil {
+		return err
+	}
+
+	binary, err = filepath.Abs(binary)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the absolute path of %q", binary)
+	}
+	fi, err := os.Stat(binary)
+	if err != nil {
+		return errors.Wrapf(err, "cannot use %q as the plugin binary", binary)
+	}
+	if fi.IsDir() {
+		return errors.Errorf("plugin binary %q is a directory", binary)
+	}
+	sum, err := fileSha256(binary)
+	if err != nil {
+		return err
+	}
+
+	klog.V(3).Infof("Linking plugin %s to development build %q", name, binary)
+	if err := createOrUpdateLink(p.BinPath(), binary, name); err != nil {
+		return errors.Wrap(err, "failed to link development build")
+	}
+
+	r := receipt.New(devLinkPlugin(name, binary, sum), "")
+	r.Status.DevLink = binary
+	now := metav1.Now()
+	r.Status.InstalledAt = &now
+	err = receipt.Store(r, p.PluginInstallReceiptPath(name))
+	return errors.Wrap(err, "installation receipt could not be stored, uninstall may fail")
+}
+
+// devLinkPlugin returns a plugin manifest describing the linked binary on the
+// current platform.
+func devLinkPlugin(name, binary, sha256sum string) index.Plugin {
+	osArch := OSArch()
+	return index.Plugin{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: constants.CurrentAPIVersion,
+			Kind:       constants.PluginKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: index.PluginSpec{
+			Version:          DevLinkVersion,
+			ShortDescription: "Development build linked from " + binary,
+			Platforms: []index.Platform{{
+				URI:    "file://" + filepath.ToSlash(binary),
+				Sha256: sha256sum,
+				Bin:    filepath.Base(binary),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{
+					"os":   osArch.OS,
+					"arch": osArch.Arch,
+				}},
+			}},
+		},
+	}
+}
+
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open %q", path)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "failed to compute the checksum of %q", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+)
+
+func TestPluginNameFromBinary(t *testing.T) {
+	tests := map[string]string{
+		"kubectl-foo":              "foo",
+		"./dist/kubectl-foo_bar":   "foo-bar",
+		"dist/kubectl-foo.exe":     "foo",
+		"/abs/path/without-prefix": "without-prefix",
+	}
+	for in, want := range tests {
+		if got := PluginNameFromBinary(in); got != want {
+			t.Errorf("PluginNameFromBinary(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLinkDev(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Path("root"))
+	for _, dir := range []string{p.BinPath(), p.InstallReceiptsPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binary := tmpDir.Write("dist/kubectl-foo", []byte("#!/bin/sh")).Path("dist/kubectl-foo")
+
+	if err := LinkDev(p, "foo", binary); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(p.BinPath(), pluginNameToBin("foo", IsWindows()))
+	got, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != binary {
+		t.Errorf("link points to %q, expected the development build %q", got, binary)
+	}
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatalf("failed to load receipt of linked plugin: %v", err)
+	}
+	if r.Status.DevLink != binary {
+		t.Errorf("receipt DevLink = %q, expected %q", r.Status.DevLink, binary)
+	}
+
+	if err := LinkDev(p, "foo", binary); err != ErrIsAlreadyInstalled {
+		t.Errorf("expected ErrIsAlreadyInstalled for second link, got %v", err)
+	}
+	if err := Upgrade(p, testutil.NewPlugin().WithName("foo").WithVersion("v9.0.0").V(), InstallOpts{}); err != ErrIsDevLinked {
+		t.Errorf("expected ErrIsDevLinked on upgrade, got %v", err)
+	}
+
+	if err := Uninstall(p, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("expected link to be removed, got err=%v", err)
+	}
+	if _, err := os.Stat(binary); err != nil {
+		t.Errorf("expected development build to be kept: %v", err)
+	}
+}
+
+func TestLinkDev_invalid(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Path("root"))
+	tmpDir.Write("dist/kubectl-foo", nil)
+
+	if err := LinkDev(p, "foo", tmpDir.Path("dist")); err == nil {
+		t.Error("expected error linking a directory")
+	}
+	if err := LinkDev(p, "foo", tmpDir.Path("dist/does-not-exist")); err == nil {
+		t.Error("expected error linking a missing binary")
+	}
+	if err := LinkDev(p, "../foo", tmpDir.Path("dist/kubectl-foo")); err == nil {
+		t.Error("expected error for unsafe plugin name")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -32,7 +33,16 @@ type move struct {
 	from, to string
 }
 
+// findMoveTargets resolves the file operation to the moves from fromDir to
+// toDir. If From is the path of a file or directory, it is renamed to To, or
+// moved into To if it ends with a "/". If From is a glob pattern, the matches
+// are always moved into the To directory, however many files match.
 func findMoveTargets(fromDir, toDir string, fo index.FileOperation) ([]move, error) {
+	// a trailing slash makes To a directory to move the files into
+	intoDir := strings.HasSuffix(fo.To, "/") && fo.To != "/"
+	if intoDir {
+		fo.To = strings.TrimSuffix(fo.To, "/")
+	}
 	if fo.To != filepath.Clean(fo.To) {
 		return nil, errors.Errorf("the provided path is not clean, %q should be %q", fo.To, filepath.Clean(fo.To))
 	}
@@ -42,7 +52,7 @@ func findMoveTargets(fromDir, toDir string, fo index.FileOperation) ([]move, err
 	}
 
 	klog.V(4).Infof("Trying to move single file directly from=%q to=%q with file operation=%#v", fromDir, toDir, fo)
-	if m, ok, err := getDirectMove(fromDir, toDir, fo); err != nil {
+	if m, ok, err := getDirectMove(fromDir, toDir, fo, intoDir); err != nil {
 		return nil, errors.Wrap(err, "failed to detect single move operation")
 	} else if ok {
 		klog.V(3).Infof("Detected single move from file operation=%#v", fo)
@@ -76,7 +86,7 @@ func findMoveTargets(fromDir, toDir string, fo index.FileOperation) ([]move, err
 	return moves, nil
 }
 
-func getDirectMove(fromDir, toDir string, fo index.FileOperation) (move, bool, error) {
+func getDirectMove(fromDir, toDir string, fo index.FileOperation, intoDir bool) (move, bool, error) {
 	var m move
 	fromDir, err := filepath.Abs(fromDir)
 	if err != nil {
@@ -98,6 +108,8 @@ func getDirectMove(fromDir, toDir string, fo index.FileOperation) (move, bool, e
 	// If target is empty use old file name.
 	if filepath.Clean(fo.To) == "." {
 		fo.To = filepath.Base(fromFilePath)
+	} else if intoDir {
+		fo.To = filepath.Join(fo.To, filepath.Base(fromFilePath))
 	}
 
 	// Build new file name
@@ -71,6 +71,96 @@ func Test_findMoveTargets(t *testing.T) {
 			}},
 			wantErr: false,
 		},
+		{
+			name: "glob matching a single file moves it into the directory",
+			args: args{
+				fromDir: filepath.Join(testdataPath(t), "testdir_A"),
+				toDir:   filepath.Join(testdataPath(t), "testdir_B"),
+				fo: index.FileOperation{
+					From: "not*",
+					To:   "bin/foo",
+				},
+			},
+			want: []move{{
+				from: filepath.Join(testdataPath(t), "testdir_A", "notsecret"),
+				to:   filepath.Join(testdataPath(t), "testdir_B", "bin", "foo", "notsecret"),
+			}},
+		},
+		{
+			name: "file renamed to the target",
+			args: args{
+				fromDir: filepath.Join(testdataPath(t), "testdir_A"),
+				toDir:   filepath.Join(testdataPath(t), "testdir_B"),
+				fo: index.FileOperation{
+					From: "notsecret",
+					To:   "bin/foo",
+				},
+			},
+			want: []move{{
+				from: filepath.Join(testdataPath(t), "testdir_A", "notsecret"),
+				to:   filepath.Join(testdataPath(t), "testdir_B", "bin", "foo"),
+			}},
+		},
+		{
+			name: "glob matching a single file into a directory",
+			args: args{
+				fromDir: filepath.Join(testdataPath(t), "testdir_A"),
+				toDir:   filepath.Join(testdataPath(t), "testdir_B"),
+				fo: index.FileOperation{
+					From: "not*",
+					To:   "bin/",
+				},
+			},
+			want: []move{{
+				from: filepath.Join(testdataPath(t), "testdir_A", "notsecret"),
+				to:   filepath.Join(testdataPath(t), "testdir_B", "bin", "notsecret"),
+			}},
+		},
+		{
+			name: "glob matching multiple files moves them into a directory",
+			args: args{
+				fromDir: filepath.Join(testdataPath(t), "testdir_A"),
+				toDir:   filepath.Join(testdataPath(t), "testdir_B"),
+				fo: index.FileOperation{
+					From: "*",
+					To:   "bin",
+				},
+			},
+			want: []move{{
+				from: filepath.Join(testdataPath(t), "testdir_A", ".secret"),
+				to:   filepath.Join(testdataPath(t), "testdir_B", "bin", ".secret"),
+			}, {
+				from: filepath.Join(testdataPath(t), "testdir_A", "notsecret"),
+				to:   filepath.Join(testdataPath(t), "testdir_B", "bin", "notsecret"),
+			}},
+		},
+		{
+			name: "file into a directory",
+			args: args{
+				fromDir: filepath.Join(testdataPath(t), "testdir_A"),
+				toDir:   filepath.Join(testdataPath(t), "testdir_B"),
+				fo: index.FileOperation{
+					From: "notsecret",
+					To:   "docs/",
+				},
+			},
+			want: []move{{
+				from: filepath.Join(testdataPath(t), "testdir_A", "notsecret"),
+				to:   filepath.Join(testdataPath(t), "testdir_B", "docs", "notsecret"),
+			}},
+		},
+		{
+			name: "rename outside of the installation directory",
+			args: args{
+				fromDir: filepath.Join(testdataPath(t), "testdir_A"),
+				toDir:   filepath.Join(testdataPath(t), "testdir_B"),
+				fo: index.FileOperation{
+					From: "not*",
+					To:   "../foo",
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "glob not matching any files",
 			args: args{
@@ -170,7 +260,7 @@ func Test_getDirectMove(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1, err := getDirectMove(tt.args.fromDir, tt.args.toDir, tt.args.fo)
+			got, got1, err := getDirectMove(tt.args.fromDir, tt.args.toDir, tt.args.fo, false)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getDirectMove() error = %v, wantErr %v", err, tt.wantErr)
 				return
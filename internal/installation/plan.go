@@ -0,0 +1,66 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// InstallPlan describes what Install would do for a plugin.
+type InstallPlan struct {
+	// AlreadyInstalled is set if the plugin is installed, in which case
+	// Install would do nothing and the other fields are not set.
+	AlreadyInstalled bool
+
+	Version    string
+	URI        string // the download URI, after expanding it and rewriting its host
+	Sha256     string
+	Files      []index.FileOperation
+	Bin        string // the executable in InstallDir
+	BinName    string // the name the plugin is invoked with
+	InstallDir string
+}
+
+// PlanInstall returns what Install would download and install for the plugin
+// with the same arguments, without downloading or changing anything. It fails
+// if Install would fail before downloading.
+func PlanInstall(p environment.Paths, plugin index.Plugin, indexName string, opts InstallOpts) (InstallPlan, error) {
+	target, err := prepareInstall(p, plugin, opts)
+	if err == ErrIsAlreadyInstalled {
+		return InstallPlan{AlreadyInstalled: true}, nil
+	} else if err != nil {
+		return InstallPlan{}, err
+	}
+	candidate := target.platform
+	uri, err := resolveDownloadURI(candidate.URI, indexName == "", opts.DownloadHosts)
+	if err != nil {
+		return InstallPlan{}, err
+	}
+	if err := checkOffline(uri, candidate.Sha256, opts); err != nil {
+		return InstallPlan{}, err
+	}
+	applyDefaults(&candidate)
+
+	return InstallPlan{
+		Version:    plugin.Spec.Version,
+		URI:        uri,
+		Sha256:     candidate.Sha256,
+		Files:      candidate.Files,
+		Bin:        candidate.Bin,
+		BinName:    target.binName,
+		InstallDir: p.PluginVersionInstallPath(plugin.Name, plugin.Spec.Version),
+	}, nil
+}
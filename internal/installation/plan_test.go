@@ -0,0 +1,91 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func TestPlanInstall(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+		WithURI("https://github.com/foo/foo-${KREW_OS}.tar.gz").V()
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+
+	got, err := PlanInstall(p, plugin, constants.DefaultIndexName, InstallOpts{
+		BinName:       "foo-alt",
+		DownloadHosts: map[string]string{"github.com": "mirror.internal"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := InstallPlan{
+		Version:    "v1.0.0",
+		URI:        "https://mirror.internal/foo/foo-" + runtime.GOOS + ".tar.gz",
+		Sha256:     platform.Sha256,
+		Files:      platform.Files,
+		Bin:        platform.Bin,
+		BinName:    "foo-alt",
+		InstallDir: p.PluginVersionInstallPath("foo", "v1.0.0"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PlanInstall() mismatch (-want +got):\n%s", diff)
+	}
+
+	// nothing is written
+	entries, err := ioutil.ReadDir(tempDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the receipts directory in %s, got %d entries", tempDir.Root(), len(entries))
+	}
+
+	if err := receipt.Store(receipt.New(plugin, constants.DefaultIndexName), p.PluginInstallReceiptPath("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := PlanInstall(p, plugin, constants.DefaultIndexName, InstallOpts{}); err != nil || !got.AlreadyInstalled {
+		t.Errorf("PlanInstall() = %+v, %v; expected the plugin to be already installed", got, err)
+	}
+
+	other := testutil.NewPlugin().WithName("bar").WithPlatforms(
+		testutil.NewPlatform().WithOSArch("none", runtime.GOARCH).V()).V()
+	if _, err := PlanInstall(p, other, constants.DefaultIndexName, InstallOpts{}); err == nil {
+		t.Error("expected an error for a plugin not available on this platform")
+	}
+
+	offline := testutil.NewPlugin().WithName("baz").WithPlatforms(platform).V()
+	if _, err := PlanInstall(p, offline, constants.DefaultIndexName, InstallOpts{Offline: true}); err == nil {
+		t.Error("expected an error for a download in offline mode")
+	}
+	if _, err := PlanInstall(p, offline, constants.DefaultIndexName, InstallOpts{Offline: true, ArchiveFileOverride: "foo.tar.gz"}); err != nil {
+		t.Errorf("expected a local archive to be installable in offline mode: %v", err)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -34,6 +35,12 @@ func GetMatchingPlatform(platforms []index.Platform) (index.Platform, bool, erro
 	return matchPlatform(platforms, OSArch())
 }
 
+// MatchPlatform finds the platform spec in the specified plugin that matches
+// the given os/arch.
+func MatchPlatform(platforms []index.Platform, env OSArchPair) (index.Platform, bool, error) {
+	return matchPlatform(platforms, env)
+}
+
 // matchPlatform returns the first matching platform to given os/arch.
 func matchPlatform(platforms []index.Platform, env OSArchPair) (index.Platform, bool, error) {
 	envLabels := labels.Set{
@@ -55,6 +62,38 @@ func matchPlatform(platforms []index.Platform, env OSArchPair) (index.Platform,
 	return index.Platform{}, false, nil
 }
 
+// KnownOSArchPairs returns the os/arch pairs that plugins are commonly built
+// for, used to enumerate the platforms of a manifest.
+func KnownOSArchPairs() []OSArchPair {
+	return []OSArchPair{
+		{"darwin", "amd64"}, {"darwin", "arm64"},
+		{"linux", "386"}, {"linux", "amd64"}, {"linux", "arm"}, {"linux", "arm64"},
+		{"linux", "ppc64le"}, {"linux", "s390x"},
+		{"windows", "386"}, {"windows", "amd64"}, {"windows", "arm64"},
+	}
+}
+
+// PlatformsByOSArch returns the index of the platform in platforms that is
+// installed on each of the given os/arch pairs, the way GetMatchingPlatform
+// selects it. Pairs that no platform matches are left out.
+func PlatformsByOSArch(platforms []index.Platform, envs []OSArchPair) (map[OSArchPair]int, error) {
+	out := make(map[OSArchPair]int, len(envs))
+	for _, env := range envs {
+		envLabels := labels.Set{"os": env.OS, "arch": env.Arch}
+		for i, platform := range platforms {
+			sel, err := metav1.LabelSelectorAsSelector(platform.Selector)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to compile label selector")
+			}
+			if sel.Matches(envLabels) {
+				out[env] = i
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
 // OSArchPair is wrapper around operating system and architecture
 type OSArchPair struct {
 	OS, Arch string
@@ -65,6 +104,16 @@ func (p OSArchPair) String() string {
 	return fmt.Sprintf("%s/%s", p.OS, p.Arch)
 }
 
+// ParseOSArch parses an os/arch pair in the "OS/ARCH" format, for example
+// "linux/amd64".
+func ParseOSArch(s string) (OSArchPair, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return OSArchPair{}, errors.Errorf("invalid platform %q, must be in the OS/ARCH format (e.g. linux/amd64)", s)
+	}
+	return OSArchPair{OS: parts[0], Arch: parts[1]}, nil
+}
+
 // OSArch returns the OS/arch combination to be used on the current system. It
 // can be overridden by setting KREW_OS and/or KREW_ARCH environment variables.
 func OSArch() OSArchPair {
@@ -72,3 +72,39 @@ func Test_matchPlatform(t *testing.T) {
 		t.Fatal("got a matching platform, but was not expecting")
 	}
 }
+
+func TestParseOSArch(t *testing.T) {
+	got, err := ParseOSArch("linux/arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(OSArchPair{OS: "linux", Arch: "arm64"}, got); diff != "" {
+		t.Errorf("ParseOSArch() mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, invalid := range []string{"", "linux", "linux/", "/amd64", "linux/amd64/v2"} {
+		if _, err := ParseOSArch(invalid); err == nil {
+			t.Errorf("ParseOSArch(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestPlatformsByOSArch(t *testing.T) {
+	platforms := []index.Platform{
+		testutil.NewPlatform().WithOSArch("darwin", "amd64").V(),
+		testutil.NewPlatform().WithOS("linux").V(),
+		testutil.NewPlatform().WithOSArch("linux", "arm64").V(), // shadowed by the previous one
+	}
+	got, err := PlatformsByOSArch(platforms, KnownOSArchPairs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[OSArchPair]int{
+		{"darwin", "amd64"}: 0,
+		{"linux", "386"}:    1, {"linux", "amd64"}: 1, {"linux", "arm"}: 1, {"linux", "arm64"}: 1,
+		{"linux", "ppc64le"}: 1, {"linux", "s390x"}: 1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PlatformsByOSArch() mismatch:\n%s", diff)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"sigs.k8s.io/krew/internal/download"
+)
+
+// Progress events of the installation of a plugin, reported to
+// InstallOpts.Progress as they happen.
+const (
+	ProgressDownloaded = "downloaded" // the download finished
+	ProgressVerified   = "verified"   // the download matches its checksum
+	ProgressExtracted  = "extracted"  // the download was unpacked
+)
+
+// ProgressFunc is called with the progress events of an installation.
+type ProgressFunc func(event string)
+
+func (f ProgressFunc) report(event string) {
+	if f != nil {
+		f(event)
+	}
+}
+
+// progressVerifier reports when the download is complete and verified.
+// Verify is only called once the whole download was written to it.
+type progressVerifier struct {
+	download.Verifier
+	progress ProgressFunc
+}
+
+func (v progressVerifier) Verify() error {
+	v.progress.report(ProgressDownloaded)
+	if err := v.Verifier.Verify(); err != nil {
+		return err
+	}
+	v.progress.report(ProgressVerified)
+	return nil
+}
@@ -15,29 +15,96 @@
 package receipt
 
 import (
+	"bytes"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/krew/internal/index/indexscanner"
+	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
 
-// Store saves the given plugin at the destination.
+// Store saves the given receipt at the destination.
 // The caller has to ensure that the destination directory exists.
-func Store(plugin index.Plugin, dest string) error {
-	yamlBytes, err := yaml.Marshal(plugin)
+// The receipt is written to a temporary file that replaces the destination,
+// so that concurrent readers never see a partially written receipt.
+func Store(receipt index.Receipt, dest string) error {
+	yamlBytes, err := yaml.Marshal(receipt)
 	if err != nil {
 		return errors.Wrapf(err, "convert to yaml")
 	}
 
-	err = ioutil.WriteFile(dest, yamlBytes, 0644)
-	return errors.Wrapf(err, "write plugin receipt %q", dest)
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+"-")
+	if err != nil {
+		return errors.Wrapf(err, "write plugin receipt %q", dest)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(yamlBytes); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "write plugin receipt %q", dest)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "write plugin receipt %q", dest)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.Wrapf(err, "write plugin receipt %q", dest)
+	}
+	return errors.Wrapf(os.Rename(tmp.Name(), dest), "write plugin receipt %q", dest)
 }
 
 // Load reads the plugin receipt at the specified destination.
 // If not found, it returns os.IsNotExist error.
-func Load(path string) (index.Plugin, error) {
-	return indexscanner.ReadPluginFromFile(path)
+func Load(path string) (index.Receipt, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return index.Receipt{}, err
+	} else if err != nil {
+		return index.Receipt{}, errors.Wrap(err, "failed to read receipt file")
+	}
+	plugin, err := indexscanner.ReadPlugin(ioutil.NopCloser(bytes.NewReader(b)))
+	if err != nil {
+		return index.Receipt{Plugin: plugin}, err
+	}
+	var status struct {
+		Status index.ReceiptStatus `json:"status"`
+	}
+	if err := yaml.Unmarshal(b, &status); err != nil {
+		return index.Receipt{Plugin: plugin}, errors.Wrap(err, "failed to decode the status of the plugin receipt")
+	}
+	return index.Receipt{Plugin: plugin, Status: status.Status}, nil
+}
+
+// New returns a new receipt for the given plugin installed from the index
+// with the given name. indexName is empty for custom manifests.
+func New(plugin index.Plugin, indexName string) index.Receipt {
+	if indexName == "" {
+		indexName = constants.ManifestSourceName
+	}
+	return index.Receipt{
+		Plugin: plugin,
+		Status: index.ReceiptStatus{
+			Source: index.SourceIndex{Name: indexName},
+		},
+	}
+}
+
+// SourceIndexName returns the name of the index the plugin of the receipt
+// was installed from, or constants.ManifestSourceName if it was installed from
+// a custom manifest. Receipts that don't record the source were written by
+// versions of krew that only installed from the default index.
+func SourceIndexName(r index.Receipt) string {
+	if r.Status.Source.Name == "" {
+		return constants.DefaultIndexName
+	}
+	return r.Status.Source.Name
+}
+
+// IsCustomManifest reports whether the plugin of the receipt was installed
+// from a custom manifest instead of an index.
+func IsCustomManifest(r index.Receipt) bool {
+	return SourceIndexName(r) == constants.ManifestSourceName
 }
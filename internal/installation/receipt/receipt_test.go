@@ -19,9 +19,12 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/krew/internal/index/indexscanner"
 	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
 )
 
 func TestStore(t *testing.T) {
@@ -31,7 +34,7 @@ func TestStore(t *testing.T) {
 	testPlugin := testutil.NewPlugin().WithName("some-plugin").WithPlatforms(testutil.NewPlatform().V()).V()
 	dest := tmpDir.Path("some-plugin.yaml")
 
-	if err := Store(testPlugin, dest); err != nil {
+	if err := Store(New(testPlugin, constants.DefaultIndexName), dest); err != nil {
 		t.Fatal(err)
 	}
 
@@ -50,15 +53,42 @@ func TestLoad(t *testing.T) {
 	defer cleanup()
 
 	testPlugin := testutil.NewPlugin().WithName("foo").WithPlatforms(testutil.NewPlatform().V()).V()
-	if err := Store(testPlugin, tmpDir.Path("foo.yaml")); err != nil {
+	testReceipt := New(testPlugin, constants.DefaultIndexName)
+	if err := Store(testReceipt, tmpDir.Path("foo.yaml")); err != nil {
 		t.Fatal(err)
 	}
 
-	gotPlugin, err := Load(tmpDir.Path("foo.yaml"))
+	gotReceipt, err := Load(tmpDir.Path("foo.yaml"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if diff := cmp.Diff(&gotPlugin, &testPlugin); diff != "" {
+	if diff := cmp.Diff(&gotReceipt, &testReceipt); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoad_receiptWithoutStatus(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	testPlugin := testutil.NewPlugin().WithName("foo").WithPlatforms(testutil.NewPlatform().V()).V()
+	b, err := yaml.Marshal(testPlugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("foo.yaml", b)
+
+	gotReceipt, err := Load(tmpDir.Path("foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gotReceipt.Status.Source.Name; got != "" {
+		t.Fatalf("expected empty source index, got %q", got)
+	}
+	if got := SourceIndexName(gotReceipt); got != constants.DefaultIndexName {
+		t.Fatalf("expected a receipt without source to be from the %q index, got %q", constants.DefaultIndexName, got)
+	}
+	if diff := cmp.Diff(&gotReceipt.Plugin, &testPlugin); diff != "" {
 		t.Fatal(diff)
 	}
 }
@@ -69,3 +99,27 @@ func TestLoad_preservesNonExistsError(t *testing.T) {
 		t.Fatalf("returned error is not ENOENT: %+v", err)
 	}
 }
+
+func TestSourceIndexName(t *testing.T) {
+	plugin := testutil.NewPlugin().V()
+	tests := []struct {
+		name       string
+		receipt    index.Receipt
+		want       string
+		wantCustom bool
+	}{
+		{name: "index", receipt: New(plugin, "foo"), want: "foo"},
+		{name: "custom manifest", receipt: New(plugin, ""), want: constants.ManifestSourceName, wantCustom: true},
+		{name: "written before the source was recorded", receipt: index.Receipt{Plugin: plugin}, want: constants.DefaultIndexName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SourceIndexName(tt.receipt); got != tt.want {
+				t.Errorf("SourceIndexName() = %q, want %q", got, tt.want)
+			}
+			if got := IsCustomManifest(tt.receipt); got != tt.wantCustom {
+				t.Errorf("IsCustomManifest() = %v, want %v", got, tt.wantCustom)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// ManifestFinder returns the manifest of the plugin at the version from the
+// index, and the channel it is from. It returns an error that can be checked
+// with os.IsNotExist if the index has no such manifest.
+type ManifestFinder func(name, version string) (index.Plugin, string, error)
+
+// RefreshResult is the outcome of refreshing the receipt of a single plugin
+// installation directory.
+type RefreshResult struct {
+	Plugin  string
+	Version string // the matched version, empty if no manifest matched
+	// Err is non-nil if no receipt could be written for the directory.
+	Err error
+}
+
+// RefreshReceipts writes new receipts for the plugin installation directories
+// that have no valid receipt, for example after copying plugins into the
+// krew root or a receipt got corrupted. Each directory is matched to the
+// manifest of its name and installed version found with find. The manifest
+// must have a platform matching the current system whose binary is
+// installed. Directories that have a valid receipt, and directories left
+// behind by an uninstall that don't have the plugin binary in any version, are
+// not included in the results.
+func RefreshReceipts(p environment.Paths, find ManifestFinder) ([]RefreshResult, error) {
+	entries, err := ioutil.ReadDir(p.InstallPath())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the plugin installation directories")
+	}
+	var results []RefreshResult
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		r, err := receipt.Load(p.PluginInstallReceiptPath(name))
+		if err == nil && r.Name == name {
+			continue
+		} else if err != nil && !os.IsNotExist(err) {
+			klog.V(1).Infof("Receipt of plugin %s is invalid, refreshing it: %v", name, err)
+		}
+		klog.V(2).Infof("Refreshing the receipt of plugin %s", name)
+		version, err := refreshReceipt(p, name, find)
+		if err == errNoInstalledBinary {
+			klog.V(1).Infof("Skipping %s, no version has the plugin binary installed", name)
+			continue
+		}
+		results = append(results, RefreshResult{Plugin: name, Version: version, Err: err})
+	}
+	return results, nil
+}
+
+// errNoInstalledBinary is returned by refreshReceipt if the manifests of all
+// installed versions were found but none of them has the plugin binary, e.g.
+// for the files a plugin created that are kept on uninstall.
+var errNoInstalledBinary = errors.New("no installed version has the plugin binary")
+
+// refreshReceipt matches the installation directory of the plugin to a
+// manifest and writes its receipt. It returns the matched version.
+func refreshReceipt(p environment.Paths, name string, find ManifestFinder) (string, error) {
+	lock, err := lockPlugin(p, name)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	versions, err := installedVersions(p.PluginInstallPath(name))
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", errors.New("no installed versions found")
+	}
+	var noBinary int
+	for _, version := range versions {
+		plugin, channel, err := find(name, version)
+		if os.IsNotExist(err) {
+			klog.V(2).Infof("No manifest for %s@%s in the index", name, version)
+			continue
+		} else if err != nil {
+			return "", errors.Wrapf(err, "failed to look up %s@%s in the index", name, version)
+		}
+		binary, err := installedBinary(p, plugin)
+		if err != nil {
+			klog.V(2).Infof("Manifest of %s@%s does not match the installation: %v", name, version, err)
+			if os.IsNotExist(errors.Cause(err)) {
+				noBinary++
+			}
+			continue
+		}
+
+		r := receipt.New(plugin, constants.DefaultIndexName)
+		r.Status.Source.Channel = channel
+		r.Status.BinName = linkedBinName(p, name, binary)
+		recordFiles(&r.Status, p.PluginVersionInstallPath(name, version))
+		if err := receipt.Store(r, p.PluginInstallReceiptPath(name)); err != nil {
+			return "", errors.Wrapf(err, "failed to store the receipt of plugin %s", name)
+		}
+		return version, nil
+	}
+	if noBinary == len(versions) {
+		return "", errNoInstalledBinary
+	}
+	return "", errors.Errorf("no manifest in the index matches the installed version(s) %s", strings.Join(versions, ", "))
+}
+
+// installedVersions returns the names of the version directories in the
+// installation directory of a plugin, newest version first. Names that are
+// not semantic versions come last.
+func installedVersions(pluginDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(pluginDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %q", pluginDir)
+	}
+	var versions []string
+	for _, e := range entries {
+		if e.IsDir() {
+			versions = append(versions, e.Name())
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, erri := semver.Parse(versions[i])
+		vj, errj := semver.Parse(versions[j])
+		if erri != nil || errj != nil {
+			return erri == nil && errj != nil
+		}
+		return semver.Less(vj, vi)
+	})
+	return versions, nil
+}
+
+// installedBinary returns the path of the plugin binary of the manifest in its
+// installation directory, and an error if it is not installed there.
+func installedBinary(p environment.Paths, plugin index.Plugin) (string, error) {
+	candidate, ok, err := GetMatchingPlatform(plugin.Spec.Platforms)
+	if err != nil {
+		return "", errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
+	}
+	if !ok {
+		return "", errors.Errorf("plugin %q does not offer installation for this platform (%s)", plugin.Name, OSArch())
+	}
+	binary := filepath.Join(p.PluginVersionInstallPath(plugin.Name, plugin.Spec.Version), filepath.FromSlash(candidate.Bin))
+	if _, err := os.Stat(binary); err != nil {
+		return "", errors.Wrapf(err, "cannot find the plugin binary %q", binary)
+	}
+	return binary, nil
+}
+
+// linkedBinName returns the bin name of the link in the bin directory that
+// points to binary, if it is not the default bin name of the plugin.
+func linkedBinName(p environment.Paths, name, binary string) string {
+	entries, err := ioutil.ReadDir(p.BinPath())
+	if err != nil {
+		klog.V(2).Infof("Failed to read the bin directory: %v", err)
+		return ""
+	}
+	for _, e := range entries {
+		target, err := os.Readlink(filepath.Join(p.BinPath(), e.Name()))
+		if err != nil || filepath.Clean(target) != filepath.Clean(binary) {
+			continue
+		}
+		if e.Name() == pluginNameToBin(name, IsWindows()) {
+			return ""
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(e.Name(), "kubectl-"), ".exe")
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestRefreshReceipts(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithBin("kubectl-x").V()
+	manifests := map[string]index.Plugin{
+		"foo@v1.0.0": testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V(),
+		"bar@v2.0.0": testutil.NewPlugin().WithName("bar").WithVersion("v2.0.0").WithPlatforms(platform).V(),
+		"ok@v1.0.0":  testutil.NewPlugin().WithName("ok").WithVersion("v1.0.0").WithPlatforms(platform).V(),
+	}
+	find := func(name, version string) (index.Plugin, string, error) {
+		plugin, ok := manifests[name+"@"+version]
+		if !ok {
+			return testutil.NewPlugin().V(), "", os.ErrNotExist
+		}
+		return plugin, "beta", nil
+	}
+
+	// foo has no receipt and is linked with another bin name
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "kubectl-x"), nil)
+	if err := os.Symlink(tmpDir.Path(filepath.Join("store", "foo", "v1.0.0", "kubectl-x")),
+		filepath.Join(p.BinPath(), pluginNameToBin("foo2", IsWindows()))); err != nil {
+		t.Fatal(err)
+	}
+	// bar has a corrupted receipt and a version the index doesn't have
+	tmpDir.Write(filepath.Join("store", "bar", "v1.0.0", "kubectl-x"), nil)
+	tmpDir.Write(filepath.Join("receipts", "bar"+constants.ManifestExtension), []byte("{corrupted"))
+	// ok has a valid receipt
+	tmpDir.Write(filepath.Join("store", "ok", "v1.0.0", "kubectl-x"), nil)
+	if err := receipt.Store(receipt.New(manifests["ok@v1.0.0"], constants.DefaultIndexName), p.PluginInstallReceiptPath("ok")); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RefreshReceipts(p, find)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	for _, r := range results {
+		switch r.Plugin {
+		case "foo":
+			if r.Err != nil || r.Version != "v1.0.0" {
+				t.Errorf("refreshing foo = %q, %v", r.Version, r.Err)
+			}
+		case "bar":
+			if r.Err == nil {
+				t.Error("expected an error for bar, whose version is not in the index")
+			}
+		default:
+			t.Errorf("unexpected plugin %q in results", r.Plugin)
+		}
+	}
+
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Spec.Version != "v1.0.0" || r.Status.Source.Channel != "beta" || BinName(r) != "foo2" {
+		t.Errorf("unexpected refreshed receipt: version=%s channel=%q binName=%q", r.Spec.Version, r.Status.Source.Channel, BinName(r))
+	}
+	if len(r.Status.Files) != 1 || r.Status.Files[0] != "kubectl-x" {
+		t.Errorf("expected the installed files to be recorded, got %v", r.Status.Files)
+	}
+}
+
+func TestRefreshReceipts_afterUninstall(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithBin("kubectl-foo").V()
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+	find := func(name, version string) (index.Plugin, string, error) {
+		if name+"@"+version != "foo@v1.0.0" {
+			return testutil.NewPlugin().V(), "", os.ErrNotExist
+		}
+		return plugin, "", nil
+	}
+	r := receipt.New(plugin, constants.DefaultIndexName)
+	r.Status.Files = []string{"kubectl-foo"}
+	if err := receipt.Store(r, p.PluginInstallReceiptPath("foo")); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "kubectl-foo"), nil)
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "data", "created-by-plugin"), nil)
+
+	if err := Uninstall(p, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := RefreshReceipts(p, find)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected the files kept on uninstall to be skipped, got %+v", results)
+	}
+	if _, err := os.Stat(p.PluginInstallReceiptPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("expected no receipt to be written for the uninstalled plugin, got err=%v", err)
+	}
+}
+
+func Test_installedVersions(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	for _, v := range []string{"v1.9.0", "v1.10.0", "dev", "v1.10.0-rc.1", "v0.1.0"} {
+		if err := os.MkdirAll(tmpDir.Path(filepath.Join("foo", v)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmpDir.Write(filepath.Join("foo", "file"), nil)
+
+	got, err := installedVersions(tmpDir.Path("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"v1.10.0", "v1.10.0-rc.1", "v1.9.0", "v0.1.0", "dev"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("installedVersions() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// RelinkResult is the outcome of relinking a single installed plugin.
+type RelinkResult struct {
+	Plugin string
+	// Err is non-nil if the symlink of the plugin could not be recreated.
+	Err error
+}
+
+// Relink recreates the symlinks in the bin directory of all installed plugins
+// so that they point to the plugin binaries under the current krew root. This
+// repairs installations that have been copied or moved to another location.
+// Plugins that can't be relinked are reported in the results and don't stop
+// the remaining plugins from being relinked.
+func Relink(p environment.Paths) ([]RelinkResult, error) {
+	receipts, err := ListInstalledPlugins(p.InstallReceiptsPath())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find all installed plugins")
+	}
+
+	results := make([]RelinkResult, 0, len(receipts))
+	for _, r := range receipts {
+		klog.V(2).Infof("Relinking plugin %s", r.Name)
+		results = append(results, RelinkResult{Plugin: r.Name, Err: relinkPlugin(p, r)})
+	}
+	return results, nil
+}
+
+func relinkPlugin(p environment.Paths, r index.Receipt) error {
+	if r.Status.DevLink != "" {
+		return createOrUpdateLink(p.BinPath(), r.Status.DevLink, BinName(r))
+	}
+	candidate, ok, err := GetMatchingPlatform(r.Spec.Platforms)
+	if err != nil {
+		return errors.Wrap(err, "failed trying to find a matching platform in the plugin receipt")
+	}
+	if !ok {
+		return errors.New("plugin receipt has no platform matching the current system")
+	}
+
+	binary := filepath.Join(p.PluginVersionInstallPath(r.Name, r.Spec.Version), filepath.FromSlash(candidate.Bin))
+	if _, err := os.Stat(binary); err != nil {
+		return errors.Wrapf(err, "cannot resolve the plugin binary %q", binary)
+	}
+	return createOrUpdateLink(p.BinPath(), binary, BinName(r))
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func TestRelink(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithBin("bin/kubectl-x").V()
+	if err := os.MkdirAll(p.InstallReceiptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "missing"} {
+		plugin := testutil.NewPlugin().WithName(name).WithVersion("v1.0.0").WithPlatforms(platform).V()
+		if err := receipt.Store(receipt.New(plugin, constants.DefaultIndexName), p.PluginInstallReceiptPath(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binary := filepath.Join(p.PluginVersionInstallPath("foo", "v1.0.0"), "bin", "kubectl-x")
+	tmpDir.Write(filepath.Join("store", "foo", "v1.0.0", "bin", "kubectl-x"), nil)
+
+	// simulate a link into a krew root that was moved away
+	link := filepath.Join(p.BinPath(), pluginNameToBin("foo", IsWindows()))
+	if err := os.MkdirAll(p.BinPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/old/krew/root/store/foo/v1.0.0/bin/kubectl-x", link); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Relink(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		switch r.Plugin {
+		case "foo":
+			if r.Err != nil {
+				t.Errorf("relinking foo failed: %v", r.Err)
+			}
+		case "missing":
+			if r.Err == nil {
+				t.Errorf("expected an error for plugin with missing binary")
+			}
+		default:
+			t.Errorf("unexpected plugin %q in results", r.Plugin)
+		}
+	}
+
+	got, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != binary {
+		t.Errorf("expected link to point to %q, got %q", binary, got)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// UpgradeKrew upgrades krew itself to the version of the plugin manifest.
+//
+// Unlike Upgrade, the new version is installed next to the running one and
+// has to run before anything else changes. The link in the bin directory is
+// then replaced in one rename, so it always points to a complete krew. If
+// any step fails, the link, the receipt and the installation of the current
+// version are left (or put back) as they were.
+//
+// The running executable itself is never replaced. Its directory is removed
+// after the upgrade, except on Windows where it is still in use and is
+// cleaned up on the next run.
+//
+// With opts.ReinstallOnChecksumChange, the same version is reinstalled if the
+// checksum of its download changed, like Upgrade does. The new installation
+// is staged and run next to the current one, which is then replaced in one
+// rename. This fails on Windows while the current version is running.
+func UpgradeKrew(p environment.Paths, plugin index.Plugin, opts InstallOpts) error {
+	lock, err := lockPlugin(p, plugin.Name)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	u, err := planUpgrade(p, plugin, opts)
+	if err != nil {
+		return err
+	}
+	curVersion, newVersion, newDir := u.curVersion, u.newVersion, u.installDir
+	binName := BinName(u.installed)
+	link := filepath.Join(p.BinPath(), pluginNameToBin(binName, IsWindows()))
+	oldBinary, err := os.Readlink(link)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the link to the current version at %q", link)
+	}
+
+	removeNewVersion := func() {
+		if err := os.RemoveAll(newDir); err != nil {
+			klog.Warningf("failed to clean up the installation directory %q: %v", newDir, err)
+		}
+	}
+
+	klog.V(1).Infof("Installing new version %s", newVersion)
+	uri, err := install(installOperation{
+		pluginName: plugin.Name,
+		binName:    binName,
+		platform:   u.platform,
+
+		installDir: newDir,
+		binDir:     p.BinPath(),
+		noLink:     true,
+	}, InstallOpts{
+		DownloadHosts: opts.DownloadHosts,
+		Offline:       opts.Offline,
+		HTTPFetcher:   opts.HTTPFetcher,
+		Progress:      opts.Progress,
+		DownloadCache: opts.DownloadCache,
+
+		ArchiveFileOverride: opts.ArchiveFileOverride,
+	})
+	if err != nil {
+		removeNewVersion()
+		return errors.Wrap(err, "failed to install new version")
+	}
+
+	platform := u.platform
+	applyDefaults(&platform)
+	newBinary := filepath.Join(newDir, filepath.FromSlash(platform.Bin))
+	if err := verifyRun(newBinary); err != nil {
+		removeNewVersion()
+		return errors.Wrapf(err, "new version %s of krew failed to run, keeping version %s", newVersion, curVersion)
+	}
+
+	if u.reinstall {
+		// the link already points into the version directory
+		if err := replaceInstallation(u.versionDir, newDir); err != nil {
+			return errors.Wrapf(err, "failed to reinstall version %s of krew", newVersion)
+		}
+	} else if err := replaceLink(link, newBinary); err != nil {
+		removeNewVersion()
+		return errors.Wrap(err, "failed to link the new version")
+	}
+
+	if err := receipt.Store(u.newReceipt(plugin, uri), p.PluginInstallReceiptPath(plugin.Name)); err != nil {
+		if u.reinstall {
+			return errors.Wrapf(err, "version %s of krew was reinstalled, but its installation receipt could not be stored", newVersion)
+		}
+		if rerr := replaceLink(link, oldBinary); rerr != nil {
+			return errors.Wrapf(err, "installation receipt could not be stored, and restoring the link to version %s failed (%v)", curVersion, rerr)
+		}
+		removeNewVersion()
+		return errors.Wrapf(err, "installation receipt could not be stored, keeping version %s", curVersion)
+	}
+
+	if u.reinstall {
+		// the same version was reinstalled in place
+		return nil
+	}
+	klog.V(2).Infof("Starting old version cleanup")
+	if err := cleanupInstallation(p, plugin, curVersion); err != nil {
+		klog.Warningf("failed to remove the old version %s of krew: %v", curVersion, err)
+	}
+	return nil
+}
+
+// replaceLink points the symlink at dst to binary in a single rename, so
+// that dst never is missing or points to a partial installation.
+func replaceLink(dst, binary string) error {
+	tmp := dst + ".new"
+	if err := removeLink(tmp); err != nil {
+		return errors.Wrap(err, "failed to remove a leftover symlink")
+	}
+	klog.V(2).Infof("Creating symlink to %q at %q", binary, tmp)
+	if err := os.Symlink(binary, tmp); err != nil {
+		return errors.Wrapf(err, "failed to create a symlink from %q to %q", binary, tmp)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to replace the symlink at %q", dst)
+	}
+	klog.V(2).Infof("Replaced symlink at %q", dst)
+	return nil
+}
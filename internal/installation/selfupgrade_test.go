@@ -0,0 +1,201 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestUpgradeKrew(t *testing.T) {
+	if IsWindows() {
+		t.Skip("test uses shell scripts as krew executables")
+	}
+	tests := []struct {
+		name        string
+		script      string
+		shouldFail  bool
+		wantVersion string
+	}{
+		{
+			name:        "new version runs",
+			script:      "#!/bin/sh\nexit 0\n",
+			wantVersion: "v2.0.0",
+		},
+		{
+			name:        "new version fails to run",
+			script:      "#!/bin/sh\nexit 1\n",
+			shouldFail:  true,
+			wantVersion: "v1.0.0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			p := environment.NewPaths(tempDir.Root())
+			for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tempDir.Write("v1", []byte("#!/bin/sh\nexit 0\n"))
+			tempDir.Write("v2", []byte(test.script))
+			krew := func(version, file string) index.Plugin {
+				return testutil.NewPlugin().WithName(constants.KrewPluginName).WithVersion(version).
+					WithPlatforms(testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+						WithRawBinary(true).WithBin("krew").WithFiles(nil).
+						WithSHA256(sha256Of(t, tempDir.Path(file))).V()).V()
+			}
+			if err := Install(p, krew("v1.0.0", "v1"), constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: tempDir.Path("v1")}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := UpgradeKrew(p, krew("v2.0.0", "v2"), InstallOpts{ArchiveFileOverride: tempDir.Path("v2")})
+			if (err != nil) != test.shouldFail {
+				t.Fatalf("UpgradeKrew() error = %v, shouldFail = %v", err, test.shouldFail)
+			}
+
+			r, err := receipt.Load(p.PluginInstallReceiptPath(constants.KrewPluginName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Spec.Version != test.wantVersion {
+				t.Errorf("receipt has version %s, expected %s", r.Spec.Version, test.wantVersion)
+			}
+			target, err := os.Readlink(filepath.Join(p.BinPath(), "kubectl-krew"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(p.PluginVersionInstallPath(constants.KrewPluginName, test.wantVersion), "krew"); target != want {
+				t.Errorf("link points to %q, expected %q", target, want)
+			}
+			for _, version := range []string{"v1.0.0", "v2.0.0"} {
+				_, err := os.Stat(p.PluginVersionInstallPath(constants.KrewPluginName, version))
+				if exists := err == nil; exists != (version == test.wantVersion) {
+					t.Errorf("installation of %s exists = %v, expected only %s", version, exists, test.wantVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestUpgradeKrew_reinstallOnChecksumChange(t *testing.T) {
+	if IsWindows() {
+		t.Skip("test uses shell scripts as krew executables")
+	}
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tempDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tempDir.Write("v1", []byte("#!/bin/sh\nexit 0\n"))
+	tempDir.Write("v1-rebuilt", []byte("#!/bin/sh\n# rebuilt\nexit 0\n"))
+	krew := func(file string) index.Plugin {
+		return testutil.NewPlugin().WithName(constants.KrewPluginName).WithVersion("v1.0.0").
+			WithPlatforms(testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+				WithRawBinary(true).WithBin("krew").WithFiles(nil).
+				WithSHA256(sha256Of(t, tempDir.Path(file))).V()).V()
+	}
+	if err := Install(p, krew("v1"), constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: tempDir.Path("v1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt := krew("v1-rebuilt")
+	opts := InstallOpts{ArchiveFileOverride: tempDir.Path("v1-rebuilt")}
+	if err := UpgradeKrew(p, rebuilt, opts); err != ErrIsAlreadyUpgraded {
+		t.Fatalf("UpgradeKrew() error = %v, expected %v without ReinstallOnChecksumChange", err, ErrIsAlreadyUpgraded)
+	}
+	opts.ReinstallOnChecksumChange = true
+	if err := UpgradeKrew(p, rebuilt, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := receipt.Load(p.PluginInstallReceiptPath(constants.KrewPluginName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := rebuilt.Spec.Platforms[0].Sha256; r.Status.SHA256 != want {
+		t.Errorf("receipt has checksum %s, expected %s", r.Status.SHA256, want)
+	}
+	binary := filepath.Join(p.PluginVersionInstallPath(constants.KrewPluginName, "v1.0.0"), "krew")
+	if got := sha256Of(t, binary); got != r.Status.SHA256 {
+		t.Errorf("installed krew has checksum %s, expected the reinstalled %s", got, r.Status.SHA256)
+	}
+	if target, err := os.Readlink(filepath.Join(p.BinPath(), "kubectl-krew")); err != nil {
+		t.Fatal(err)
+	} else if target != binary {
+		t.Errorf("link points to %q, expected %q", target, binary)
+	}
+	entries, err := ioutil.ReadDir(p.PluginInstallPath(constants.KrewPluginName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the installation of v1.0.0 to be left, got %d entries", len(entries))
+	}
+
+	if err := UpgradeKrew(p, rebuilt, opts); err != ErrIsAlreadyUpgraded {
+		t.Errorf("UpgradeKrew() error = %v, expected %v after the reinstall", err, ErrIsAlreadyUpgraded)
+	}
+}
+
+func Test_replaceLink(t *testing.T) {
+	tempDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tempDir.Write("a", nil)
+	tempDir.Write("b", nil)
+
+	link := tempDir.Path("link")
+	if err := os.Symlink(tempDir.Path("a"), link); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceLink(link, tempDir.Path("b")); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(link); err != nil {
+		t.Fatal(err)
+	} else if target != tempDir.Path("b") {
+		t.Errorf("link points to %q, expected %q", target, tempDir.Path("b"))
+	}
+	if _, err := os.Lstat(link + ".new"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary link to be gone, got %v", err)
+	}
+}
+
+func sha256Of(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	k8sver "k8s.io/apimachinery/pkg/util/version"
+)
+
+// Latest is the version specification of the newest available version.
+const Latest = "latest"
+
+// ErrNoMatchingVersion indicates that none of the available versions matches
+// a version specification.
+var ErrNoMatchingVersion = errors.New("no available version matches")
+
+// Spec is a version specification: Latest, an exact version or a range of
+// versions.
+//
+// A range is one or more sets of comparators separated by "||", and matches a
+// version that satisfies all comparators of any set. Comparators are
+// separated by spaces or commas, and are a version with one of the operators
+// =, !=, <, <=, > or >=, or one of:
+//
+//	~v1.2.3  for >=v1.2.3 <v1.3.0
+//	^v1.2.3  for >=v1.2.3 <v2.0.0 (>=v0.2.3 <v0.3.0 for ^v0.2.3)
+//
+// The leading "v" of versions in ranges is optional. Like with npm, a range
+// only matches a pre-release version if one of its comparators in the same set
+// is a pre-release of the same major, minor and patch version. Build metadata
+// is ignored, except for exact versions specified with build metadata.
+type Spec struct {
+	raw    string
+	latest bool
+	exact  *Version
+	sets   [][]comparator
+}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+// ParseSpec parses a version specification.
+func ParseSpec(s string) (Spec, error) {
+	s = strings.TrimSpace(s)
+	spec := Spec{raw: s}
+	if s == "" {
+		return spec, errors.New("version specification is empty")
+	}
+	if s == Latest {
+		spec.latest = true
+		return spec, nil
+	}
+	if v, err := Parse(s); err == nil {
+		spec.exact = &v
+		return spec, nil
+	} else if !strings.ContainsAny(s, "=<>!~^|, ") {
+		return spec, errors.Wrapf(err, "invalid version %q", s)
+	}
+
+	for _, set := range strings.Split(s, "||") {
+		var comparators []comparator
+		fields := strings.FieldsFunc(set, func(r rune) bool { return r == ' ' || r == ',' })
+		for i := 0; i < len(fields); i++ {
+			c := fields[i]
+			if strings.Trim(c, "=<>!~^") == "" && i+1 < len(fields) {
+				// an operator separated from its version, e.g. ">= v1.2.0"
+				i++
+				c += fields[i]
+			}
+			cs, err := parseComparator(c)
+			if err != nil {
+				return spec, errors.Wrapf(err, "invalid version range %q", s)
+			}
+			comparators = append(comparators, cs...)
+		}
+		if len(comparators) == 0 {
+			return spec, errors.Errorf("invalid version range %q, it has an empty set of comparators", s)
+		}
+		spec.sets = append(spec.sets, comparators)
+	}
+	return spec, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	var op string
+	for _, o := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return nil, errors.Errorf("comparator %q has no operator", s)
+	}
+	vs := strings.TrimPrefix(s, op)
+	if !strings.HasPrefix(vs, "v") {
+		vs = "v" + vs
+	}
+	v, err := Parse(vs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "comparator %q has an invalid version", s)
+	}
+
+	vv := k8sver.Version(v)
+	major, minor, patch := vv.Major(), vv.Minor(), vv.Patch()
+	var upper string
+	switch op {
+	case "~":
+		upper = fmt.Sprintf("v%d.%d.0", major, minor+1)
+	case "^":
+		switch {
+		case major > 0:
+			upper = fmt.Sprintf("v%d.0.0", major+1)
+		case minor > 0:
+			upper = fmt.Sprintf("v0.%d.0", minor+1)
+		default:
+			upper = fmt.Sprintf("v0.0.%d", patch+1)
+		}
+	default:
+		return []comparator{{op: op, v: v}}, nil
+	}
+	u, err := Parse(upper)
+	if err != nil {
+		return nil, err
+	}
+	return []comparator{{op: ">=", v: v}, {op: "<", v: u}}, nil
+}
+
+// IsLatest reports whether the specification is Latest.
+func (s Spec) IsLatest() bool { return s.latest }
+
+// String returns the specification as it was parsed.
+func (s Spec) String() string { return s.raw }
+
+// Matches reports whether the version matches the specification. Every
+// version matches Latest.
+func (s Spec) Matches(v Version) bool {
+	switch {
+	case s.latest:
+		return true
+	case s.exact != nil:
+		if compare(v, *s.exact) != 0 {
+			return false
+		}
+		exact := k8sver.Version(*s.exact)
+		vv := k8sver.Version(v)
+		return exact.BuildMetadata() == "" || exact.BuildMetadata() == vv.BuildMetadata()
+	}
+	for _, set := range s.sets {
+		if matchesSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesSet(set []comparator, v Version) bool {
+	vv := k8sver.Version(v)
+	prereleaseAllowed := vv.PreRelease() == ""
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+		cv := k8sver.Version(c.v)
+		if cv.PreRelease() != "" && cv.Major() == vv.Major() && cv.Minor() == vv.Minor() && cv.Patch() == vv.Patch() {
+			prereleaseAllowed = true
+		}
+	}
+	return prereleaseAllowed
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := compare(v, c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+// compare returns -1, 0 or 1 if a is lower than, equal to or higher than b,
+// ignoring build metadata.
+func compare(a, b Version) int {
+	switch {
+	case Less(a, b):
+		return -1
+	case Less(b, a):
+		return 1
+	}
+	return 0
+}
+
+// Resolve returns the highest of the available versions that matches the
+// specification, or ErrNoMatchingVersion. Available versions that are not
+// valid semantic versions are ignored.
+func Resolve(spec Spec, available []string) (string, error) {
+	var best string
+	var bestV Version
+	for _, a := range available {
+		v, err := Parse(a)
+		if err != nil || !spec.Matches(v) {
+			continue
+		}
+		if best == "" || Less(bestV, v) {
+			best, bestV = a, v
+		}
+	}
+	if best == "" {
+		return "", ErrNoMatchingVersion
+	}
+	return best, nil
+}
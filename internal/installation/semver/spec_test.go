@@ -0,0 +1,138 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"testing"
+)
+
+func TestParseSpec_invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"  ",
+		"v1.2",
+		"1.2.3",
+		"newest",
+		">=v1",
+		">=",
+		"v1.0.0 ||",
+		"|| <v2.0.0",
+		"~",
+		">=v1.0.0 foo",
+		"=>v1.0.0",
+		">=v01.0.0",
+	} {
+		if _, err := ParseSpec(s); err == nil {
+			t.Errorf("ParseSpec(%q) expected an error", s)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	available := []string{
+		"v0.1.0", "v0.2.0", "v0.2.5", "v1.0.0", "v1.2.0", "v1.2.9", "v1.3.0-rc.1",
+		"v1.3.0", "v2.0.0-beta.1", "v2.0.0-beta.2", "not-a-version",
+	}
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "latest", want: "v2.0.0-beta.2"},
+		{spec: "v1.2.0", want: "v1.2.0"},
+		{spec: " v1.2.0 ", want: "v1.2.0"},
+		{spec: "v1.3.0-rc.1", want: "v1.3.0-rc.1"},
+		{spec: "=v1.0.0", want: "v1.0.0"},
+		{spec: ">=v1.0.0", want: "v1.3.0"},
+		{spec: ">= v1.0.0, < v1.3.0", want: "v1.2.9"},
+		{spec: ">=1.0.0 <1.3.0", want: "v1.2.9"},
+		{spec: "<v1.0.0", want: "v0.2.5"},
+		{spec: "<=v1.0.0", want: "v1.0.0"},
+		{spec: ">v1.3.0", want: ""},
+		{spec: "!=v1.3.0 <v2.0.0", want: "v1.2.9"},
+		{spec: "~v1.2.0", want: "v1.2.9"},
+		{spec: "~1.3.0", want: "v1.3.0"},
+		{spec: "^v1.0.0", want: "v1.3.0"},
+		{spec: "^v0.2.0", want: "v0.2.5"},
+		{spec: "^v0.0.1", want: ""},
+		{spec: "<v0.2.0 || >=v1.2.0 <v1.3.0", want: "v1.2.9"},
+		{spec: "<v0.2.0 || ~v3.0.0", want: "v0.1.0"},
+		{spec: "v3.0.0", want: ""},
+
+		// pre-releases only match ranges with a pre-release of the same version
+		{spec: ">=v2.0.0-beta.1", want: "v2.0.0-beta.2"},
+		{spec: ">=v1.3.0-rc.1 <v1.3.0", want: "v1.3.0-rc.1"},
+		{spec: ">v1.3.0 <v3.0.0", want: ""},
+		{spec: "^v2.0.0-beta.1", want: "v2.0.0-beta.2"},
+	}
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpec(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		got, err := Resolve(spec, available)
+		if tt.want == "" {
+			if err != ErrNoMatchingVersion {
+				t.Errorf("Resolve(%q) = %q, %v, expected %v", tt.spec, got, err, ErrNoMatchingVersion)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v, expected %q", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestSpec_buildMetadata(t *testing.T) {
+	available := []string{"v1.0.0+build.1", "v1.1.0+build.2"}
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "v1.0.0", want: "v1.0.0+build.1"},
+		{spec: "v1.0.0+build.1", want: "v1.0.0+build.1"},
+		{spec: "v1.0.0+build.2", want: ""},
+		{spec: "<=v1.1.0", want: "v1.1.0+build.2"},
+		{spec: ">v1.0.0+build.9", want: "v1.1.0+build.2"},
+	}
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) failed: %v", tt.spec, err)
+		}
+		got, err := Resolve(spec, available)
+		if tt.want == "" {
+			if err != ErrNoMatchingVersion {
+				t.Errorf("Resolve(%q) = %q, %v, expected no match", tt.spec, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v, expected %q", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestSpec_IsLatest(t *testing.T) {
+	for s, want := range map[string]bool{"latest": true, "v1.0.0": false, ">=v1.0.0": false} {
+		spec, err := ParseSpec(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spec.IsLatest() != want {
+			t.Errorf("ParseSpec(%q).IsLatest() = %v, expected %v", s, spec.IsLatest(), want)
+		}
+	}
+}
@@ -16,75 +16,267 @@ package installation
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
 
 	"sigs.k8s.io/krew/internal/environment"
 	"sigs.k8s.io/krew/internal/installation/receipt"
 	"sigs.k8s.io/krew/internal/installation/semver"
+	"sigs.k8s.io/krew/internal/pathutil"
 	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/index"
 )
 
 // Upgrade will reinstall and delete the old plugin. The operation tries
 // to not get the plugin dir in a bad state if it fails during the process.
-func Upgrade(p environment.Paths, plugin index.Plugin) error {
-	installReceipt, err := receipt.Load(p.PluginInstallReceiptPath(plugin.Name))
+// The BinName of opts is ignored, the plugin keeps the name it was installed
+// with.
+func Upgrade(p environment.Paths, plugin index.Plugin, opts InstallOpts) error {
+	lock, err := lockPlugin(p, plugin.Name)
 	if err != nil {
-		return errors.Wrapf(err, "failed to load install receipt for plugin %q", plugin.Name)
+		return err
 	}
+	defer lock.Unlock()
 
-	curVersion := installReceipt.Spec.Version
-	curv, err := semver.Parse(curVersion)
+	u, err := planUpgrade(p, plugin, opts)
 	if err != nil {
-		return errors.Wrapf(err, "failed to parse installed plugin version (%q) as a semver value", curVersion)
+		return err
+	}
+
+	// Re-Install
+	klog.V(1).Infof("Installing new version %s", u.newVersion)
+	uri, err := install(installOperation{
+		pluginName: plugin.Name,
+		binName:    BinName(u.installed),
+		platform:   u.platform,
+
+		installDir: u.installDir,
+		binDir:     p.BinPath(),
+		noLink:     u.reinstall,
+	}, InstallOpts{
+		DownloadHosts: opts.DownloadHosts,
+		Offline:       opts.Offline,
+		HTTPFetcher:   opts.HTTPFetcher,
+		VerifyRun:     opts.VerifyRun,
+		RequireRun:    opts.RequireRun,
+		Progress:      opts.Progress,
+		DownloadCache: opts.DownloadCache,
+	})
+	if err != nil {
+		if u.reinstall {
+			os.RemoveAll(u.installDir)
+		}
+		return errors.Wrap(err, "failed to install new version")
+	}
+	if u.reinstall {
+		if err := replaceInstallation(u.versionDir, u.installDir); err != nil {
+			return err
+		}
+		platform := u.platform
+		applyDefaults(&platform)
+		if err := createOrUpdateLink(p.BinPath(), filepath.Join(u.versionDir, filepath.FromSlash(platform.Bin)), BinName(u.installed)); err != nil {
+			return errors.Wrap(err, "failed to link reinstalled plugin")
+		}
+	}
+
+	// The receipt is only updated once the new version is installed, so that a
+	// failed upgrade is attempted again.
+	klog.V(2).Infof("Upgrading install receipt for plugin %s", plugin.Name)
+	if err := receipt.Store(u.newReceipt(plugin, uri), p.PluginInstallReceiptPath(plugin.Name)); err != nil {
+		return errors.Wrap(err, "installation receipt could not be stored, uninstall may fail")
+	}
+
+	// Clean old installations
+	if u.reinstall {
+		// the same version was reinstalled in place
+		return nil
+	}
+	klog.V(2).Infof("Starting old version cleanup")
+	return cleanupInstallation(p, plugin, u.curVersion)
+}
+
+// upgradePlan is what an upgrade of an installed plugin installs.
+type upgradePlan struct {
+	installed  index.Receipt // the receipt of the installed version
+	platform   index.Platform
+	curVersion string
+	newVersion string
+
+	// reinstall is set if the same version is reinstalled because the
+	// checksum of its download changed.
+	reinstall bool
+
+	versionDir string // the installation directory of the new version
+	installDir string // where the new version is installed, staged next to versionDir for a reinstall
+}
+
+// planUpgrade decides whether and how the installed plugin is upgraded to the
+// plugin manifest, for both Upgrade and UpgradeKrew. It returns
+// ErrIsAlreadyUpgraded if there is nothing to install.
+func planUpgrade(p environment.Paths, plugin index.Plugin, opts InstallOpts) (upgradePlan, error) {
+	installReceipt, err := receipt.Load(p.PluginInstallReceiptPath(plugin.Name))
+	if os.IsNotExist(err) || (err == nil && installReceipt.Status.Removed) {
+		return upgradePlan{}, ErrIsNotInstalled
+	} else if err != nil {
+		return upgradePlan{}, errors.Wrapf(err, "failed to load install receipt for plugin %q", plugin.Name)
+	}
+	if installReceipt.Status.DevLink != "" {
+		return upgradePlan{}, ErrIsDevLinked
 	}
 
 	// Find available installation candidate
 	candidate, ok, err := GetMatchingPlatform(plugin.Spec.Platforms)
 	if err != nil {
-		return errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
+		return upgradePlan{}, errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
 	}
 	if !ok {
-		return errors.Errorf("plugin %q does not offer installation for this platform (%s)",
+		return upgradePlan{}, errors.Errorf("plugin %q does not offer installation for this platform (%s)",
 			plugin.Name, OSArch())
 	}
 
-	newVersion := plugin.Spec.Version
-	newv, err := semver.Parse(newVersion)
+	curVersion, newVersion := installReceipt.Spec.Version, plugin.Spec.Version
+	needsUpgrade, err := NeedsUpgrade(installReceipt, plugin)
 	if err != nil {
-		return errors.Wrapf(err, "failed to parse candidate version spec (%q)", newVersion)
+		return upgradePlan{}, err
+	}
+	downgrade := !needsUpgrade && opts.DowngradeOK && newVersion != curVersion
+	reinstall := !needsUpgrade && opts.ReinstallOnChecksumChange && newVersion == curVersion &&
+		checksumChanged(installReceipt, candidate)
+	if !needsUpgrade && !downgrade && !reinstall {
+		return upgradePlan{}, ErrIsAlreadyUpgraded
+	}
+	if downgrade {
+		klog.V(1).Infof("Downgrading plugin %s from %s to %s to match the index", plugin.Name, curVersion, newVersion)
+	}
+	if reinstall {
+		klog.V(1).Infof("Reinstalling plugin %s, the checksum of version %s changed", plugin.Name, newVersion)
 	}
-	klog.V(2).Infof("Comparing versions: current=%s target=%s", curv, newv)
 
-	// See if it's a newer version
-	if !semver.Less(curv, newv) {
-		klog.V(3).Infof("Plugin does not need upgrade (%s ≥ %s)", curv, newv)
-		return ErrIsAlreadyUpgraded
+	// A reinstall of the same version is staged next to the working
+	// installation, which is only replaced once the new one is complete.
+	versionDir := p.PluginVersionInstallPath(plugin.Name, newVersion)
+	installDir := versionDir
+	if reinstall {
+		installDir = filepath.Join(p.PluginInstallPath(plugin.Name), "."+newVersion+".reinstall")
+		if err := os.RemoveAll(installDir); err != nil {
+			return upgradePlan{}, errors.Wrapf(err, "failed to remove a leftover staging directory %q", installDir)
+		}
 	}
-	klog.V(1).Infof("Plugin needs upgrade (%s < %s)", curv, newv)
+	return upgradePlan{
+		installed:  installReceipt,
+		platform:   candidate,
+		curVersion: curVersion,
+		newVersion: newVersion,
+		reinstall:  reinstall,
+		versionDir: versionDir,
+		installDir: installDir,
+	}, nil
+}
 
-	klog.V(2).Infof("Upgrading install receipt for plugin %s", plugin.Name)
-	if err = receipt.Store(plugin, p.PluginInstallReceiptPath(plugin.Name)); err != nil {
-		return errors.Wrap(err, "installation receipt could not be stored, uninstall may fail")
+// newReceipt returns the receipt of the plugin once it is installed in the
+// version directory of the upgrade from uri.
+func (u upgradePlan) newReceipt(plugin index.Plugin, uri string) index.Receipt {
+	// Upgrades are always resolved from the index, so the receipt now records
+	// it as the source even if the plugin was installed from a manifest.
+	r := receipt.New(plugin, constants.DefaultIndexName)
+	r.Status.BinName = BinName(u.installed)
+	r.Status.Source.Channel = u.installed.Status.Source.Channel
+	now := metav1.Now()
+	r.Status.InstalledAt = &now
+	recordFiles(&r.Status, u.versionDir)
+	r.Status.SHA256 = u.platform.Sha256
+	r.Status.PreviousSHA256 = previousSHA256(u.installed, u.platform.Sha256)
+	r.Status.URI = uri
+	return r
+}
+
+// replaceInstallation replaces the installation in dir with the one in staged.
+// The current installation is kept until the new one is in place.
+func replaceInstallation(dir, staged string) error {
+	backup := staged + ".old"
+	if err := pathutil.ReplaceDir(dir, staged, backup); err != nil {
+		os.RemoveAll(staged)
+		return errors.Wrap(err, "failed to replace the installation")
+	}
+	if err := os.RemoveAll(backup); err != nil {
+		klog.Warningf("failed to remove the previous installation %q: %v", backup, err)
 	}
+	return nil
+}
 
-	// Re-Install
-	klog.V(1).Infof("Installing new version %s", newVersion)
-	if err := install(installOperation{
-		pluginName: plugin.Name,
-		platform:   candidate,
+// checksumChanged reports whether the installed plugin of the receipt was
+// installed from another download than the one of the platform.
+func checksumChanged(installed index.Receipt, platform index.Platform) bool {
+	sum := installedSHA256(installed)
+	if sum == "" {
+		klog.V(2).Infof("Cannot find the checksum of the installed version of plugin %s", installed.Name)
+		return false
+	}
+	return !strings.EqualFold(sum, platform.Sha256)
+}
 
-		installDir: p.PluginVersionInstallPath(plugin.Name, newVersion),
-		binDir:     p.BinPath(),
-	}, InstallOpts{}); err != nil {
-		return errors.Wrap(err, "failed to install new version")
+// installedSHA256 returns the checksum of the download the plugin of the
+// receipt was installed from, or an empty string if it is not known.
+func installedSHA256(r index.Receipt) string {
+	if r.Status.SHA256 != "" {
+		return r.Status.SHA256
 	}
+	old, ok, err := GetMatchingPlatform(r.Spec.Platforms)
+	if err != nil || !ok {
+		return ""
+	}
+	return old.Sha256
+}
 
-	// Clean old installations
-	klog.V(2).Infof("Starting old version cleanup")
-	return cleanupInstallation(p, plugin, curVersion)
+// previousSHA256 returns the lower-case checksums of the downloads of all
+// versions installed up to the receipt, except newSum, to record them in the
+// receipt of the version installed next.
+func previousSHA256(installed index.Receipt, newSum string) []string {
+	seen := map[string]bool{strings.ToLower(newSum): true}
+	var sums []string
+	for _, sum := range append(append([]string(nil), installed.Status.PreviousSHA256...), installedSHA256(installed)) {
+		sum = strings.ToLower(sum)
+		if sum == "" || seen[sum] {
+			continue
+		}
+		seen[sum] = true
+		sums = append(sums, sum)
+	}
+	return sums
+}
+
+var latestSpec, _ = semver.ParseSpec(semver.Latest)
+
+// NeedsUpgrade reports whether the plugin manifest offers a newer version than
+// the installed version recorded in the receipt.
+func NeedsUpgrade(installed index.Receipt, plugin index.Plugin) (bool, error) {
+	curVersion := installed.Spec.Version
+	curv, err := semver.Parse(curVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse installed plugin version (%q) as a semver value", curVersion)
+	}
+	newVersion := plugin.Spec.Version
+	newv, err := semver.Parse(newVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse candidate version spec (%q)", newVersion)
+	}
+	klog.V(2).Infof("Comparing versions: current=%s target=%s", curv, newv)
+
+	// the newest of both, the installed version if they are equal
+	newest, err := semver.Resolve(latestSpec, []string{curVersion, newVersion})
+	if err != nil {
+		return false, err
+	}
+	if newest == curVersion {
+		klog.V(3).Infof("Plugin does not need upgrade (%s ≥ %s)", curv, newv)
+		return false, nil
+	}
+	klog.V(1).Infof("Plugin needs upgrade (%s < %s)", curv, newv)
+	return true, nil
 }
 
 // cleanupInstallation will remove a plugin directly if it not krew.
@@ -0,0 +1,242 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
+)
+
+func TestNeedsUpgrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		available string
+		want      bool
+		wantErr   bool
+	}{
+		{"newer available", "v1.0.0", "v1.1.0", true, false},
+		{"same version", "v1.0.0", "v1.0.0", false, false},
+		{"older available", "v1.1.0", "v1.0.0", false, false},
+		{"newer pre-release available", "v1.0.0", "v1.1.0-rc.1", true, false},
+		{"release of installed pre-release", "v1.1.0-rc.1", "v1.1.0", true, false},
+		{"only build metadata differs", "v1.0.0", "v1.0.0+build.2", false, false},
+		{"bad installed version", "1.0.0", "v1.0.0", false, true},
+		{"bad available version", "v1.0.0", "latest", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installed := receipt.New(testutil.NewPlugin().WithVersion(tt.installed).V(), "")
+			available := testutil.NewPlugin().WithVersion(tt.available).V()
+			got, err := NeedsUpgrade(installed, available)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NeedsUpgrade() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("NeedsUpgrade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_previousSHA256(t *testing.T) {
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithSHA256("AAAA").V()
+	installed := receipt.New(testutil.NewPlugin().WithPlatforms(platform).V(), "")
+	if diff := cmp.Diff([]string{"aaaa"}, previousSHA256(installed, "bbbb")); diff != "" {
+		t.Errorf("expected the checksum of the manifest in a legacy receipt (-want +got):\n%s", diff)
+	}
+	installed.Status.SHA256 = "cccc"
+	installed.Status.PreviousSHA256 = []string{"dddd", "bbbb"}
+	if diff := cmp.Diff([]string{"dddd", "cccc"}, previousSHA256(installed, "BBBB")); diff != "" {
+		t.Errorf("expected earlier checksums except the new one (-want +got):\n%s", diff)
+	}
+}
+
+func Test_checksumChanged(t *testing.T) {
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).WithSHA256("AAAA").V()
+	installed := receipt.New(testutil.NewPlugin().WithPlatforms(platform).V(), "")
+	if checksumChanged(installed, testutil.NewPlatform().WithSHA256("aaaa").V()) {
+		t.Error("expected the checksum of the manifest in the receipt to be compared case-insensitively")
+	}
+	installed.Status.SHA256 = "bbbb"
+	if !checksumChanged(installed, testutil.NewPlatform().WithSHA256("aaaa").V()) {
+		t.Error("expected the recorded checksum to take precedence over the manifest in the receipt")
+	}
+}
+
+func TestUpgrade_reinstallOnChecksumChange(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rebuilt := []byte("#!/bin/sh\necho rebuilt\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(rebuilt) }))
+	defer server.Close()
+	sum := sha256.Sum256(rebuilt)
+
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+		WithRawBinary(true).WithBin("kubectl-foo").WithFiles(nil).WithURI(server.URL).
+		WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	if err := Install(p, plugin, constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: testFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	platform.Sha256 = hex.EncodeToString(sum[:])
+	plugin = testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+	if err := Upgrade(p, plugin, InstallOpts{}); err != ErrIsAlreadyUpgraded {
+		t.Fatalf("expected ErrIsAlreadyUpgraded without ReinstallOnChecksumChange, got %v", err)
+	}
+	if err := Upgrade(p, plugin, InstallOpts{ReinstallOnChecksumChange: true}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(p.PluginVersionInstallPath("foo", "v1.0.0"), "kubectl-foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(rebuilt) {
+		t.Errorf("expected the rebuilt binary to be installed, got %q", b)
+	}
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status.SHA256 != platform.Sha256 {
+		t.Errorf("receipt records checksum %q, expected %q", r.Status.SHA256, platform.Sha256)
+	}
+	if err := Upgrade(p, plugin, InstallOpts{ReinstallOnChecksumChange: true}); err != ErrIsAlreadyUpgraded {
+		t.Errorf("expected ErrIsAlreadyUpgraded once the checksum matches, got %v", err)
+	}
+}
+
+func TestUpgrade_reinstallFailureKeepsInstallation(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broken := []byte("#!/bin/sh\nexit 1\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(broken) }))
+	defer server.Close()
+	sum := sha256.Sum256(broken)
+
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+		WithRawBinary(true).WithBin("kubectl-foo").WithFiles(nil).WithURI(server.URL).
+		WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	if err := Install(p, plugin, constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: testFile}); err != nil {
+		t.Fatal(err)
+	}
+	installed, err := ioutil.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	platform.Sha256 = hex.EncodeToString(sum[:])
+	plugin = testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+	if err := Upgrade(p, plugin, InstallOpts{ReinstallOnChecksumChange: true, RequireRun: true}); err == nil {
+		t.Fatal("expected the reinstall of a plugin that fails to run to fail")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(p.PluginVersionInstallPath("foo", "v1.0.0"), "kubectl-foo"))
+	if err != nil {
+		t.Fatalf("expected the working installation to be kept: %v", err)
+	}
+	if string(b) != string(installed) {
+		t.Errorf("expected the installed binary to be kept, got %q", b)
+	}
+	entries, err := ioutil.ReadDir(p.PluginInstallPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the installed version in %q, got %d entries", p.PluginInstallPath("foo"), len(entries))
+	}
+	if err := Upgrade(p, plugin, InstallOpts{ReinstallOnChecksumChange: true}); err == ErrIsAlreadyUpgraded {
+		t.Error("expected the failed reinstall to be attempted again")
+	}
+}
+
+func TestUpgrade_downgradeOK(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	platform := testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+		WithRawBinary(true).WithBin("kubectl-foo").WithFiles(nil).
+		WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	installed := testutil.NewPlugin().WithName("foo").WithVersion("v1.1.0").WithPlatforms(platform).V()
+	if err := Install(p, installed, constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: testFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(content) }))
+	defer server.Close()
+	platform.URI = server.URL
+	older := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(platform).V()
+	if err := Upgrade(p, older, InstallOpts{}); err != ErrIsAlreadyUpgraded {
+		t.Fatalf("expected ErrIsAlreadyUpgraded without DowngradeOK, got %v", err)
+	}
+	if err := Upgrade(p, older, InstallOpts{DowngradeOK: true}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Spec.Version != "v1.0.0" {
+		t.Errorf("receipt records version %q, expected v1.0.0", r.Spec.Version)
+	}
+	if _, err := os.Stat(p.PluginVersionInstallPath("foo", "v1.1.0")); !os.IsNotExist(err) {
+		t.Errorf("expected the newer version to be removed, got %v", err)
+	}
+	if err := Upgrade(p, older, InstallOpts{DowngradeOK: true}); err != ErrIsAlreadyUpgraded {
+		t.Errorf("expected ErrIsAlreadyUpgraded once the version matches, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// uriEnvAllowlistEnv is a comma-separated list of environment variables that
+// may be referenced in the uri of a custom plugin manifest in addition to the
+// built-in ones.
+const uriEnvAllowlistEnv = "KREW_URI_ENV_ALLOWLIST"
+
+var uriVariableRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// ExpandURI replaces the ${VAR} references in a plugin manifest uri. The
+// ${KREW_OS} and ${KREW_ARCH} variables expand to the current OS/arch pair.
+// If allowEnv is set (for custom manifests), environment variables listed in
+// KREW_URI_ENV_ALLOWLIST can be referenced as well. Manifests from an index
+// must be self-contained and never expand environment variables. Referencing
+// any other variable, or an allowlisted variable that is unset, is an error.
+func ExpandURI(uri string, allowEnv bool) (string, error) {
+	return ExpandURIFor(uri, OSArch(), allowEnv)
+}
+
+// ExpandURIFor is like ExpandURI, but expands ${KREW_OS} and ${KREW_ARCH} to
+// the given OS/arch pair.
+func ExpandURIFor(uri string, osArch OSArchPair, allowEnv bool) (string, error) {
+	if !strings.Contains(uri, "${") {
+		return uri, nil
+	}
+
+	builtin := map[string]string{
+		"KREW_OS":   osArch.OS,
+		"KREW_ARCH": osArch.Arch,
+	}
+	allowed := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv(uriEnvAllowlistEnv), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+
+	var expandErr error
+	out := uriVariableRegexp.ReplaceAllStringFunc(uri, func(ref string) string {
+		name := uriVariableRegexp.FindStringSubmatch(ref)[1]
+		if v, ok := builtin[name]; ok {
+			return v
+		}
+		if !allowEnv {
+			if expandErr == nil {
+				expandErr = errors.Errorf("variable %q referenced in uri is not allowed, plugin manifests from an index can only reference KREW_OS and KREW_ARCH", name)
+			}
+			return ref
+		}
+		if !allowed[name] {
+			if expandErr == nil {
+				expandErr = errors.Errorf("variable %q referenced in uri is not allowed (allowed: KREW_OS, KREW_ARCH and the variables in %s)", name, uriEnvAllowlistEnv)
+			}
+			return ref
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			if expandErr == nil {
+				expandErr = errors.Errorf("environment variable %q referenced in uri is not set", name)
+			}
+			return ref
+		}
+		return v
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	klog.V(2).Infof("Expanded uri %q to %q", uri, out)
+	return out, nil
+}
+
+// resolveDownloadURI returns the uri a plugin is downloaded from, with its
+// variables expanded like ExpandURI and its host rewritten like
+// RewriteDownloadHost.
+func resolveDownloadURI(uri string, customManifest bool, hosts map[string]string) (string, error) {
+	uri, err := ExpandURI(uri, customManifest)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve the download uri")
+	}
+	uri, err = RewriteDownloadHost(uri, hosts)
+	return uri, errors.Wrap(err, "failed to resolve the download uri")
+}
+
+// RewriteDownloadHost replaces the host of the uri if it is mapped to another
+// host in hosts. The host is matched with and without its port.
+func RewriteDownloadHost(uri string, hosts map[string]string) (string, error) {
+	if len(hosts) == 0 {
+		return uri, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse uri %q", uri)
+	}
+	to, ok := hosts[u.Host]
+	if !ok {
+		to, ok = hosts[u.Hostname()]
+		if ok && u.Port() != "" {
+			to += ":" + u.Port()
+		}
+	}
+	if !ok {
+		return uri, nil
+	}
+	u.Host = to
+	klog.V(2).Infof("Rewrote download uri %q to %q", uri, u.String())
+	return u.String(), nil
+}
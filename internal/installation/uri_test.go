@@ -0,0 +1,143 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExpandURI(t *testing.T) {
+	os.Setenv("KREW_OS", "linux")
+	os.Setenv("KREW_ARCH", "arm64")
+	os.Setenv("KREW_URI_ENV_ALLOWLIST", "MIRROR, EMPTY, UNSET")
+	os.Setenv("MIRROR", "mirror.example.com")
+	os.Setenv("EMPTY", "")
+	os.Setenv("SECRET", "s3cr3t")
+	os.Unsetenv("UNSET")
+	defer func() {
+		for _, v := range []string{"KREW_OS", "KREW_ARCH", "KREW_URI_ENV_ALLOWLIST", "MIRROR", "EMPTY", "SECRET"} {
+			os.Unsetenv(v)
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		uri        string
+		indexEntry bool
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "no variables",
+			uri:  "https://example.com/foo-$1.tar.gz",
+			want: "https://example.com/foo-$1.tar.gz",
+		},
+		{
+			name: "os and arch",
+			uri:  "https://example.com/foo-${KREW_OS}-${KREW_ARCH}.tar.gz",
+			want: "https://example.com/foo-linux-arm64.tar.gz",
+		},
+		{
+			name: "allowlisted variable",
+			uri:  "https://${MIRROR}/foo.tar.gz",
+			want: "https://mirror.example.com/foo.tar.gz",
+		},
+		{
+			name:       "os and arch in index manifest",
+			uri:        "https://example.com/foo-${KREW_OS}-${KREW_ARCH}.tar.gz",
+			indexEntry: true,
+			want:       "https://example.com/foo-linux-arm64.tar.gz",
+		},
+		{
+			name:       "allowlisted variable in index manifest",
+			uri:        "https://${MIRROR}/foo.tar.gz",
+			indexEntry: true,
+			wantErr:    true,
+		},
+		{
+			name:    "variable not in allowlist",
+			uri:     "https://example.com/foo.tar.gz?token=${SECRET}",
+			wantErr: true,
+		},
+		{
+			name:    "allowlisted variable is empty",
+			uri:     "https://${EMPTY}/foo.tar.gz",
+			wantErr: true,
+		},
+		{
+			name:    "allowlisted variable is unset",
+			uri:     "https://${UNSET}/foo.tar.gz",
+			wantErr: true,
+		},
+		{
+			name:    "empty variable name",
+			uri:     "https://example.com/${}/foo.tar.gz",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandURI(tt.uri, !tt.indexEntry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandURI() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteDownloadHost(t *testing.T) {
+	hosts := map[string]string{
+		"github.com":     "mirror.internal",
+		"example.com:80": "mirror.internal:8080",
+	}
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{
+			uri:  "https://github.com/foo/bar/releases/download/v1.0/bar.tar.gz",
+			want: "https://mirror.internal/foo/bar/releases/download/v1.0/bar.tar.gz",
+		},
+		{
+			uri:  "https://github.com:443/foo.tar.gz",
+			want: "https://mirror.internal:443/foo.tar.gz",
+		},
+		{
+			uri:  "http://example.com:80/foo.tar.gz",
+			want: "http://mirror.internal:8080/foo.tar.gz",
+		},
+		{
+			uri:  "https://gitlab.com/foo.tar.gz",
+			want: "https://gitlab.com/foo.tar.gz",
+		},
+		{
+			uri:  "https://api.github.com/foo.tar.gz",
+			want: "https://api.github.com/foo.tar.gz",
+		},
+	}
+	for _, tt := range tests {
+		got, err := RewriteDownloadHost(tt.uri, hosts)
+		if err != nil {
+			t.Fatalf("RewriteDownloadHost(%q) failed: %v", tt.uri, err)
+		}
+		if got != tt.want {
+			t.Errorf("RewriteDownloadHost(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -15,35 +15,142 @@
 package installation
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/krew/internal/environment"
 	"sigs.k8s.io/krew/internal/installation/receipt"
 	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index"
 )
 
-// ListInstalledPlugins returns a list of all install plugins in a
-// name:version format based on the install receipts at the specified dir.
-func ListInstalledPlugins(receiptsDir string) (map[string]string, error) {
-	// TODO(ahmetb): Write unit tests for this method. Currently blocked by
-	// lack of an in-memory recipt object (issue#270) that we can use to save
-	// receipts to a tempdir that can be read from unit tests.
+// ListInstalledPlugins returns the install receipts of all installed plugins
+// found at the specified dir. Receipts kept for uninstalled plugins are not
+// included.
+func ListInstalledPlugins(receiptsDir string) ([]index.Receipt, error) {
+	return listReceipts(receiptsDir, false)
+}
+
+// ListKeptReceipts returns the receipts found at the specified dir that were
+// kept for plugins uninstalled with UninstallKeepReceipt.
+func ListKeptReceipts(receiptsDir string) ([]index.Receipt, error) {
+	return listReceipts(receiptsDir, true)
+}
 
+func listReceipts(receiptsDir string, removed bool) ([]index.Receipt, error) {
 	matches, err := filepath.Glob(filepath.Join(receiptsDir, "*"+constants.ManifestExtension))
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to grab receipts directory (%s) for manifests", receiptsDir)
 	}
 	klog.V(4).Infof("Found %d install receipts in %s", len(matches), receiptsDir)
-	installed := make(map[string]string)
+	installed := make([]index.Receipt, 0, len(matches))
 	for _, m := range matches {
 		r, err := receipt.Load(m)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse plugin install receipt %s", m)
 		}
 		klog.V(4).Infof("parsed receipt for %s: version=%s", r.GetObjectMeta().GetName(), r.Spec.Version)
-		installed[r.GetObjectMeta().GetName()] = r.Spec.Version
+		if r.Status.Removed != removed {
+			continue
+		}
+		installed = append(installed, r)
 	}
 	return installed, nil
 }
+
+// removeEmptyParents removes the parent directories of path that are empty,
+// up to but not including root. It stops at the first directory that is not
+// empty, and ignores directories that don't exist anymore.
+func removeEmptyParents(path, root string) error {
+	return removeEmptyDirs(filepath.Dir(filepath.Clean(path)), root)
+}
+
+// removeEmptyDirs removes dir if it is empty, and then its empty parent
+// directories the same way as removeEmptyParents.
+func removeEmptyDirs(dir, root string) error {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root; dir = filepath.Dir(dir) {
+		if rel, err := filepath.Rel(root, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return nil // not under root
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read directory %q", dir)
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+		klog.V(3).Infof("Removing empty directory %q", dir)
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove empty directory %q", dir)
+		}
+	}
+	return nil
+}
+
+// InstallTime returns the time the plugin of the receipt was installed or last
+// upgraded. For receipts that don't record it, the modification time of the
+// receipt file at receiptPath is used.
+func InstallTime(r index.Receipt, receiptPath string) (time.Time, error) {
+	if r.Status.InstalledAt != nil {
+		return r.Status.InstalledAt.Time, nil
+	}
+	fi, err := os.Stat(receiptPath)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to read the modification time of receipt %q", receiptPath)
+	}
+	return fi.ModTime(), nil
+}
+
+// listFiles returns the files and symlinks under dir, relative to it with
+// forward slashes, in lexical order.
+func listFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, errors.Wrapf(err, "failed to list the files in %q", dir)
+}
+
+// PluginFiles returns the absolute paths of the files installed for the
+// plugin of the receipt: its link in the bin directory, followed by the files
+// recorded in the receipt. For receipts that don't record the files, the
+// files found in the installation directory of the plugin are returned.
+func PluginFiles(p environment.Paths, r index.Receipt) ([]string, error) {
+	files := []string{filepath.Join(p.BinPath(), pluginNameToBin(BinName(r), IsWindows()))}
+	if r.Status.DevLink != "" {
+		return files, nil
+	}
+	installDir := p.PluginVersionInstallPath(r.Name, r.Spec.Version)
+	rel := r.Status.Files
+	if rel == nil {
+		klog.V(2).Infof("Receipt of plugin %s does not record its files, listing %q", r.Name, installDir)
+		var err error
+		if rel, err = listFiles(installDir); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range rel {
+		files = append(files, filepath.Join(installDir, filepath.FromSlash(f)))
+	}
+	return files, nil
+}
@@ -15,10 +15,61 @@
 package installation
 
 import (
+	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/krew/internal/environment"
+	"sigs.k8s.io/krew/internal/installation/receipt"
+	"sigs.k8s.io/krew/internal/testutil"
+	"sigs.k8s.io/krew/pkg/constants"
 )
 
+func TestListInstalledPlugins(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	for _, r := range []struct{ name, version, index string }{
+		{"foo", "v1.0.0", constants.DefaultIndexName},
+		{"bar", "v0.2.0", ""},
+	} {
+		plugin := testutil.NewPlugin().WithName(r.name).WithVersion(r.version).V()
+		if err := receipt.Store(receipt.New(plugin, r.index), tmpDir.Path(r.name+constants.ManifestExtension)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmpDir.Write("not-a-receipt.txt", []byte("ignored"))
+
+	got, err := ListInstalledPlugins(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("ListInstalledPlugins() returned %d receipts, want 2", len(got))
+	}
+	if got[0].Name != "bar" || got[0].Spec.Version != "v0.2.0" || !receipt.IsCustomManifest(got[0]) {
+		t.Errorf("unexpected receipt for bar: %+v", got[0])
+	}
+	if got[1].Name != "foo" || got[1].Spec.Version != "v1.0.0" || got[1].Status.Source.Name != constants.DefaultIndexName {
+		t.Errorf("unexpected receipt for foo: %+v", got[1])
+	}
+}
+
+func TestListInstalledPlugins_invalidReceipt(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	tmpDir.Write("foo"+constants.ManifestExtension, []byte("not: [valid"))
+	if _, err := ListInstalledPlugins(tmpDir.Root()); err == nil {
+		t.Fatal("expected error for invalid receipt")
+	}
+}
+
 func testdataPath(t *testing.T) string {
 	pwd, err := filepath.Abs(".")
 	if err != nil {
@@ -26,3 +77,135 @@ func testdataPath(t *testing.T) string {
 	}
 	return filepath.Join(pwd, "testdata")
 }
+
+func Test_removeEmptyParents(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	tmpDir.Write("root/a/b/sibling/file", nil)
+	if err := os.MkdirAll(tmpDir.Path("root/a/b/c/d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(tmpDir.Path("root/x/y"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// d was removed already, c is empty, b contains a sibling
+	if err := os.Remove(tmpDir.Path("root/a/b/c/d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeEmptyParents(tmpDir.Path("root/a/b/c/d"), tmpDir.Path("root")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("root/a/b/c")); !os.IsNotExist(err) {
+		t.Errorf("expected empty directory to be removed, got err=%v", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("root/a/b/sibling/file")); err != nil {
+		t.Errorf("expected sibling to be kept: %v", err)
+	}
+
+	// all parents are empty, root must be kept
+	if err := os.Remove(tmpDir.Path("root/x/y")); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeEmptyParents(tmpDir.Path("root/x/y"), tmpDir.Path("root")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("root/x")); !os.IsNotExist(err) {
+		t.Errorf("expected empty directory to be removed, got err=%v", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("root")); err != nil {
+		t.Errorf("expected root to be kept: %v", err)
+	}
+}
+
+func TestInstallTime(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	r := receipt.New(testutil.NewPlugin().WithName("foo").V(), constants.DefaultIndexName)
+	recorded := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	r.Status.InstalledAt = &metav1.Time{Time: recorded}
+	got, err := InstallTime(r, tmpDir.Path("does-not-exist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(recorded) {
+		t.Errorf("InstallTime() = %v, want %v", got, recorded)
+	}
+
+	// legacy receipts fall back to the modification time of the receipt file
+	r.Status.InstalledAt = nil
+	path := tmpDir.Write("foo"+constants.ManifestExtension, nil).Path("foo" + constants.ManifestExtension)
+	mtime := time.Date(2019, 6, 1, 8, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	got, err = InstallTime(r, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(mtime) {
+		t.Errorf("InstallTime() = %v, want receipt mtime %v", got, mtime)
+	}
+
+	if _, err := InstallTime(r, tmpDir.Path("does-not-exist")); err == nil {
+		t.Error("expected error for missing receipt file")
+	}
+}
+
+func TestPluginFiles(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p := environment.NewPaths(tmpDir.Root())
+	for _, dir := range []string{p.InstallReceiptsPath(), p.BinPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testFile := filepath.Join(testdataPath(t), "..", "..", "download", "testdata", "bash-utf8-file")
+	plugin := testutil.NewPlugin().WithName("foo").WithVersion("v1.0.0").WithPlatforms(
+		testutil.NewPlatform().WithOSArch(runtime.GOOS, runtime.GOARCH).
+			WithRawBinary(true).WithBin("bin/kubectl-foo").WithFiles(nil).
+			WithSHA256("2dfddaa235daec8aeac50a6a0d0b5aba674866f81656b7fd0283622105fe436f").V()).V()
+	if err := Install(p, plugin, constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: testFile}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := receipt.Load(p.PluginInstallReceiptPath("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"bin/kubectl-foo"}, r.Status.Files); diff != "" {
+		t.Errorf("unexpected files recorded in the receipt: %s", diff)
+	}
+
+	want := []string{
+		filepath.Join(p.BinPath(), pluginNameToBin("foo", IsWindows())),
+		filepath.Join(p.PluginVersionInstallPath("foo", "v1.0.0"), "bin", "kubectl-foo"),
+	}
+	got, err := PluginFiles(p, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PluginFiles() with recorded files: %s", diff)
+	}
+
+	// receipts written before the files were recorded
+	r.Status.Files = nil
+	if got, err = PluginFiles(p, r); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PluginFiles() without recorded files: %s", diff)
+	}
+
+	r.Status.DevLink = "/dev/build/kubectl-foo"
+	if got, err = PluginFiles(p, r); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want[:1], got); diff != "" {
+		t.Errorf("PluginFiles() of linked plugin: %s", diff)
+	}
+}
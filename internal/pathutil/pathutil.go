@@ -15,10 +15,12 @@
 package pathutil
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
+	"k8s.io/klog"
 )
 
 // IsSubPath checks if the extending path is an extension of the basePath, it will return the extending path
@@ -42,3 +44,22 @@ func ReplaceBase(path, old, replacement string) (string, error) {
 	}
 	return filepath.Join(replacement, extendingPath), nil
 }
+
+// ReplaceDir replaces dir with the directory at src. The current dir is moved
+// to backup first and is restored if src cannot be moved in place.
+func ReplaceDir(dir, src, backup string) error {
+	if _, err := os.Stat(dir); err == nil {
+		if err := os.Rename(dir, backup); err != nil {
+			return errors.Wrapf(err, "failed to move %q aside", dir)
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to check %q", dir)
+	}
+	if err := os.Rename(src, dir); err != nil {
+		if rerr := os.Rename(backup, dir); rerr != nil && !os.IsNotExist(rerr) {
+			klog.Warningf("failed to restore %q: %v", dir, rerr)
+		}
+		return errors.Wrapf(err, "failed to move %q to %q", src, dir)
+	}
+	return nil
+}
@@ -62,6 +62,7 @@ func (p *P) WithShortDescription(v string) *P     { p.v.Spec.ShortDescription =
 func (p *P) WithTypeMeta(v metav1.TypeMeta) *P    { p.v.TypeMeta = v; return p }
 func (p *P) WithPlatforms(v ...index.Platform) *P { p.v.Spec.Platforms = v; return p }
 func (p *P) WithVersion(v string) *P              { p.v.Spec.Version = v; return p }
+func (p *P) WithTags(v ...string) *P              { p.v.Spec.Tags = v; return p }
 func (p *P) V() index.Plugin                      { return p.v }
 
 func NewPlatform() *R {
@@ -101,4 +102,5 @@ func (p *R) WithFiles(v []index.FileOperation) *R    { p.v.Files = v; return p }
 func (p *R) WithBin(v string) *R                     { p.v.Bin = v; return p }
 func (p *R) WithURI(v string) *R                     { p.v.URI = v; return p }
 func (p *R) WithSHA256(v string) *R                  { p.v.Sha256 = v; return p }
+func (p *R) WithRawBinary(v bool) *R                 { p.v.RawBinary = v; return p }
 func (p *R) V() index.Platform                       { return p.v }
@@ -22,4 +22,9 @@ const (
 
 	// IndexURI points to the upstream index.
 	IndexURI = "https://github.com/kubernetes-sigs/krew-index.git"
+	// DefaultIndexName is the name of the index that IndexURI is cloned as.
+	DefaultIndexName = "default"
+	// ManifestSourceName is recorded in receipts as the source index of
+	// plugins installed from a custom manifest.
+	ManifestSourceName = "(manifest)"
 )
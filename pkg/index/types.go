@@ -34,6 +34,10 @@ type PluginSpec struct {
 	Caveats          string `json:"caveats,omitempty"`
 	Homepage         string `json:"homepage,omitempty"`
 
+	// Tags are optional keywords describing the capabilities of the plugin
+	// (e.g. networking, security), used for discovering plugins.
+	Tags []string `json:"tags,omitempty"`
+
 	Platforms []Platform `json:"platforms,omitempty"`
 }
 
@@ -50,6 +54,10 @@ type Platform struct {
 	// The path is relative to the root of the installation folder.
 	// The binary will be linked after all FileOperations are executed.
 	Bin string `json:"bin"`
+
+	// RawBinary specifies that the URI points to the plugin executable itself
+	// instead of an archive. The downloaded file is placed at the Bin path.
+	RawBinary bool `json:"rawBinary,omitempty"`
 }
 
 // FileOperation specifies a file copying operation from plugin archive to the
@@ -58,3 +66,72 @@ type FileOperation struct {
 	From string `json:"from,omitempty"`
 	To   string `json:"to,omitempty"`
 }
+
+// Receipt describes a plugin receipt file.
+type Receipt struct {
+	Plugin `json:",inline" yaml:",inline"`
+
+	Status ReceiptStatus `json:"status"`
+}
+
+// ReceiptStatus contains information about the installed plugin.
+type ReceiptStatus struct {
+	Source SourceIndex `json:"source"`
+
+	// BinName is the name the plugin is invoked with (as in "kubectl
+	// <binName>"). It is empty for receipts written before it was recorded,
+	// in which case the plugin name is used.
+	BinName string `json:"binName,omitempty"`
+
+	// InstalledAt is the time the plugin was installed or last upgraded. It
+	// is not set for receipts written before it was recorded.
+	InstalledAt *metav1.Time `json:"installedAt,omitempty"`
+
+	// DevLink is the path of the development build the plugin is linked to
+	// (via "install --link"). Such plugins are not managed by krew.
+	DevLink string `json:"devLink,omitempty"`
+
+	// Files are the files installed for the plugin, relative to its
+	// installation directory with forward slashes. They are not recorded for
+	// plugins linked to a development build, and for receipts written before
+	// they were recorded.
+	Files []string `json:"files,omitempty"`
+
+	// Checksums are the sha256 checksums of the Files, keyed by their path.
+	// They are not recorded for receipts written before they were recorded.
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	// SHA256 is the checksum of the download the plugin was installed from.
+	// It is not set for receipts written before it was recorded, in which
+	// case it is the checksum of the platform of the manifest in the receipt
+	// that matches the current system.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// URI is the uri the plugin was downloaded from, with the variables of
+	// the uri in the manifest expanded and its host rewritten with download
+	// host remaps. It is not set for receipts written before it was recorded.
+	URI string `json:"uri,omitempty"`
+
+	// PreviousSHA256 are the checksums of the downloads of the versions that
+	// were installed before, e.g. to remove them from the download cache. It
+	// is not set for receipts written before it was recorded.
+	PreviousSHA256 []string `json:"previousSHA256,omitempty"`
+
+	// Removed is set if the plugin was uninstalled with its receipt kept
+	// (via "uninstall --keep-receipt"), so that it can be reinstalled at the
+	// same version and from the same source. Its files are not present.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// SourceIndex contains information about the index a plugin was installed from.
+type SourceIndex struct {
+	// Name is the name of the index a plugin was installed from, or
+	// constants.ManifestSourceName for plugins installed from a custom
+	// manifest. It is empty in receipts written before the source was
+	// recorded, which were all installed from the default index.
+	Name string `json:"name,omitempty"`
+
+	// Channel is the channel of the index the plugin tracks, such as "beta".
+	// It is empty for the stable channel.
+	Channel string `json:"channel,omitempty"`
+}